@@ -0,0 +1,35 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalDuration_Succeed(t *testing.T) {
+	var d Duration
+	err := json.Unmarshal([]byte(`"1m30s"`), &d)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if time.Duration(d) != 90*time.Second {
+		t.Errorf("invalid duration: %v", time.Duration(d))
+	}
+}
+
+func TestUnmarshalDuration_Fail_Number(t *testing.T) {
+	var d Duration
+	err := json.Unmarshal([]byte(`90`), &d)
+	if err == nil {
+		t.Errorf("Duration without unit should be invalid")
+	}
+}
+
+func TestUnmarshalDuration_Fail_InvalidString(t *testing.T) {
+	var d Duration
+	err := json.Unmarshal([]byte(`"invalid"`), &d)
+	if err == nil {
+		t.Errorf("Duration with invalid string should be invalid")
+	}
+}
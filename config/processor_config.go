@@ -23,6 +23,12 @@ type ProcessorConfig struct {
 	Type     string       `json:"type"`
 	CronSpec string       `json:"cronSpec"`
 	Params   CustomConfig `json:"params"`
+
+	// BatchMaxSize and BatchMaxWait bound the batches of metrics received by batch processors,
+	// defaults are used if they are zero
+	// They are rejected for other processors
+	BatchMaxSize int      `json:"batchMaxSize"`
+	BatchMaxWait Duration `json:"batchMaxWait"`
 }
 
 var _ CustomConfig = (*ProcessorConfig)(nil)
@@ -36,8 +42,19 @@ func (config *ProcessorConfig) Validate() error {
 		return errors.New("type is required")
 	}
 
-	if _, err := globals.CronParser.Parse(strings.TrimSpace(config.CronSpec)); err != nil {
-		return err
+	// Processors driven only by input conveyors or by their own listeners need no cron
+	if strings.TrimSpace(config.CronSpec) != "" {
+		if _, err := globals.CronParser.Parse(strings.TrimSpace(config.CronSpec)); err != nil {
+			return err
+		}
+	}
+
+	if config.BatchMaxSize < 0 {
+		return errors.New("batchMaxSize must not be negative")
+	}
+
+	if config.BatchMaxWait < 0 {
+		return errors.New("batchMaxWait must not be negative")
 	}
 
 	if config.Params != nil {
@@ -51,10 +68,12 @@ func (config *ProcessorConfig) Validate() error {
 
 func (config *ProcessorConfig) UnmarshalJSON(data []byte) error {
 	aux := &struct {
-		Name     string          `json:"name"`
-		Type     string          `json:"type"`
-		CronSpec string          `json:"cronSpec"`
-		Params   json.RawMessage `json:"params"`
+		Name         string          `json:"name"`
+		Type         string          `json:"type"`
+		CronSpec     string          `json:"cronSpec"`
+		BatchMaxSize int             `json:"batchMaxSize"`
+		BatchMaxWait Duration        `json:"batchMaxWait"`
+		Params       json.RawMessage `json:"params"`
 	}{}
 
 	if err := json.Unmarshal(data, &aux); err != nil {
@@ -63,6 +82,8 @@ func (config *ProcessorConfig) UnmarshalJSON(data []byte) error {
 	config.Name = aux.Name
 	config.Type = aux.Type
 	config.CronSpec = aux.CronSpec
+	config.BatchMaxSize = aux.BatchMaxSize
+	config.BatchMaxWait = aux.BatchMaxWait
 
 	if aux.Params != nil {
 		paramConstructor, ok := type2Params[config.Type]
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestValidateProcessorConfig_Succeed(t *testing.T) {
@@ -26,6 +27,26 @@ func TestValidateProcessorConfig_Succeed(t *testing.T) {
 	}
 }
 
+func TestValidateProcessorConfig_Succeed_NoCron(t *testing.T) {
+	configStr := `
+	{
+		"name": "hostname_modifier",
+		"type": "tag_modifier"
+	}
+	`
+
+	var config ProcessorConfig
+	err := json.Unmarshal([]byte(configStr), &config)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestValidateProcessorConfig_Fail_SpaceName(t *testing.T) {
 	configStr := `
 	{
@@ -88,3 +109,31 @@ func TestValidateProcessorConfig_Fail_InvalidCron(t *testing.T) {
 		t.Errorf("Config with invalid cron should be invalid")
 	}
 }
+
+func TestValidateProcessorConfig_Batch(t *testing.T) {
+	// influxdb_output receives metrics in batches, so the batch options are supported
+	configStr := `
+	{
+		"name": "influxdb_output",
+		"type": "influxdb_output",
+		"batchMaxSize": 500,
+		"batchMaxWait": "200ms"
+	}
+	`
+
+	var config ProcessorConfig
+	err := json.Unmarshal([]byte(configStr), &config)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if config.BatchMaxSize != 500 || time.Duration(config.BatchMaxWait) != 200*time.Millisecond {
+		t.Errorf("Invalid batch options: %d, %v", config.BatchMaxSize, time.Duration(config.BatchMaxWait))
+	}
+
+	config.BatchMaxSize = -1
+	err = config.Validate()
+	if err == nil {
+		t.Errorf("Config with negative batchMaxSize should be invalid")
+	}
+}
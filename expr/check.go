@@ -0,0 +1,208 @@
+package expr
+
+// Type is the static type of an expression
+type Type int
+
+const (
+	// TypeDynamic is known only at evaluation, e.g. the type of a field value
+	TypeDynamic Type = iota
+	TypeBool
+	TypeInt
+	TypeFloat
+	TypeString
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeBool:
+		return "bool"
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeString:
+		return "string"
+	default:
+		return "dynamic"
+	}
+}
+
+func (t Type) isNumeric() bool {
+	return t == TypeInt || t == TypeFloat || t == TypeDynamic
+}
+
+// accepts tells whether a value of type u may be given where t is expected
+// Integers are accepted as floats and dynamic values are checked at evaluation
+func (t Type) accepts(u Type) bool {
+	return t == TypeDynamic || u == TypeDynamic || t == u || (t == TypeFloat && u == TypeInt)
+}
+
+// check returns the type of a syntax tree or the first type error
+func check(n node) (Type, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return typeOf(n.value), nil
+
+	case *nameNode:
+		if n.name == "time" {
+			return TypeFloat, nil
+		}
+		return TypeString, nil
+
+	case *tagNode:
+		return TypeString, nil
+
+	case *fieldNode:
+		return TypeDynamic, nil
+
+	case *unaryNode:
+		x, err := check(n.x)
+		if err != nil {
+			return 0, err
+		}
+		if n.op == "!" {
+			if !TypeBool.accepts(x) {
+				return 0, errorAt(n.pos, "operator ! requires bool, got %s", x)
+			}
+			return TypeBool, nil
+		}
+		if !x.isNumeric() {
+			return 0, errorAt(n.pos, "operator - requires a number, got %s", x)
+		}
+		return x, nil
+
+	case *binaryNode:
+		return checkBinary(n)
+
+	case *callNode:
+		return checkCall(n)
+	}
+	return 0, errorAt(n.position(), "unknown expression")
+}
+
+func checkBinary(n *binaryNode) (Type, error) {
+	x, err := check(n.x)
+	if err != nil {
+		return 0, err
+	}
+	y, err := check(n.y)
+	if err != nil {
+		return 0, err
+	}
+	mismatch := func() error {
+		return errorAt(n.pos, "invalid operands of %s: %s and %s", n.op, x, y)
+	}
+
+	switch n.op {
+	case "&&", "||":
+		if !TypeBool.accepts(x) || !TypeBool.accepts(y) {
+			return 0, mismatch()
+		}
+		return TypeBool, nil
+
+	case "==", "!=":
+		if !comparable(x, y) {
+			return 0, mismatch()
+		}
+		return TypeBool, nil
+
+	case "<", "<=", ">", ">=":
+		if !comparable(x, y) || x == TypeBool || y == TypeBool {
+			return 0, mismatch()
+		}
+		return TypeBool, nil
+
+	case "=~", "!~":
+		if !TypeString.accepts(x) {
+			return 0, mismatch()
+		}
+		if lit, ok := n.y.(*literalNode); !ok || y != TypeString {
+			return 0, errorAt(n.y.position(), "right operand of %s must be a string literal", n.op)
+		} else if _, err := compileRegexp(lit); err != nil {
+			return 0, err
+		}
+		return TypeBool, nil
+
+	case "+":
+		if x == TypeString || y == TypeString {
+			if !TypeString.accepts(x) || !TypeString.accepts(y) {
+				return 0, mismatch()
+			}
+			return TypeString, nil
+		}
+		fallthrough
+
+	case "-", "*", "%":
+		if !x.isNumeric() || !y.isNumeric() {
+			return 0, mismatch()
+		}
+		return numericResult(x, y), nil
+
+	case "/":
+		if !x.isNumeric() || !y.isNumeric() {
+			return 0, mismatch()
+		}
+		return TypeFloat, nil
+	}
+	return 0, errorAt(n.pos, "unknown operator %s", n.op)
+}
+
+// comparable tells whether the values of the types can be compared with each other
+func comparable(x, y Type) bool {
+	if x == TypeDynamic || y == TypeDynamic || x == y {
+		return true
+	}
+	return x.isNumeric() && y.isNumeric()
+}
+
+// numericResult is int for ints, float if any is a float and dynamic otherwise
+func numericResult(types ...Type) Type {
+	result := TypeInt
+	for _, t := range types {
+		if t == TypeDynamic {
+			return TypeDynamic
+		}
+		if t == TypeFloat {
+			result = TypeFloat
+		}
+	}
+	return result
+}
+
+func checkCall(n *callNode) (Type, error) {
+	if n.name == "has" {
+		if len(n.args) != 1 {
+			return 0, errorAt(n.pos, "has requires 1 argument, got %d", len(n.args))
+		}
+		switch n.args[0].(type) {
+		case *tagNode, *fieldNode:
+			return TypeBool, nil
+		}
+		return 0, errorAt(n.args[0].position(), "argument of has must be a tag or a field")
+	}
+
+	fn, ok := functions[n.name]
+	if !ok {
+		return 0, errorAt(n.pos, "unknown function \"%s\"", n.name)
+	}
+	if len(n.args) != len(fn.params) {
+		return 0, errorAt(n.pos, "%s requires %d arguments, got %d", n.name, len(fn.params), len(n.args))
+	}
+
+	types := make([]Type, len(n.args))
+	for i, arg := range n.args {
+		t, err := check(arg)
+		if err != nil {
+			return 0, err
+		}
+		if !fn.params[i].accepts(t) {
+			return 0, errorAt(arg.position(), "argument %d of %s must be %s, got %s", i+1, n.name, fn.params[i], t)
+		}
+		types[i] = t
+	}
+
+	if fn.numeric {
+		return numericResult(types...), nil
+	}
+	return fn.result, nil
+}
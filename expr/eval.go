@@ -0,0 +1,406 @@
+package expr
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// evalFunc evaluates a node against a metric
+// Values are nil for a missing field, bool, int64, float64 or string
+type evalFunc func(mt *metric.Metric) (interface{}, error)
+
+func typeOf(value interface{}) Type {
+	switch value.(type) {
+	case bool:
+		return TypeBool
+	case int64:
+		return TypeInt
+	case float64:
+		return TypeFloat
+	case string:
+		return TypeString
+	default:
+		return TypeDynamic
+	}
+}
+
+func toFloat(value interface{}) float64 {
+	if i, ok := value.(int64); ok {
+		return float64(i)
+	}
+	return value.(float64)
+}
+
+// normalize converts a field value to a value of an expression
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bool, int64, float64, string:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return normalizeUint(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return normalizeUint(v)
+	case float32:
+		return float64(v)
+	default:
+		return nil
+	}
+}
+
+func normalizeUint(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return float64(v)
+	}
+	return int64(v)
+}
+
+func compileRegexp(lit *literalNode) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(lit.value.(string))
+	if err != nil {
+		return nil, errorAt(lit.pos, "invalid regular expression: %v", err)
+	}
+	return re, nil
+}
+
+// compileNode converts a checked syntax tree to closures
+func compileNode(n node) evalFunc {
+	switch n := n.(type) {
+	case *literalNode:
+		value := n.value
+		return func(mt *metric.Metric) (interface{}, error) {
+			return value, nil
+		}
+
+	case *nameNode:
+		if n.name == "time" {
+			return func(mt *metric.Metric) (interface{}, error) {
+				return float64(mt.Time.UnixNano()) / 1e9, nil
+			}
+		}
+		return func(mt *metric.Metric) (interface{}, error) {
+			return mt.Name, nil
+		}
+
+	case *tagNode:
+		key := n.key
+		return func(mt *metric.Metric) (interface{}, error) {
+			for _, tag := range mt.Tags {
+				if tag.Key == key {
+					return tag.Value, nil
+				}
+			}
+			return "", nil
+		}
+
+	case *fieldNode:
+		key := n.key
+		return func(mt *metric.Metric) (interface{}, error) {
+			for _, field := range mt.Fields {
+				if field.Key == key {
+					return normalize(field.Value), nil
+				}
+			}
+			return nil, nil
+		}
+
+	case *unaryNode:
+		return compileUnary(n)
+
+	case *binaryNode:
+		switch n.op {
+		case "&&", "||":
+			return compileLogical(n)
+		case "=~", "!~":
+			return compileMatch(n)
+		}
+		return compileBinary(n)
+
+	case *callNode:
+		return compileCall(n)
+	}
+	panic("unknown node")
+}
+
+func compileUnary(n *unaryNode) evalFunc {
+	x := compileNode(n.x)
+	pos, op := n.pos, n.op
+	return func(mt *metric.Metric) (interface{}, error) {
+		value, err := x(mt)
+		if err != nil {
+			return nil, err
+		}
+
+		if op == "!" {
+			b, err := truthy(value, pos, op)
+			return !b, err
+		}
+		switch v := value.(type) {
+		case nil:
+			return nil, nil
+		case int64:
+			return -v, nil
+		case float64:
+			return -v, nil
+		}
+		return nil, errorAt(pos, "operator - requires a number, got %s", typeOf(value))
+	}
+}
+
+// truthy is false for a missing value
+func truthy(value interface{}, pos int, op string) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+	return false, errorAt(pos, "operator %s requires bool, got %s", op, typeOf(value))
+}
+
+func compileLogical(n *binaryNode) evalFunc {
+	x, y := compileNode(n.x), compileNode(n.y)
+	pos, op := n.pos, n.op
+	return func(mt *metric.Metric) (interface{}, error) {
+		value, err := x(mt)
+		if err != nil {
+			return nil, err
+		}
+		b, err := truthy(value, pos, op)
+		if err != nil {
+			return nil, err
+		}
+		if (op == "&&" && !b) || (op == "||" && b) {
+			return b, nil
+		}
+
+		value, err = y(mt)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(value, pos, op)
+	}
+}
+
+func compileMatch(n *binaryNode) evalFunc {
+	x := compileNode(n.x)
+	re, _ := compileRegexp(n.y.(*literalNode))
+	pos, negate := n.pos, n.op == "!~"
+	return func(mt *metric.Metric) (interface{}, error) {
+		value, err := x(mt)
+		if err != nil {
+			return nil, err
+		}
+		switch v := value.(type) {
+		case nil:
+			return false, nil
+		case string:
+			return re.MatchString(v) != negate, nil
+		}
+		return nil, errorAt(pos, "operator =~ requires string, got %s", typeOf(value))
+	}
+}
+
+func compileBinary(n *binaryNode) evalFunc {
+	x, y := compileNode(n.x), compileNode(n.y)
+	pos, op := n.pos, n.op
+	return func(mt *metric.Metric) (interface{}, error) {
+		a, err := x(mt)
+		if err != nil {
+			return nil, err
+		}
+		b, err := y(mt)
+		if err != nil {
+			return nil, err
+		}
+
+		// comparisons with a missing value are false and other operations have no value
+		if a == nil || b == nil {
+			if _, ok := precedences[op]; ok && precedences[op] == precedences["=="] {
+				return false, nil
+			}
+			return nil, nil
+		}
+
+		result, ok := binaryOp(op, a, b)
+		if !ok {
+			return nil, errorAt(pos, "invalid operands of %s: %s and %s", op, typeOf(a), typeOf(b))
+		}
+		if err, isErr := result.(error); isErr {
+			return nil, errorAt(pos, "%v", err)
+		}
+		return result, nil
+	}
+}
+
+type divisionByZero struct{}
+
+func (divisionByZero) Error() string {
+	return "integer division by zero"
+}
+
+// binaryOp applies an arithmetic or comparison operator
+// It returns false if the operand types are invalid for the operator
+func binaryOp(op string, a, b interface{}) (interface{}, bool) {
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return nil, false
+		}
+		switch op {
+		case "+":
+			return a + b, true
+		case "==", "!=", "<", "<=", ">", ">=":
+			return compareResult(op, strings.Compare(a, b)), true
+		}
+		return nil, false
+
+	case bool:
+		b, ok := b.(bool)
+		if !ok {
+			return nil, false
+		}
+		switch op {
+		case "==":
+			return a == b, true
+		case "!=":
+			return a != b, true
+		}
+		return nil, false
+	}
+
+	ai, aInt := a.(int64)
+	bi, bInt := b.(int64)
+	if aInt && bInt {
+		switch op {
+		case "+":
+			return ai + bi, true
+		case "-":
+			return ai - bi, true
+		case "*":
+			return ai * bi, true
+		case "/":
+			return float64(ai) / float64(bi), true
+		case "%":
+			if bi == 0 {
+				return divisionByZero{}, true
+			}
+			return ai % bi, true
+		}
+		switch {
+		case ai < bi:
+			return compareResult(op, -1), true
+		case ai > bi:
+			return compareResult(op, 1), true
+		}
+		return compareResult(op, 0), true
+	}
+
+	if typeOf(a) != TypeInt && typeOf(a) != TypeFloat || typeOf(b) != TypeInt && typeOf(b) != TypeFloat {
+		return nil, false
+	}
+	af, bf := toFloat(a), toFloat(b)
+	switch op {
+	case "+":
+		return af + bf, true
+	case "-":
+		return af - bf, true
+	case "*":
+		return af * bf, true
+	case "/":
+		return af / bf, true
+	case "%":
+		return math.Mod(af, bf), true
+	}
+	switch {
+	case af < bf:
+		return compareResult(op, -1), true
+	case af > bf:
+		return compareResult(op, 1), true
+	case af == bf:
+		return compareResult(op, 0), true
+	}
+	// NaN is not ordered
+	return op == "!=", true
+}
+
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func compileCall(n *callNode) evalFunc {
+	if n.name == "has" {
+		switch arg := n.args[0].(type) {
+		case *tagNode:
+			return func(mt *metric.Metric) (interface{}, error) {
+				_, err := mt.GetTag(arg.key)
+				return err == nil, nil
+			}
+		case *fieldNode:
+			return func(mt *metric.Metric) (interface{}, error) {
+				_, err := mt.GetField(arg.key)
+				return err == nil, nil
+			}
+		}
+	}
+
+	fn := functions[n.name]
+	args := make([]evalFunc, len(n.args))
+	for i, arg := range n.args {
+		args[i] = compileNode(arg)
+	}
+	pos, name := n.pos, n.name
+	return func(mt *metric.Metric) (interface{}, error) {
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			value, err := arg(mt)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				return nil, nil
+			}
+			converted, ok := convertArg(value, fn.params[i])
+			if !ok {
+				return nil, errorAt(pos, "argument %d of %s must be %s, got %s", i+1, name, fn.params[i], typeOf(value))
+			}
+			values[i] = converted
+		}
+
+		result, err := fn.call(values)
+		if err != nil {
+			return nil, errorAt(pos, "%v", err)
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,84 @@
+// Package expr implements a small expression language over metrics
+//
+// Expressions reference the metric by name, time (unix seconds as a float),
+// tags.<key> and fields.<key>, or tags["<key>"] and fields["<key>"] for any key
+// Tags are strings, missing tags are empty, and fields are typed at evaluation
+// Missing fields have no value: comparisons with them are false,
+// other operations and function calls with them have no value, and in conditions they are false
+//
+// Operators from the lowest precedence: ||, &&, the comparisons == != < <= > >=
+// and the regular expression matches =~ !~, + - and * / %, and the unary ! -
+// The division always produces a float
+//
+// Functions are has(tag or field), lower, upper, trim, len, contains, startsWith, endsWith,
+// replace, substr(str, start, length), abs, min, max, round, floor, ceil, sqrt, log, exp, pow
+// and the conversions int, float and string
+//
+// For example: name == "cpu" && tags.host =~ "^web-.*" && fields.usage > 90
+package expr
+
+import (
+	"github.com/expinc/melegraf/metric"
+)
+
+// Program is a compiled expression, it is safe for concurrent use
+type Program struct {
+	src  string
+	typ  Type
+	eval evalFunc
+}
+
+// Compile parses and type-checks an expression
+func Compile(src string) (*Program, error) {
+	n, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, err := check(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Program{
+		src:  src,
+		typ:  typ,
+		eval: compileNode(n),
+	}, nil
+}
+
+// CompileCondition compiles an expression which must evaluate to a bool
+func CompileCondition(src string) (*Program, error) {
+	program, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	if !TypeBool.accepts(program.typ) {
+		return nil, errorAt(0, "condition must be bool, got %s", program.typ)
+	}
+	return program, nil
+}
+
+func (p *Program) String() string {
+	return p.src
+}
+
+// Type returns the static type of the expression
+func (p *Program) Type() Type {
+	return p.typ
+}
+
+// Eval evaluates the expression against a metric
+// The value is nil, bool, int64, float64 or string
+func (p *Program) Eval(mt *metric.Metric) (interface{}, error) {
+	return p.eval(mt)
+}
+
+// EvalBool evaluates a condition, which is false if it has no value
+func (p *Program) EvalBool(mt *metric.Metric) (bool, error) {
+	value, err := p.eval(mt)
+	if err != nil {
+		return false, err
+	}
+	return truthy(value, 0, "condition")
+}
@@ -0,0 +1,197 @@
+package expr
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func testMetric() *metric.Metric {
+	return &metric.Metric{
+		Name: "cpu",
+		Tags: []metric.Tag{
+			{Key: "host", Value: "web-01"},
+			{Key: "data center", Value: "eu"},
+		},
+		Fields: []metric.Field{
+			{Key: "usage", Value: 95.5},
+			{Key: "cores", Value: 8},
+			{Key: "load", Value: uint32(3)},
+			{Key: "status", Value: "ok"},
+			{Key: "enabled", Value: true},
+		},
+		Time: time.Unix(1700000000, 500000000),
+	}
+}
+
+func TestEval(t *testing.T) {
+	cases := []struct {
+		src   string
+		value interface{}
+	}{
+		{`name == "cpu" && tags.host =~ "^web-.*" && fields.usage > 90`, true},
+		{`tags["data center"] == "eu"`, true},
+		{`tags.missing == ""`, true},
+		{`tags.host !~ "^db-"`, true},
+		{`1 + 2 * 3`, int64(7)},
+		{`(1 + 2) * 3`, int64(9)},
+		{`7 / 2`, 3.5},
+		{`7 % 3`, int64(1)},
+		{`-fields.cores + 1`, int64(-7)},
+		{`fields.cores * 1.5`, 12.0},
+		{`fields.load + fields.cores`, int64(11)},
+		{`"a" + 'b' + "\t"`, "ab\t"},
+		{`fields.status == "ok" || false`, true},
+		{`!fields.enabled`, false},
+		{`fields.cores >= 8 && fields.cores < 9`, true},
+		{`time`, 1700000000.5},
+		{`lower("ABC") + upper("d")`, "abcD"},
+		{`len("héllo")`, int64(5)},
+		{`substr(tags.host, 4, 10)`, "01"},
+		{`replace(tags.host, "-", "_")`, "web_01"},
+		{`contains(name, "p") && startsWith(name, "c") && endsWith(name, "u")`, true},
+		{`trim("  x ")`, "x"},
+		{`abs(-3)`, int64(3)},
+		{`max(fields.cores, 2.5)`, 8.0},
+		{`min(2, 3)`, int64(2)},
+		{`round(fields.usage)`, 96.0},
+		{`pow(2, 10)`, 1024.0},
+		{`int(fields.usage)`, int64(95)},
+		{`int("42")`, int64(42)},
+		{`float("1.5") + 1`, 2.5},
+		{`string(fields.cores) + "c"`, "8c"},
+		{`has(tags.host) && !has(fields.missing)`, true},
+
+		// missing fields
+		{`fields.missing > 1`, false},
+		{`fields.missing == fields.missing`, false},
+		{`fields.missing + 1`, nil},
+		{`abs(fields.missing)`, nil},
+		{`!fields.missing`, true},
+		{`fields.missing || true`, true},
+	}
+	for _, c := range cases {
+		program, err := Compile(c.src)
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+		value, err := program.Eval(testMetric())
+		if err != nil {
+			t.Errorf("%s: %v", c.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, c.value) {
+			t.Errorf("%s: expected %#v but got %#v", c.src, c.value, value)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		src string
+		pos int
+	}{
+		{`name ==`, 7},
+		{`name == "cpu`, 8},
+		{`(1 + 2`, 6},
+		{`1 2`, 2},
+		{`tags.`, 5},
+		{`tags[1]`, 5},
+		{`foo`, 0},
+		{`foo(1)`, 0},
+		{`name # 1`, 5},
+		{`name > 1`, 5},
+		{`"a" - "b"`, 4},
+		{`!name`, 0},
+		{`true && 1`, 5},
+		{`name =~ tags.host`, 8},
+		{`name =~ "("`, 8},
+		{`lower(1)`, 6},
+		{`lower("a", "b")`, 0},
+		{`has(name)`, 4},
+		{`true < false`, 5},
+	}
+	for _, c := range cases {
+		_, err := Compile(c.src)
+		var exprErr *Error
+		if !errors.As(err, &exprErr) {
+			t.Errorf("%s: expected an error but got %v", c.src, err)
+			continue
+		}
+		if exprErr.Pos != c.pos {
+			t.Errorf("%s: expected the error at %d but got %v", c.src, c.pos, err)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, src := range []string{
+		`fields.status > 1`,
+		`fields.enabled + 1`,
+		`fields.cores && true`,
+		`fields.cores % 0`,
+		`lower(fields.cores)`,
+		`int(fields.status)`,
+		`substr("abc", -1, 1)`,
+	} {
+		program, err := Compile(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if _, err := program.Eval(testMetric()); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+func TestCompileCondition(t *testing.T) {
+	program, err := CompileCondition(`fields.usage > 90`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pass, err := program.EvalBool(testMetric())
+	if err != nil || !pass {
+		t.Errorf("expected true but got %v, %v", pass, err)
+	}
+
+	program, err = CompileCondition(`fields.enabled`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := program.EvalBool(&metric.Metric{Fields: []metric.Field{{Key: "enabled", Value: 1}}}); err == nil {
+		t.Error("expected an error for a non-bool value")
+	}
+	if pass, err := program.EvalBool(&metric.Metric{}); err != nil || pass {
+		t.Errorf("expected false for a missing field but got %v, %v", pass, err)
+	}
+
+	if _, err := CompileCondition(`name + "x"`); err == nil {
+		t.Error("expected an error for a non-bool condition")
+	}
+}
+
+func TestFloatSemantics(t *testing.T) {
+	program, err := Compile(`fields.usage / 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := program.Eval(testMetric())
+	if err != nil || !math.IsInf(value.(float64), 1) {
+		t.Errorf("expected +Inf but got %v, %v", value, err)
+	}
+
+	program, err = Compile(`fields.nan != fields.nan`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := &metric.Metric{Fields: []metric.Field{{Key: "nan", Value: math.NaN()}}}
+	if value, err := program.Eval(mt); err != nil || value != true {
+		t.Errorf("expected NaN to be unequal but got %v, %v", value, err)
+	}
+}
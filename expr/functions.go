@@ -0,0 +1,193 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// function is a builtin function
+// The arguments are checked and converted to the parameter types before call,
+// and a call with any missing argument returns no value
+type function struct {
+	params []Type
+	result Type
+	// numeric functions return int for int arguments and float otherwise
+	numeric bool
+	call    func(args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]*function{
+	"lower": {params: []Type{TypeString}, result: TypeString, call: func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(args[0].(string)), nil
+	}},
+	"upper": {params: []Type{TypeString}, result: TypeString, call: func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(args[0].(string)), nil
+	}},
+	"trim": {params: []Type{TypeString}, result: TypeString, call: func(args []interface{}) (interface{}, error) {
+		return strings.TrimSpace(args[0].(string)), nil
+	}},
+	"len": {params: []Type{TypeString}, result: TypeInt, call: func(args []interface{}) (interface{}, error) {
+		return int64(utf8.RuneCountInString(args[0].(string))), nil
+	}},
+	"contains": {params: []Type{TypeString, TypeString}, result: TypeBool, call: func(args []interface{}) (interface{}, error) {
+		return strings.Contains(args[0].(string), args[1].(string)), nil
+	}},
+	"startsWith": {params: []Type{TypeString, TypeString}, result: TypeBool, call: func(args []interface{}) (interface{}, error) {
+		return strings.HasPrefix(args[0].(string), args[1].(string)), nil
+	}},
+	"endsWith": {params: []Type{TypeString, TypeString}, result: TypeBool, call: func(args []interface{}) (interface{}, error) {
+		return strings.HasSuffix(args[0].(string), args[1].(string)), nil
+	}},
+	"replace": {params: []Type{TypeString, TypeString, TypeString}, result: TypeString, call: func(args []interface{}) (interface{}, error) {
+		return strings.ReplaceAll(args[0].(string), args[1].(string), args[2].(string)), nil
+	}},
+	// substr returns at most length characters from the character at start
+	"substr": {params: []Type{TypeString, TypeInt, TypeInt}, result: TypeString, call: func(args []interface{}) (interface{}, error) {
+		runes := []rune(args[0].(string))
+		start, length := args[1].(int64), args[2].(int64)
+		if start < 0 || length < 0 {
+			return nil, fmt.Errorf("substr requires non-negative start and length")
+		}
+		if start > int64(len(runes)) {
+			start = int64(len(runes))
+		}
+		end := start + length
+		if end > int64(len(runes)) {
+			end = int64(len(runes))
+		}
+		return string(runes[start:end]), nil
+	}},
+
+	"abs": {params: []Type{TypeFloat}, numeric: true, call: func(args []interface{}) (interface{}, error) {
+		if i, ok := args[0].(int64); ok {
+			if i < 0 {
+				return -i, nil
+			}
+			return i, nil
+		}
+		return math.Abs(args[0].(float64)), nil
+	}},
+	"min": {params: []Type{TypeFloat, TypeFloat}, numeric: true, call: func(args []interface{}) (interface{}, error) {
+		return numericCall(args, func(a, b int64) int64 {
+			if a < b {
+				return a
+			}
+			return b
+		}, math.Min), nil
+	}},
+	"max": {params: []Type{TypeFloat, TypeFloat}, numeric: true, call: func(args []interface{}) (interface{}, error) {
+		return numericCall(args, func(a, b int64) int64 {
+			if a > b {
+				return a
+			}
+			return b
+		}, math.Max), nil
+	}},
+	"round": floatFunction(math.Round),
+	"floor": floatFunction(math.Floor),
+	"ceil":  floatFunction(math.Ceil),
+	"sqrt":  floatFunction(math.Sqrt),
+	"log":   floatFunction(math.Log),
+	"exp":   floatFunction(math.Exp),
+	"pow": {params: []Type{TypeFloat, TypeFloat}, result: TypeFloat, call: func(args []interface{}) (interface{}, error) {
+		return math.Pow(toFloat(args[0]), toFloat(args[1])), nil
+	}},
+
+	"int": {params: []Type{TypeDynamic}, result: TypeInt, call: func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case int64:
+			return v, nil
+		case float64:
+			return int64(v), nil
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return i, nil
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("can not convert \"%s\" to int", v)
+			}
+			return int64(f), nil
+		}
+		return nil, fmt.Errorf("can not convert %T to int", args[0])
+	}},
+	"float": {params: []Type{TypeDynamic}, result: TypeFloat, call: func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case int64, float64:
+			return toFloat(v), nil
+		case bool:
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("can not convert \"%s\" to float", v)
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("can not convert %T to float", args[0])
+	}},
+	"string": {params: []Type{TypeDynamic}, result: TypeString, call: func(args []interface{}) (interface{}, error) {
+		return FormatValue(args[0]), nil
+	}},
+}
+
+func floatFunction(fn func(float64) float64) *function {
+	return &function{params: []Type{TypeFloat}, result: TypeFloat, call: func(args []interface{}) (interface{}, error) {
+		return fn(toFloat(args[0])), nil
+	}}
+}
+
+// numericCall calls intFn if both arguments are ints and floatFn otherwise
+func numericCall(args []interface{}, intFn func(a, b int64) int64, floatFn func(a, b float64) float64) interface{} {
+	a, aInt := args[0].(int64)
+	b, bInt := args[1].(int64)
+	if aInt && bInt {
+		return intFn(a, b)
+	}
+	return floatFn(toFloat(args[0]), toFloat(args[1]))
+}
+
+// convertArg checks the type of an argument at evaluation
+// Ints given as floats are kept so that numeric functions can return ints
+func convertArg(value interface{}, t Type) (interface{}, bool) {
+	switch t {
+	case TypeDynamic:
+		return value, true
+	case TypeFloat:
+		switch value.(type) {
+		case int64, float64:
+			return value, true
+		}
+		return nil, false
+	default:
+		return value, typeOf(value) == t
+	}
+}
+
+// FormatValue formats a value of an expression as a string
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,171 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// value is the value of a number or a string literal
+	value interface{}
+	pos   int
+}
+
+// operators are ordered so that longer operators are matched first
+var operators = []string{
+	"&&", "||", "==", "!=", "=~", "!~", "<=", ">=",
+	"<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ".", ",",
+}
+
+func isIdentRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
+
+// tokenize splits the source to tokens terminated by a tokenEOF
+func tokenize(src string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case isIdentRune(r, true):
+			start := i
+			for i < len(runes) && isIdentRune(runes[i], false) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+
+		case unicode.IsDigit(r):
+			start := i
+			isFloat := false
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			if i+1 < len(runes) && runes[i] == '.' && unicode.IsDigit(runes[i+1]) {
+				isFloat = true
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					isFloat = true
+					i = j
+					for i < len(runes) && unicode.IsDigit(runes[i]) {
+						i++
+					}
+				}
+			}
+
+			text := string(runes[start:i])
+			if isFloat {
+				value, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return nil, errorAt(start, "invalid number %s", text)
+				}
+				tokens = append(tokens, token{kind: tokenFloat, text: text, value: value, pos: start})
+			} else {
+				value, err := strconv.ParseInt(text, 10, 64)
+				if err != nil {
+					return nil, errorAt(start, "invalid number %s", text)
+				}
+				tokens = append(tokens, token{kind: tokenInt, text: text, value: value, pos: start})
+			}
+
+		case r == '"' || r == '\'':
+			start := i
+			var builder strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				i++
+				if c == r {
+					closed = true
+					break
+				}
+				if c != '\\' {
+					builder.WriteRune(c)
+					continue
+				}
+				if i >= len(runes) {
+					break
+				}
+				switch e := runes[i]; e {
+				case 'n':
+					builder.WriteByte('\n')
+				case 't':
+					builder.WriteByte('\t')
+				case 'r':
+					builder.WriteByte('\r')
+				case '\\', '"', '\'':
+					builder.WriteRune(e)
+				default:
+					return nil, errorAt(i-1, "invalid escape sequence \\%c", e)
+				}
+				i++
+			}
+			if !closed {
+				return nil, errorAt(start, "unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[start:i]), value: builder.String(), pos: start})
+
+		default:
+			end := i + 2
+			if end > len(runes) {
+				end = len(runes)
+			}
+			matched := ""
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:end]), op) {
+					matched = op
+					break
+				}
+			}
+			if matched == "" {
+				return nil, errorAt(i, "unexpected character %q", r)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: matched, pos: i})
+			i += len(matched)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// Error is a syntax, type or evaluation error of an expression
+type Error struct {
+	// Pos is the offset in runes of the error in the source
+	Pos int
+	Msg string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", err.Pos, err.Msg)
+}
+
+func errorAt(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
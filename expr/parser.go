@@ -0,0 +1,237 @@
+package expr
+
+// node is a node of the syntax tree of an expression
+type node interface {
+	position() int
+}
+
+type literalNode struct {
+	pos   int
+	value interface{}
+}
+
+// nameNode references the name or the time of the metric
+type nameNode struct {
+	pos  int
+	name string
+}
+
+type tagNode struct {
+	pos int
+	key string
+}
+
+type fieldNode struct {
+	pos int
+	key string
+}
+
+type unaryNode struct {
+	pos int
+	op  string
+	x   node
+}
+
+type binaryNode struct {
+	pos  int
+	op   string
+	x, y node
+}
+
+type callNode struct {
+	pos  int
+	name string
+	args []node
+}
+
+func (n *literalNode) position() int { return n.pos }
+func (n *nameNode) position() int    { return n.pos }
+func (n *tagNode) position() int     { return n.pos }
+func (n *fieldNode) position() int   { return n.pos }
+func (n *unaryNode) position() int   { return n.pos }
+func (n *binaryNode) position() int  { return n.pos }
+func (n *callNode) position() int    { return n.pos }
+
+// precedences of the binary operators, higher binds tighter
+var precedences = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "=~": 3, "!~": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+// parse builds the syntax tree of an expression
+func parse(src string) (node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorAt(tok.pos, "unexpected %s", describe(tok))
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokenEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *parser) isOperator(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOperator && tok.text == text
+}
+
+func (p *parser) expect(text string) error {
+	tok := p.next()
+	if tok.kind != tokenOperator || tok.text != text {
+		return errorAt(tok.pos, "expected \"%s\" but found %s", text, describe(tok))
+	}
+	return nil
+}
+
+func describe(tok token) string {
+	if tok.kind == tokenEOF {
+		return "end of expression"
+	}
+	return "\"" + tok.text + "\""
+}
+
+// parseBinary parses the binary operations of at least the precedence by precedence climbing
+func (p *parser) parseBinary(minPrecedence int) (node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		precedence, ok := precedences[tok.text]
+		if tok.kind != tokenOperator || !ok || precedence < minPrecedence {
+			return x, nil
+		}
+		p.next()
+
+		y, err := p.parseBinary(precedence + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: tok.pos, op: tok.text, x: x, y: y}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("!") || p.isOperator("-") {
+		tok := p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{pos: tok.pos, op: tok.text, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenInt, tokenFloat, tokenString:
+		return &literalNode{pos: tok.pos, value: tok.value}, nil
+
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &literalNode{pos: tok.pos, value: true}, nil
+		case "false":
+			return &literalNode{pos: tok.pos, value: false}, nil
+		case "name", "time":
+			return &nameNode{pos: tok.pos, name: tok.text}, nil
+		case "tags", "fields":
+			key, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			if tok.text == "tags" {
+				return &tagNode{pos: tok.pos, key: key}, nil
+			}
+			return &fieldNode{pos: tok.pos, key: key}, nil
+		}
+
+		if !p.isOperator("(") {
+			return nil, errorAt(tok.pos, "unknown identifier \"%s\"", tok.text)
+		}
+		p.next()
+		call := &callNode{pos: tok.pos, name: tok.text}
+		for !p.isOperator(")") {
+			if len(call.args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseBinary(1)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		p.next()
+		return call, nil
+
+	case tokenOperator:
+		if tok.text == "(" {
+			x, err := p.parseBinary(1)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+	return nil, errorAt(tok.pos, "unexpected %s", describe(tok))
+}
+
+// parseKey parses the key of a tag or a field, either ".key" or "["key"]"
+func (p *parser) parseKey() (string, error) {
+	tok := p.next()
+	if tok.kind == tokenOperator && tok.text == "." {
+		key := p.next()
+		if key.kind != tokenIdent {
+			return "", errorAt(key.pos, "expected a key but found %s", describe(key))
+		}
+		return key.text, nil
+	}
+
+	if tok.kind == tokenOperator && tok.text == "[" {
+		key := p.next()
+		if key.kind != tokenString {
+			return "", errorAt(key.pos, "expected a string key but found %s", describe(key))
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		return key.value.(string), nil
+	}
+
+	return "", errorAt(tok.pos, "expected \".\" or \"[\" but found %s", describe(tok))
+}
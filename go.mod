@@ -7,6 +7,7 @@ require (
 	github.com/sirupsen/logrus v1.8.3
 	github.com/spf13/cobra v1.7.0
 	github.com/stretchr/testify v1.7.5
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
 )
 
 require (
@@ -21,7 +21,12 @@ THE SOFTWARE.
 */
 package main
 
-import "github.com/expinc/melegraf/cmd"
+import (
+	"github.com/expinc/melegraf/cmd"
+
+	// register all processors
+	_ "github.com/expinc/melegraf/processor/processors"
+)
 
 func main() {
 	cmd.Execute()
@@ -2,6 +2,8 @@ package metric
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -74,3 +76,23 @@ func (mt *Metric) GetField(key string) (interface{}, error) {
 	}
 	return nil, fmt.Errorf("field with key '%s' not found", key)
 }
+
+// SeriesKey returns a key identifying the series of the metric,
+// which is composed of the name and the tags sorted by key
+func (mt *Metric) SeriesKey() string {
+	tags := make([]Tag, len(mt.Tags))
+	copy(tags, mt.Tags)
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+
+	var builder strings.Builder
+	builder.WriteString(mt.Name)
+	for _, tag := range tags {
+		builder.WriteByte(',')
+		builder.WriteString(tag.Key)
+		builder.WriteByte('=')
+		builder.WriteString(tag.Value)
+	}
+	return builder.String()
+}
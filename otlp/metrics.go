@@ -0,0 +1,212 @@
+// Package otlp implements the metrics subset of the OpenTelemetry protocol
+// in the protobuf and the JSON encodings used by OTLP/HTTP
+// Gauges, sums, histograms and exponential histograms are supported,
+// other messages and fields are skipped when decoding
+package otlp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// MetricsPath is the default URL path of OTLP/HTTP metrics
+	MetricsPath = "/v1/metrics"
+
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+const (
+	AggregationTemporalityUnspecified int32 = 0
+	AggregationTemporalityDelta       int32 = 1
+	AggregationTemporalityCumulative  int32 = 2
+)
+
+// Uint64 is encoded as a decimal string in JSON and decoded from either a string or a number
+type Uint64 uint64
+
+func (v Uint64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatUint(uint64(v), 10))
+}
+
+func (v *Uint64) UnmarshalJSON(data []byte) error {
+	str, err := unquoteJSONNumber(data)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid uint64: %s", data)
+	}
+	*v = Uint64(n)
+	return nil
+}
+
+// Int64 is encoded as a decimal string in JSON and decoded from either a string or a number
+type Int64 int64
+
+func (v Int64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(v), 10))
+}
+
+func (v *Int64) UnmarshalJSON(data []byte) error {
+	str, err := unquoteJSONNumber(data)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64: %s", data)
+	}
+	*v = Int64(n)
+	return nil
+}
+
+func unquoteJSONNumber(data []byte) (string, error) {
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		err := json.Unmarshal(data, &str)
+		return str, err
+	}
+	return string(data), nil
+}
+
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []*ResourceMetrics `json:"resourceMetrics,omitempty"`
+}
+
+type ResourceMetrics struct {
+	Resource     Resource        `json:"resource"`
+	ScopeMetrics []*ScopeMetrics `json:"scopeMetrics,omitempty"`
+	SchemaURL    string          `json:"schemaUrl,omitempty"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+type ScopeMetrics struct {
+	Scope     Scope     `json:"scope"`
+	Metrics   []*Metric `json:"metrics,omitempty"`
+	SchemaURL string    `json:"schemaUrl,omitempty"`
+}
+
+// Scope is the instrumentation scope producing the metrics
+type Scope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// Metric has exactly one of Gauge, Sum, Histogram and ExponentialHistogram
+type Metric struct {
+	Name                 string                `json:"name"`
+	Description          string                `json:"description,omitempty"`
+	Unit                 string                `json:"unit,omitempty"`
+	Gauge                *Gauge                `json:"gauge,omitempty"`
+	Sum                  *Sum                  `json:"sum,omitempty"`
+	Histogram            *Histogram            `json:"histogram,omitempty"`
+	ExponentialHistogram *ExponentialHistogram `json:"exponentialHistogram,omitempty"`
+}
+
+type Gauge struct {
+	DataPoints []*NumberDataPoint `json:"dataPoints,omitempty"`
+}
+
+type Sum struct {
+	DataPoints             []*NumberDataPoint `json:"dataPoints,omitempty"`
+	AggregationTemporality int32              `json:"aggregationTemporality,omitempty"`
+	IsMonotonic            bool               `json:"isMonotonic,omitempty"`
+}
+
+type Histogram struct {
+	DataPoints             []*HistogramDataPoint `json:"dataPoints,omitempty"`
+	AggregationTemporality int32                 `json:"aggregationTemporality,omitempty"`
+}
+
+type ExponentialHistogram struct {
+	DataPoints             []*ExponentialHistogramDataPoint `json:"dataPoints,omitempty"`
+	AggregationTemporality int32                            `json:"aggregationTemporality,omitempty"`
+}
+
+// NumberDataPoint has either AsDouble or AsInt
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano,omitempty"`
+	AsDouble          *float64   `json:"asDouble,omitempty"`
+	AsInt             *Int64     `json:"asInt,omitempty"`
+}
+
+// HistogramDataPoint has one more bucket count than explicit bounds,
+// the last bucket counts the values greater than the last bound
+type HistogramDataPoint struct {
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano,omitempty"`
+	Count             Uint64     `json:"count,omitempty"`
+	Sum               *float64   `json:"sum,omitempty"`
+	BucketCounts      []Uint64   `json:"bucketCounts,omitempty"`
+	ExplicitBounds    []float64  `json:"explicitBounds,omitempty"`
+	Min               *float64   `json:"min,omitempty"`
+	Max               *float64   `json:"max,omitempty"`
+}
+
+// ExponentialHistogramDataPoint has buckets whose boundaries are powers of base = 2^(2^-scale),
+// the bucket of index i counts the values in (base^i, base^(i+1)]
+type ExponentialHistogramDataPoint struct {
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano,omitempty"`
+	Count             Uint64     `json:"count,omitempty"`
+	Sum               *float64   `json:"sum,omitempty"`
+	Scale             int32      `json:"scale,omitempty"`
+	ZeroCount         Uint64     `json:"zeroCount,omitempty"`
+	Positive          Buckets    `json:"positive"`
+	Negative          Buckets    `json:"negative"`
+	Min               *float64   `json:"min,omitempty"`
+	Max               *float64   `json:"max,omitempty"`
+	ZeroThreshold     float64    `json:"zeroThreshold,omitempty"`
+}
+
+// Buckets are the counts of consecutive bucket indexes starting from Offset
+type Buckets struct {
+	Offset       int32    `json:"offset,omitempty"`
+	BucketCounts []Uint64 `json:"bucketCounts,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue has at most one of the values set
+// Arrays, key-value lists and bytes are not supported
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *Int64   `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// StringValue creates a string AnyValue
+func StringValue(str string) AnyValue {
+	return AnyValue{StringValue: &str}
+}
+
+// String formats the value, it is empty if no value is set
+func (v AnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+	default:
+		return ""
+	}
+}
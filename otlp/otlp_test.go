@@ -0,0 +1,173 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func int64Ptr(v int64) *Int64 {
+	i := Int64(v)
+	return &i
+}
+
+func testRequest() *ExportMetricsServiceRequest {
+	enabled := true
+	return &ExportMetricsServiceRequest{
+		ResourceMetrics: []*ResourceMetrics{{
+			Resource: Resource{Attributes: []KeyValue{
+				{Key: "service.name", Value: StringValue("checkout")},
+				{Key: "enabled", Value: AnyValue{BoolValue: &enabled}},
+			}},
+			ScopeMetrics: []*ScopeMetrics{{
+				Scope: Scope{Name: "meter", Version: "1.0"},
+				Metrics: []*Metric{
+					{
+						Name: "queue.size",
+						Unit: "1",
+						Gauge: &Gauge{DataPoints: []*NumberDataPoint{{
+							Attributes:   []KeyValue{{Key: "queue", Value: AnyValue{IntValue: int64Ptr(-3)}}},
+							TimeUnixNano: 1700000000000000000,
+							AsInt:        int64Ptr(-42),
+						}}},
+					},
+					{
+						Name: "requests",
+						Sum: &Sum{
+							DataPoints: []*NumberDataPoint{{
+								StartTimeUnixNano: 1600000000000000000,
+								TimeUnixNano:      1700000000000000000,
+								AsDouble:          float64Ptr(0),
+							}},
+							AggregationTemporality: AggregationTemporalityDelta,
+							IsMonotonic:            true,
+						},
+					},
+					{
+						Name: "latency",
+						Histogram: &Histogram{
+							DataPoints: []*HistogramDataPoint{{
+								Attributes:     []KeyValue{{Key: "ratio", Value: AnyValue{DoubleValue: float64Ptr(0.5)}}},
+								TimeUnixNano:   1700000000000000000,
+								Count:          6,
+								Sum:            float64Ptr(12.5),
+								BucketCounts:   []Uint64{1, 0, 5},
+								ExplicitBounds: []float64{0.1, 1},
+								Min:            float64Ptr(0.05),
+								Max:            float64Ptr(4),
+							}},
+							AggregationTemporality: AggregationTemporalityCumulative,
+						},
+					},
+					{
+						Name: "size",
+						ExponentialHistogram: &ExponentialHistogram{
+							DataPoints: []*ExponentialHistogramDataPoint{{
+								TimeUnixNano:  1700000000000000000,
+								Count:         7,
+								Sum:           float64Ptr(-3),
+								Scale:         -2,
+								ZeroCount:     1,
+								Positive:      Buckets{Offset: -1, BucketCounts: []Uint64{2, 0, 1}},
+								Negative:      Buckets{Offset: 3, BucketCounts: []Uint64{3}},
+								ZeroThreshold: 1e-9,
+							}},
+							AggregationTemporality: AggregationTemporalityCumulative,
+						},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	req := testRequest()
+	decoded, err := UnmarshalProto(MarshalProto(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(req, decoded) {
+		a, _ := json.Marshal(req)
+		b, _ := json.Marshal(decoded)
+		t.Errorf("protobuf round trip mismatch:\n%s\n%s", a, b)
+	}
+
+	_, err = UnmarshalProto(MarshalProto(req)[:20])
+	if err == nil {
+		t.Error("truncated message should fail")
+	}
+}
+
+func TestProtoWireFormat(t *testing.T) {
+	var e protoEncoder
+	dp := &NumberDataPoint{TimeUnixNano: 1, AsInt: int64Ptr(5)}
+	dp.encode(&e)
+	expected := []byte{
+		0x19, 1, 0, 0, 0, 0, 0, 0, 0, // time_unix_nano = 3, fixed64
+		0x31, 5, 0, 0, 0, 0, 0, 0, 0, // as_int = 6, sfixed64
+	}
+	if !bytes.Equal(e.buf, expected) {
+		t.Errorf("invalid number data point encoding: %x", e.buf)
+	}
+
+	e = protoEncoder{}
+	b := &Buckets{Offset: -1, BucketCounts: []Uint64{1, 300}}
+	b.encode(&e)
+	expected = []byte{
+		0x08, 0x01, // offset = 1, sint32 zigzag of -1
+		0x12, 0x03, 0x01, 0xac, 0x02, // bucket_counts = 2, packed varints
+	}
+	if !bytes.Equal(e.buf, expected) {
+		t.Errorf("invalid buckets encoding: %x", e.buf)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	req := testRequest()
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"timeUnixNano":"1700000000000000000"`)) {
+		t.Errorf("64-bit integers should be encoded as strings: %s", data)
+	}
+
+	var decoded ExportMetricsServiceRequest
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(req, &decoded) {
+		t.Errorf("JSON round trip mismatch:\n%s", data)
+	}
+}
+
+func TestJSONNumbers(t *testing.T) {
+	data := `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [{
+		"name": "temperature",
+		"gauge": {"dataPoints": [{"timeUnixNano": 1700000000000000000, "asInt": "21"}]}
+	}]}]}]}`
+
+	var req ExportMetricsServiceRequest
+	err := json.Unmarshal([]byte(data), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dp := req.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Gauge.DataPoints[0]
+	if dp.TimeUnixNano != 1700000000000000000 || *dp.AsInt != 21 {
+		t.Errorf("invalid data point: %+v", dp)
+	}
+
+	err = json.Unmarshal([]byte(`{"resourceMetrics": [{"scopeMetrics": [{"metrics": [{
+		"gauge": {"dataPoints": [{"asInt": "2.5"}]}
+	}]}]}]}`), &req)
+	if err == nil {
+		t.Error("invalid integer should fail")
+	}
+}
@@ -0,0 +1,638 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// protoEncoder appends protobuf fields to a buffer
+type protoEncoder struct {
+	buf []byte
+}
+
+func (e *protoEncoder) tag(num int, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(num)<<3|uint64(wire))
+}
+
+func (e *protoEncoder) varint(num int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(num, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *protoEncoder) sint32(num int, v int32) {
+	e.varint(num, uint64(uint32(v<<1)^uint32(v>>31)))
+}
+
+func (e *protoEncoder) fixed64(num int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(num, wireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+func (e *protoEncoder) double(num int, v float64) {
+	e.fixed64(num, math.Float64bits(v))
+}
+
+// optionalDouble writes a field with explicit presence, zero included
+func (e *protoEncoder) optionalDouble(num int, v *float64) {
+	if v == nil {
+		return
+	}
+	e.tag(num, wireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(*v))
+}
+
+func (e *protoEncoder) bytes(num int, v []byte) {
+	e.tag(num, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *protoEncoder) string(num int, v string) {
+	if v == "" {
+		return
+	}
+	e.bytes(num, []byte(v))
+}
+
+// message writes an embedded message encoded by fn
+func (e *protoEncoder) message(num int, fn func(e *protoEncoder)) {
+	var sub protoEncoder
+	fn(&sub)
+	e.bytes(num, sub.buf)
+}
+
+func (e *protoEncoder) packedFixed64(num int, values []Uint64) {
+	if len(values) == 0 {
+		return
+	}
+	buf := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+	}
+	e.bytes(num, buf)
+}
+
+func (e *protoEncoder) packedDouble(num int, values []float64) {
+	if len(values) == 0 {
+		return
+	}
+	buf := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	e.bytes(num, buf)
+}
+
+func (e *protoEncoder) packedVarint(num int, values []Uint64) {
+	if len(values) == 0 {
+		return
+	}
+	buf := []byte{}
+	for _, v := range values {
+		buf = binary.AppendUvarint(buf, uint64(v))
+	}
+	e.bytes(num, buf)
+}
+
+// protoField is a decoded field, v holds varint and fixed values and data holds bytes
+type protoField struct {
+	num  int
+	wire int
+	v    uint64
+	data []byte
+}
+
+// readFields calls fn with every field of a message
+func readFields(data []byte, fn func(f protoField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+
+		f := protoField{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			f.v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			f.v = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			f.v = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errTruncated
+			}
+			f.data = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", f.wire)
+		}
+
+		err := fn(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f protoField) double() float64 {
+	return math.Float64frombits(f.v)
+}
+
+func (f protoField) sint32() int32 {
+	v := uint32(f.v)
+	return int32(v>>1) ^ -int32(v&1)
+}
+
+// fixed64s decodes a packed or a single repeated fixed64 field
+func (f protoField) fixed64s() ([]uint64, error) {
+	if f.wire == wireFixed64 {
+		return []uint64{f.v}, nil
+	}
+	if f.wire != wireBytes || len(f.data)%8 != 0 {
+		return nil, errTruncated
+	}
+	values := make([]uint64, 0, len(f.data)/8)
+	for i := 0; i < len(f.data); i += 8 {
+		values = append(values, binary.LittleEndian.Uint64(f.data[i:]))
+	}
+	return values, nil
+}
+
+// varints decodes a packed or a single repeated varint field
+func (f protoField) varints() ([]uint64, error) {
+	if f.wire == wireVarint {
+		return []uint64{f.v}, nil
+	}
+	if f.wire != wireBytes {
+		return nil, errTruncated
+	}
+	values := []uint64{}
+	data := f.data
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		values = append(values, v)
+		data = data[n:]
+	}
+	return values, nil
+}
+
+// MarshalProto encodes a request in protobuf
+func MarshalProto(req *ExportMetricsServiceRequest) []byte {
+	var e protoEncoder
+	for _, rm := range req.ResourceMetrics {
+		e.message(1, rm.encode)
+	}
+	return e.buf
+}
+
+// UnmarshalProto decodes a request from protobuf
+func UnmarshalProto(data []byte) (*ExportMetricsServiceRequest, error) {
+	req := &ExportMetricsServiceRequest{}
+	err := readFields(data, func(f protoField) error {
+		if f.num == 1 && f.wire == wireBytes {
+			rm := &ResourceMetrics{}
+			req.ResourceMetrics = append(req.ResourceMetrics, rm)
+			return rm.decode(f.data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (rm *ResourceMetrics) encode(e *protoEncoder) {
+	e.message(1, func(e *protoEncoder) {
+		encodeAttributes(e, 1, rm.Resource.Attributes)
+	})
+	for _, sm := range rm.ScopeMetrics {
+		e.message(2, sm.encode)
+	}
+	e.string(3, rm.SchemaURL)
+}
+
+func (rm *ResourceMetrics) decode(data []byte) error {
+	return readFields(data, func(f protoField) error {
+		switch {
+		case f.num == 1 && f.wire == wireBytes:
+			return readFields(f.data, func(f protoField) error {
+				if f.num == 1 && f.wire == wireBytes {
+					return decodeAttribute(f.data, &rm.Resource.Attributes)
+				}
+				return nil
+			})
+		case f.num == 2 && f.wire == wireBytes:
+			sm := &ScopeMetrics{}
+			rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+			return sm.decode(f.data)
+		case f.num == 3 && f.wire == wireBytes:
+			rm.SchemaURL = string(f.data)
+		}
+		return nil
+	})
+}
+
+func (sm *ScopeMetrics) encode(e *protoEncoder) {
+	e.message(1, func(e *protoEncoder) {
+		e.string(1, sm.Scope.Name)
+		e.string(2, sm.Scope.Version)
+	})
+	for _, m := range sm.Metrics {
+		e.message(2, m.encode)
+	}
+	e.string(3, sm.SchemaURL)
+}
+
+func (sm *ScopeMetrics) decode(data []byte) error {
+	return readFields(data, func(f protoField) error {
+		switch {
+		case f.num == 1 && f.wire == wireBytes:
+			return readFields(f.data, func(f protoField) error {
+				if f.wire == wireBytes && f.num == 1 {
+					sm.Scope.Name = string(f.data)
+				} else if f.wire == wireBytes && f.num == 2 {
+					sm.Scope.Version = string(f.data)
+				}
+				return nil
+			})
+		case f.num == 2 && f.wire == wireBytes:
+			m := &Metric{}
+			sm.Metrics = append(sm.Metrics, m)
+			return m.decode(f.data)
+		case f.num == 3 && f.wire == wireBytes:
+			sm.SchemaURL = string(f.data)
+		}
+		return nil
+	})
+}
+
+func (m *Metric) encode(e *protoEncoder) {
+	e.string(1, m.Name)
+	e.string(2, m.Description)
+	e.string(3, m.Unit)
+	switch {
+	case m.Gauge != nil:
+		e.message(5, func(e *protoEncoder) {
+			for _, dp := range m.Gauge.DataPoints {
+				e.message(1, dp.encode)
+			}
+		})
+	case m.Sum != nil:
+		e.message(7, func(e *protoEncoder) {
+			for _, dp := range m.Sum.DataPoints {
+				e.message(1, dp.encode)
+			}
+			e.varint(2, uint64(m.Sum.AggregationTemporality))
+			if m.Sum.IsMonotonic {
+				e.varint(3, 1)
+			}
+		})
+	case m.Histogram != nil:
+		e.message(9, func(e *protoEncoder) {
+			for _, dp := range m.Histogram.DataPoints {
+				e.message(1, dp.encode)
+			}
+			e.varint(2, uint64(m.Histogram.AggregationTemporality))
+		})
+	case m.ExponentialHistogram != nil:
+		e.message(10, func(e *protoEncoder) {
+			for _, dp := range m.ExponentialHistogram.DataPoints {
+				e.message(1, dp.encode)
+			}
+			e.varint(2, uint64(m.ExponentialHistogram.AggregationTemporality))
+		})
+	}
+}
+
+func (m *Metric) decode(data []byte) error {
+	return readFields(data, func(f protoField) error {
+		if f.wire != wireBytes {
+			return nil
+		}
+
+		switch f.num {
+		case 1:
+			m.Name = string(f.data)
+		case 2:
+			m.Description = string(f.data)
+		case 3:
+			m.Unit = string(f.data)
+		case 5:
+			m.Gauge = &Gauge{}
+			return readFields(f.data, func(f protoField) error {
+				if f.num == 1 && f.wire == wireBytes {
+					dp := &NumberDataPoint{}
+					m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+					return dp.decode(f.data)
+				}
+				return nil
+			})
+		case 7:
+			m.Sum = &Sum{}
+			return readFields(f.data, func(f protoField) error {
+				switch {
+				case f.num == 1 && f.wire == wireBytes:
+					dp := &NumberDataPoint{}
+					m.Sum.DataPoints = append(m.Sum.DataPoints, dp)
+					return dp.decode(f.data)
+				case f.num == 2 && f.wire == wireVarint:
+					m.Sum.AggregationTemporality = int32(f.v)
+				case f.num == 3 && f.wire == wireVarint:
+					m.Sum.IsMonotonic = f.v != 0
+				}
+				return nil
+			})
+		case 9:
+			m.Histogram = &Histogram{}
+			return readFields(f.data, func(f protoField) error {
+				switch {
+				case f.num == 1 && f.wire == wireBytes:
+					dp := &HistogramDataPoint{}
+					m.Histogram.DataPoints = append(m.Histogram.DataPoints, dp)
+					return dp.decode(f.data)
+				case f.num == 2 && f.wire == wireVarint:
+					m.Histogram.AggregationTemporality = int32(f.v)
+				}
+				return nil
+			})
+		case 10:
+			m.ExponentialHistogram = &ExponentialHistogram{}
+			return readFields(f.data, func(f protoField) error {
+				switch {
+				case f.num == 1 && f.wire == wireBytes:
+					dp := &ExponentialHistogramDataPoint{}
+					m.ExponentialHistogram.DataPoints = append(m.ExponentialHistogram.DataPoints, dp)
+					return dp.decode(f.data)
+				case f.num == 2 && f.wire == wireVarint:
+					m.ExponentialHistogram.AggregationTemporality = int32(f.v)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func (dp *NumberDataPoint) encode(e *protoEncoder) {
+	e.fixed64(2, uint64(dp.StartTimeUnixNano))
+	e.fixed64(3, uint64(dp.TimeUnixNano))
+	if dp.AsDouble != nil {
+		e.optionalDouble(4, dp.AsDouble)
+	} else if dp.AsInt != nil {
+		e.tag(6, wireFixed64)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(*dp.AsInt))
+	}
+	encodeAttributes(e, 7, dp.Attributes)
+}
+
+func (dp *NumberDataPoint) decode(data []byte) error {
+	return readFields(data, func(f protoField) error {
+		switch {
+		case f.num == 2 && f.wire == wireFixed64:
+			dp.StartTimeUnixNano = Uint64(f.v)
+		case f.num == 3 && f.wire == wireFixed64:
+			dp.TimeUnixNano = Uint64(f.v)
+		case f.num == 4 && f.wire == wireFixed64:
+			v := f.double()
+			dp.AsDouble, dp.AsInt = &v, nil
+		case f.num == 6 && f.wire == wireFixed64:
+			v := Int64(f.v)
+			dp.AsInt, dp.AsDouble = &v, nil
+		case f.num == 7 && f.wire == wireBytes:
+			return decodeAttribute(f.data, &dp.Attributes)
+		}
+		return nil
+	})
+}
+
+func (dp *HistogramDataPoint) encode(e *protoEncoder) {
+	e.fixed64(2, uint64(dp.StartTimeUnixNano))
+	e.fixed64(3, uint64(dp.TimeUnixNano))
+	e.fixed64(4, uint64(dp.Count))
+	e.optionalDouble(5, dp.Sum)
+	e.packedFixed64(6, dp.BucketCounts)
+	e.packedDouble(7, dp.ExplicitBounds)
+	encodeAttributes(e, 9, dp.Attributes)
+	e.optionalDouble(11, dp.Min)
+	e.optionalDouble(12, dp.Max)
+}
+
+func (dp *HistogramDataPoint) decode(data []byte) error {
+	return readFields(data, func(f protoField) error {
+		switch {
+		case f.num == 2 && f.wire == wireFixed64:
+			dp.StartTimeUnixNano = Uint64(f.v)
+		case f.num == 3 && f.wire == wireFixed64:
+			dp.TimeUnixNano = Uint64(f.v)
+		case f.num == 4 && f.wire == wireFixed64:
+			dp.Count = Uint64(f.v)
+		case f.num == 5 && f.wire == wireFixed64:
+			v := f.double()
+			dp.Sum = &v
+		case f.num == 6:
+			values, err := f.fixed64s()
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				dp.BucketCounts = append(dp.BucketCounts, Uint64(v))
+			}
+		case f.num == 7:
+			values, err := f.fixed64s()
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				dp.ExplicitBounds = append(dp.ExplicitBounds, math.Float64frombits(v))
+			}
+		case f.num == 9 && f.wire == wireBytes:
+			return decodeAttribute(f.data, &dp.Attributes)
+		case f.num == 11 && f.wire == wireFixed64:
+			v := f.double()
+			dp.Min = &v
+		case f.num == 12 && f.wire == wireFixed64:
+			v := f.double()
+			dp.Max = &v
+		}
+		return nil
+	})
+}
+
+func (dp *ExponentialHistogramDataPoint) encode(e *protoEncoder) {
+	encodeAttributes(e, 1, dp.Attributes)
+	e.fixed64(2, uint64(dp.StartTimeUnixNano))
+	e.fixed64(3, uint64(dp.TimeUnixNano))
+	e.fixed64(4, uint64(dp.Count))
+	e.optionalDouble(5, dp.Sum)
+	e.sint32(6, dp.Scale)
+	e.fixed64(7, uint64(dp.ZeroCount))
+	e.message(8, dp.Positive.encode)
+	e.message(9, dp.Negative.encode)
+	e.optionalDouble(12, dp.Min)
+	e.optionalDouble(13, dp.Max)
+	e.double(14, dp.ZeroThreshold)
+}
+
+func (dp *ExponentialHistogramDataPoint) decode(data []byte) error {
+	return readFields(data, func(f protoField) error {
+		switch {
+		case f.num == 1 && f.wire == wireBytes:
+			return decodeAttribute(f.data, &dp.Attributes)
+		case f.num == 2 && f.wire == wireFixed64:
+			dp.StartTimeUnixNano = Uint64(f.v)
+		case f.num == 3 && f.wire == wireFixed64:
+			dp.TimeUnixNano = Uint64(f.v)
+		case f.num == 4 && f.wire == wireFixed64:
+			dp.Count = Uint64(f.v)
+		case f.num == 5 && f.wire == wireFixed64:
+			v := f.double()
+			dp.Sum = &v
+		case f.num == 6 && f.wire == wireVarint:
+			dp.Scale = f.sint32()
+		case f.num == 7 && f.wire == wireFixed64:
+			dp.ZeroCount = Uint64(f.v)
+		case f.num == 8 && f.wire == wireBytes:
+			return dp.Positive.decode(f.data)
+		case f.num == 9 && f.wire == wireBytes:
+			return dp.Negative.decode(f.data)
+		case f.num == 12 && f.wire == wireFixed64:
+			v := f.double()
+			dp.Min = &v
+		case f.num == 13 && f.wire == wireFixed64:
+			v := f.double()
+			dp.Max = &v
+		case f.num == 14 && f.wire == wireFixed64:
+			dp.ZeroThreshold = f.double()
+		}
+		return nil
+	})
+}
+
+func (b *Buckets) encode(e *protoEncoder) {
+	e.sint32(1, b.Offset)
+	e.packedVarint(2, b.BucketCounts)
+}
+
+func (b *Buckets) decode(data []byte) error {
+	return readFields(data, func(f protoField) error {
+		switch {
+		case f.num == 1 && f.wire == wireVarint:
+			b.Offset = f.sint32()
+		case f.num == 2:
+			values, err := f.varints()
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				b.BucketCounts = append(b.BucketCounts, Uint64(v))
+			}
+		}
+		return nil
+	})
+}
+
+func encodeAttributes(e *protoEncoder, num int, attributes []KeyValue) {
+	for _, kv := range attributes {
+		kv := kv
+		e.message(num, func(e *protoEncoder) {
+			e.string(1, kv.Key)
+			e.message(2, kv.Value.encode)
+		})
+	}
+}
+
+// decodeAttribute decodes a KeyValue and appends it to attributes
+func decodeAttribute(data []byte, attributes *[]KeyValue) error {
+	var kv KeyValue
+	err := readFields(data, func(f protoField) error {
+		switch {
+		case f.num == 1 && f.wire == wireBytes:
+			kv.Key = string(f.data)
+		case f.num == 2 && f.wire == wireBytes:
+			return kv.Value.decode(f.data)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*attributes = append(*attributes, kv)
+	return nil
+}
+
+func (v *AnyValue) encode(e *protoEncoder) {
+	switch {
+	case v.StringValue != nil:
+		e.bytes(1, []byte(*v.StringValue))
+	case v.BoolValue != nil:
+		e.tag(2, wireVarint)
+		if *v.BoolValue {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+	case v.IntValue != nil:
+		e.tag(3, wireVarint)
+		e.buf = binary.AppendUvarint(e.buf, uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		e.optionalDouble(4, v.DoubleValue)
+	}
+}
+
+func (v *AnyValue) decode(data []byte) error {
+	return readFields(data, func(f protoField) error {
+		switch {
+		case f.num == 1 && f.wire == wireBytes:
+			str := string(f.data)
+			*v = AnyValue{StringValue: &str}
+		case f.num == 2 && f.wire == wireVarint:
+			b := f.v != 0
+			*v = AnyValue{BoolValue: &b}
+		case f.num == 3 && f.wire == wireVarint:
+			i := Int64(f.v)
+			*v = AnyValue{IntValue: &i}
+		case f.num == 4 && f.wire == wireFixed64:
+			d := f.double()
+			*v = AnyValue{DoubleValue: &d}
+		}
+		return nil
+	})
+}
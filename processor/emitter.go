@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// ErrEmitterClosed is returned when metrics are emitted after the processor is closed
+var ErrEmitterClosed = errors.New("emitter is closed")
+
+// Emitter sends metrics to the output conveyors of a processor
+// It is handed to the processor in Setup so that metrics can be produced
+// outside OnReceive and OnCronTrigger, e.g. by listeners running in their own goroutines
+// It is safe to be called concurrently
+type Emitter interface {
+	// Emit sends metrics to the output conveyors of the processor
+	// It returns ErrEmitterClosed if the processor has been closed
+	Emit(metrics ...metric.Metric) error
+
+	// Stopping returns a channel which is closed once the processor is requested to stop,
+	// so that waits blocking the processor, e.g. before retries, can be cut short
+	Stopping() <-chan struct{}
+}
+
+type runnerEmitter struct {
+	sync.RWMutex
+
+	runner   *processorRunner
+	closed   bool
+	stopping chan struct{}
+}
+
+var _ Emitter = (*runnerEmitter)(nil)
+
+func newRunnerEmitter(runner *processorRunner) *runnerEmitter {
+	return &runnerEmitter{
+		runner:   runner,
+		stopping: make(chan struct{}),
+	}
+}
+
+func (emitter *runnerEmitter) Emit(metrics ...metric.Metric) error {
+	emitter.RLock()
+	defer emitter.RUnlock()
+
+	if emitter.closed {
+		return ErrEmitterClosed
+	}
+
+	emitter.runner.send(metrics)
+	return nil
+}
+
+func (emitter *runnerEmitter) Stopping() <-chan struct{} {
+	return emitter.stopping
+}
+
+// stop closes the channel returned by Stopping
+func (emitter *runnerEmitter) stop() {
+	close(emitter.stopping)
+}
+
+// close rejects all subsequent emits
+// It waits for the ongoing emits to finish
+func (emitter *runnerEmitter) close() {
+	emitter.Lock()
+	defer emitter.Unlock()
+	emitter.closed = true
+}
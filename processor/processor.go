@@ -2,6 +2,7 @@ package processor
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/expinc/melegraf/config"
 	"github.com/expinc/melegraf/metric"
@@ -16,11 +17,13 @@ type Processor interface {
 	// Setup is called once when the processor is started
 	// It is typically used to establish connections to external systems
 	// or to open necessary files
-	Setup() error
+	// The emitter can be kept to send metrics asynchronously until Close returns
+	Setup(emitter Emitter) error
 
 	// Close is called once when the processor is stopped
 	// It is typically used to close established connections
 	// or to close opened files
+	// Metrics emitted after Close returns are rejected
 	Close() error
 
 	// OnReceive is called when a metric is received from an input conveyor
@@ -32,6 +35,48 @@ type Processor interface {
 	OnCronTrigger() (out []metric.Metric, err error)
 }
 
+// BatchProcessor is an optional interface of processors receiving metrics in batches
+// The runner collects the metrics from input conveyors into batches of up to BatchMaxSize metrics,
+// and calls OnReceiveBatch instead of OnReceive once a batch is full or BatchMaxWait after its first metric
+// Pending batches are received before cron triggers and before the processor is closed
+type BatchProcessor interface {
+	Processor
+
+	// OnReceiveBatch is called with a batch of metrics received from input conveyors
+	// The batch is not reused by the runner and may be kept
+	// It returns a slice of metrics that should be sent to output conveyors
+	OnReceiveBatch(metrics []metric.Metric) (out []metric.Metric, err error)
+}
+
+const (
+	defaultBatchMaxSize = 1000
+	defaultBatchMaxWait = 100 * time.Millisecond
+)
+
+// BatchLimits returns the maximum size and wait of the batches received by a batch processor
+func BatchLimits(cfg *config.ProcessorConfig) (maxSize int, maxWait time.Duration) {
+	maxSize, maxWait = cfg.BatchMaxSize, time.Duration(cfg.BatchMaxWait)
+	if maxSize <= 0 {
+		maxSize = defaultBatchMaxSize
+	}
+	if maxWait <= 0 {
+		maxWait = defaultBatchMaxWait
+	}
+	return maxSize, maxWait
+}
+
+// Router is an optional interface of processors sending metrics only to some of their output conveyors
+// Without it, every metric is sent to all output conveyors
+type Router interface {
+	// Route returns the names of the output conveyors the metric is sent to
+	// It is called for every metric returned by OnReceive and OnCronTrigger or emitted,
+	// so it must be safe to be called concurrently
+	// A nil slice sends the metric to all output conveyors and an empty one to none,
+	// names of conveyors not connected to the processor are ignored with a warning
+	// The metric is not sent anywhere if an error is returned
+	Route(mt metric.Metric) (outputs []string, err error)
+}
+
 // ProcessorConstructor is a function that creates a new processor
 type ProcessorConstructor func(cfg *config.ProcessorConfig) (Processor, error)
 
@@ -56,5 +101,9 @@ func NewProcessor(procType string, cfg *config.ProcessorConfig) (Processor, erro
 		return nil, err
 	}
 
+	if _, ok := proc.(BatchProcessor); !ok && (cfg.BatchMaxSize != 0 || cfg.BatchMaxWait != 0) {
+		return nil, fmt.Errorf("processor type %s does not receive metrics in batches, batchMaxSize and batchMaxWait are not supported", procType)
+	}
+
 	return proc, nil
 }
@@ -0,0 +1,257 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeBasicStats = "basicstats"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeBasicStats, NewBasicStatsProcessor)
+}
+
+// basicStatsField accumulates the values of a field in a window
+type basicStatsField struct {
+	count int64
+	min   float64
+	max   float64
+	sum   float64
+	// mean and m2 are updated by Welford's algorithm
+	mean      float64
+	m2        float64
+	first     float64
+	firstTime time.Time
+	last      float64
+	lastTime  time.Time
+}
+
+func (stats *basicStatsField) add(value float64, t time.Time) {
+	stats.count++
+	if stats.count == 1 {
+		stats.min, stats.max = value, value
+		stats.first, stats.firstTime = value, t
+		stats.last, stats.lastTime = value, t
+	}
+
+	stats.min = math.Min(stats.min, value)
+	stats.max = math.Max(stats.max, value)
+	stats.sum += value
+	delta := value - stats.mean
+	stats.mean += delta / float64(stats.count)
+	stats.m2 += delta * (value - stats.mean)
+
+	if t.Before(stats.firstTime) {
+		stats.first, stats.firstTime = value, t
+	}
+	if !t.Before(stats.lastTime) {
+		stats.last, stats.lastTime = value, t
+	}
+}
+
+// value returns the statistic, or false if it is undefined
+func (stats *basicStatsField) value(stat string) (interface{}, bool) {
+	switch stat {
+	case basicStatCount:
+		return stats.count, true
+	case basicStatMin:
+		return stats.min, true
+	case basicStatMax:
+		return stats.max, true
+	case basicStatMean:
+		return stats.mean, true
+	case basicStatSum:
+		return stats.sum, true
+	case basicStatStdev:
+		if stats.count < 2 {
+			return nil, false
+		}
+		return math.Sqrt(stats.m2 / float64(stats.count-1)), true
+	case basicStatFirst:
+		return stats.first, true
+	case basicStatLast:
+		return stats.last, true
+	case basicStatRate:
+		elapsed := stats.lastTime.Sub(stats.firstTime).Seconds()
+		if elapsed <= 0 {
+			return nil, false
+		}
+		return (stats.last - stats.first) / elapsed, true
+	}
+	return nil, false
+}
+
+// basicStatsWindow accumulates the series of a period
+type basicStatsWindow struct {
+	start  time.Time
+	series map[string]*basicStatsSeries
+}
+
+// basicStatsSeries accumulates the fields of a series in a window
+type basicStatsSeries struct {
+	name   string
+	tags   []metric.Tag
+	keys   []string
+	fields map[string]*basicStatsField
+}
+
+type basicStatsProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *BasicStatsConfig
+
+	// windows are keyed by their start in unix nanoseconds
+	windows map[int64]*basicStatsWindow
+	// closedBefore is the end of the windows already emitted
+	closedBefore time.Time
+}
+
+var _ processor.Processor = (*basicStatsProcessor)(nil)
+
+// NewBasicStatsProcessor creates a new basicstats processor
+func NewBasicStatsProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*BasicStatsConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for basicstats processor: %T", cfg.Params)
+	}
+
+	return &basicStatsProcessor{
+		cfg:     cfg,
+		params:  params,
+		windows: make(map[int64]*basicStatsWindow),
+	}, nil
+}
+
+func (proc *basicStatsProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *basicStatsProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *basicStatsProcessor) Close() error {
+	return nil
+}
+
+func (proc *basicStatsProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	proc.add(mt, time.Now())
+	if proc.params.DropOriginal {
+		return []metric.Metric{}, nil
+	}
+	return []metric.Metric{mt}, nil
+}
+
+// add aggregates the numeric fields of the metric unless its window is already emitted
+// Metrics without a time are aggregated in the window of the receive time
+func (proc *basicStatsProcessor) add(mt metric.Metric, now time.Time) {
+	t := metricTime(mt, now)
+	start := t.Truncate(time.Duration(proc.params.Period))
+	if start.Before(proc.closedBefore) {
+		return
+	}
+
+	window, ok := proc.windows[start.UnixNano()]
+	if !ok {
+		window = &basicStatsWindow{start: start, series: make(map[string]*basicStatsSeries)}
+		proc.windows[start.UnixNano()] = window
+	}
+
+	key := mt.SeriesKey()
+	series, ok := window.series[key]
+	if !ok {
+		tags := make([]metric.Tag, len(mt.Tags))
+		copy(tags, mt.Tags)
+		series = &basicStatsSeries{name: mt.Name, tags: tags, fields: make(map[string]*basicStatsField)}
+		window.series[key] = series
+	}
+
+	for _, field := range mt.Fields {
+		value, ok := numericValue(field.Value)
+		if !ok {
+			continue
+		}
+		stats, ok := series.fields[field.Key]
+		if !ok {
+			stats = &basicStatsField{}
+			series.fields[field.Key] = stats
+			series.keys = append(series.keys, field.Key)
+		}
+		stats.add(value, t)
+	}
+}
+
+func (proc *basicStatsProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return proc.flush(time.Now()), nil
+}
+
+// flush emits the windows ended before the grace period, ordered by window start and series key
+func (proc *basicStatsProcessor) flush(now time.Time) []metric.Metric {
+	cutoff := now.Add(-time.Duration(proc.params.Grace)).Truncate(time.Duration(proc.params.Period))
+	if cutoff.After(proc.closedBefore) {
+		proc.closedBefore = cutoff
+	}
+
+	var windows []*basicStatsWindow
+	for key, window := range proc.windows {
+		if window.start.Before(proc.closedBefore) {
+			windows = append(windows, window)
+			delete(proc.windows, key)
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].start.Before(windows[j].start)
+	})
+
+	var out []metric.Metric
+	for _, window := range windows {
+		for _, key := range sortedKeys(window.series) {
+			series := window.series[key]
+			mt := metric.Metric{
+				Name: series.name,
+				Tags: series.tags,
+				Time: window.start,
+			}
+			for _, field := range series.keys {
+				stats := series.fields[field]
+				for _, stat := range proc.params.Stats {
+					if value, ok := stats.value(stat); ok {
+						mt.Fields = append(mt.Fields, metric.Field{Key: field + "_" + stat, Value: value})
+					}
+				}
+			}
+			if len(mt.Fields) > 0 {
+				out = append(out, mt)
+			}
+		}
+	}
+	return out
+}
+
+// numericValue converts a numeric field value to float64, booleans and strings are not numeric
+func numericValue(value interface{}) (float64, bool) {
+	if _, ok := value.(bool); ok {
+		return 0, false
+	}
+	return metric.ToFloat(value)
+}
+
+// metricTime returns the time of a metric, or the receive time if the metric has none
+func metricTime(mt metric.Metric, now time.Time) time.Time {
+	if mt.Time.IsZero() {
+		return now
+	}
+	return mt.Time
+}
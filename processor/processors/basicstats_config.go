@@ -0,0 +1,88 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+)
+
+// Statistics computed by the basicstats processor
+const (
+	basicStatCount = "count"
+	basicStatMin   = "min"
+	basicStatMax   = "max"
+	basicStatMean  = "mean"
+	basicStatSum   = "sum"
+	basicStatStdev = "stdev"
+	basicStatFirst = "first"
+	basicStatLast  = "last"
+	basicStatRate  = "rate"
+)
+
+var basicStats = []string{
+	basicStatCount, basicStatMin, basicStatMax, basicStatMean, basicStatSum,
+	basicStatStdev, basicStatFirst, basicStatLast, basicStatRate,
+}
+
+// BasicStatsConfig aggregates the numeric fields of every series over windows aligned to the period
+// A window is emitted on the first cron trigger after its end plus the grace period,
+// as a metric with the name and tags of the series, the time of the window start
+// and a field "<field>_<stat>" for every statistic
+type BasicStatsConfig struct {
+	// Period is the length of the windows, which are assigned by Metric.Time, or the receive time if it is zero
+	Period config.Duration `json:"period"`
+	// Grace keeps windows open for late metrics, metrics of emitted windows are not aggregated
+	Grace config.Duration `json:"grace"`
+	// Stats are any of count, min, max, mean, sum, stdev, first, last and rate
+	// stdev is the sample standard deviation, and rate is the change per second from the first to the last value
+	Stats []string `json:"stats"`
+	// DropOriginal drops the received metrics instead of passing them through
+	DropOriginal bool `json:"dropOriginal"`
+}
+
+var _ config.CustomConfig = (*BasicStatsConfig)(nil)
+
+func NewBasicStatsConfig() config.CustomConfig {
+	return &BasicStatsConfig{
+		Period: config.Duration(time.Minute),
+		Stats:  append([]string(nil), basicStats...),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeBasicStats, NewBasicStatsConfig)
+}
+
+func (cfg *BasicStatsConfig) Validate() error {
+	if cfg.Period <= 0 {
+		return fmt.Errorf("invalid period: %v", time.Duration(cfg.Period))
+	}
+	if cfg.Grace < 0 {
+		return fmt.Errorf("invalid grace: %v", time.Duration(cfg.Grace))
+	}
+
+	if len(cfg.Stats) == 0 {
+		return fmt.Errorf("stats are required")
+	}
+	for _, stat := range cfg.Stats {
+		valid := false
+		for _, known := range basicStats {
+			if stat == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid stat: %s", stat)
+		}
+	}
+
+	return nil
+}
+
+func (cfg *BasicStatsConfig) UnmarshalJSON(data []byte) error {
+	type plain BasicStatsConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
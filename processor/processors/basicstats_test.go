@@ -0,0 +1,164 @@
+package processors
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func newBasicStatsMetric(host string, seconds int, value interface{}) metric.Metric {
+	return metric.Metric{
+		Name:   "latency",
+		Tags:   []metric.Tag{{Key: "host", Value: host}},
+		Fields: []metric.Field{{Key: "value", Value: value}, {Key: "path", Value: "/"}},
+		Time:   time.Unix(int64(seconds), 0),
+	}
+}
+
+func TestBasicStatsWindows(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeBasicStats, `{"period": "10s"}`).(*basicStatsProcessor)
+
+	// the values of web01 are received out of order
+	for _, mt := range []metric.Metric{
+		newBasicStatsMetric("web01", 104, 4.0),
+		newBasicStatsMetric("web01", 100, 2),
+		newBasicStatsMetric("web01", 108, uint32(9)),
+		newBasicStatsMetric("web02", 101, 5.0),
+		newBasicStatsMetric("web01", 112, 1.0),
+	} {
+		out, err := proc.OnReceive(mt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 {
+			t.Fatal("metrics should be passed through")
+		}
+	}
+
+	if out := proc.flush(time.Unix(109, 0)); len(out) != 0 {
+		t.Fatalf("open windows should not be emitted: %v", out)
+	}
+
+	out := proc.flush(time.Unix(110, 0))
+	if len(out) != 2 {
+		t.Fatalf("expected 2 metrics but got %v", out)
+	}
+	web01 := findMetric(out, "latency", metric.Tag{Key: "host", Value: "web01"})
+	if web01 == nil || !web01.Time.Equal(time.Unix(100, 0)) {
+		t.Fatalf("invalid metric of web01: %v", out)
+	}
+	expected := map[string]interface{}{
+		"value_count": int64(3),
+		"value_min":   2.0,
+		"value_max":   9.0,
+		"value_mean":  5.0,
+		"value_sum":   15.0,
+		"value_stdev": math.Sqrt(13),
+		"value_first": 2.0,
+		"value_last":  9.0,
+		"value_rate":  7.0 / 8,
+	}
+	if len(web01.Fields) != len(expected) {
+		t.Errorf("expected fields %v but got %v", expected, web01.Fields)
+	}
+	for key, value := range expected {
+		actual, err := web01.GetField(key)
+		if err != nil {
+			t.Errorf("field %s is missing", key)
+			continue
+		}
+		if f, ok := value.(float64); ok && math.Abs(actual.(float64)-f) < 1e-9 {
+			continue
+		}
+		if actual != value {
+			t.Errorf("expected %s %v but got %v", key, value, actual)
+		}
+	}
+
+	// a single value has no stdev and no rate
+	web02 := findMetric(out, "latency", metric.Tag{Key: "host", Value: "web02"})
+	if web02 == nil || len(web02.Fields) != 7 {
+		t.Errorf("invalid metric of web02: %v", web02)
+	}
+
+	// late metrics of emitted windows are not aggregated
+	proc.OnReceive(newBasicStatsMetric("web01", 105, 100.0))
+	out = proc.flush(time.Unix(120, 0))
+	if len(out) != 1 || !out[0].Time.Equal(time.Unix(110, 0)) {
+		t.Fatalf("expected only the window of 110 but got %v", out)
+	}
+	if count, _ := out[0].GetField("value_count"); count != int64(1) {
+		t.Errorf("expected 1 value but got %v", count)
+	}
+}
+
+func TestBasicStatsGraceAndDrop(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeBasicStats, `{
+		"period": "10s",
+		"grace": "5s",
+		"stats": ["count", "max"],
+		"dropOriginal": true
+	}`).(*basicStatsProcessor)
+
+	out, err := proc.OnReceive(newBasicStatsMetric("web01", 101, 1.0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || len(out) != 0 {
+		t.Fatalf("metrics should be dropped: %v", out)
+	}
+
+	if out := proc.flush(time.Unix(112, 0)); len(out) != 0 {
+		t.Fatalf("window should be kept open for the grace period: %v", out)
+	}
+	proc.OnReceive(newBasicStatsMetric("web01", 109, 3.0))
+
+	out = proc.flush(time.Unix(115, 0))
+	if len(out) != 1 {
+		t.Fatalf("expected 1 metric but got %v", out)
+	}
+	expected := []metric.Field{{Key: "value_count", Value: int64(2)}, {Key: "value_max", Value: 3.0}}
+	if len(out[0].Fields) != 2 || out[0].Fields[0] != expected[0] || out[0].Fields[1] != expected[1] {
+		t.Errorf("expected fields %v but got %v", expected, out[0].Fields)
+	}
+}
+
+func TestBasicStatsReceiveTime(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeBasicStats, `{"period": "10s", "stats": ["count"]}`).(*basicStatsProcessor)
+	for i, received := range []int64{101, 104, 111} {
+		mt := metric.Metric{Name: "latency", Fields: []metric.Field{{Key: "value", Value: i}}}
+		proc.add(mt, time.Unix(received, 0))
+		if received == 104 {
+			out := proc.flush(time.Unix(110, 0))
+			if len(out) != 1 || !out[0].Time.Equal(time.Unix(100, 0)) || out[0].Fields[0].Value != int64(2) {
+				t.Fatalf("metrics without a time should be aggregated in the window of the receive time: %v", out)
+			}
+		}
+	}
+
+	out := proc.flush(time.Unix(120, 0))
+	if len(out) != 1 || !out[0].Time.Equal(time.Unix(110, 0)) || out[0].Fields[0].Value != int64(1) {
+		t.Errorf("metrics received after a flush should be aggregated in the next window: %v", out)
+	}
+}
+
+func TestBasicStatsConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{"period": "0s"}`,
+		`{"grace": "-1s"}`,
+		`{"stats": []}`,
+		`{"stats": ["median"]}`,
+	} {
+		cfg := NewBasicStatsConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package processors
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	overflowPolicyDropOldest = "drop_oldest"
+	overflowPolicyDropNewest = "drop_newest"
+)
+
+// BatchingConfig contains the buffering and retrying options of the outputs sending batches
+// The outputs receive their batches from the runner, bounded by batchMaxSize and batchMaxWait of the processor
+type BatchingConfig struct {
+	// Timeout of a single request
+	Timeout config.Duration `json:"timeout"`
+	// BufferLimit is the maximum number of metrics kept while the destination is unavailable
+	BufferLimit int `json:"bufferLimit"`
+	// OverflowPolicy decides which metrics are dropped when the buffer is full,
+	// either "drop_oldest" or "drop_newest"
+	OverflowPolicy string `json:"overflowPolicy"`
+	// MaxRetries is the number of retries of a batch on retriable errors
+	MaxRetries int `json:"maxRetries"`
+	// RetryInitialInterval is doubled on every retry up to RetryMaxInterval,
+	// which also caps the waits requested by Retry-After headers
+	// and delays the sending of the next received batches after a batch failed all its retries
+	RetryInitialInterval config.Duration `json:"retryInitialInterval"`
+	RetryMaxInterval     config.Duration `json:"retryMaxInterval"`
+	// RetryJitter randomizes the retry interval by the fraction in [0, 1]
+	RetryJitter float64 `json:"retryJitter"`
+}
+
+var _ config.Config = (*BatchingConfig)(nil)
+
+func defaultBatchingConfig() BatchingConfig {
+	return BatchingConfig{
+		Timeout:              config.Duration(5 * time.Second),
+		BufferLimit:          10000,
+		OverflowPolicy:       overflowPolicyDropOldest,
+		MaxRetries:           3,
+		RetryInitialInterval: config.Duration(time.Second),
+		RetryMaxInterval:     config.Duration(30 * time.Second),
+		RetryJitter:          0.2,
+	}
+}
+
+func (cfg *BatchingConfig) Validate() error {
+	if cfg.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+
+	if cfg.BufferLimit <= 0 {
+		return errors.New("bufferLimit must be a positive number")
+	}
+
+	if cfg.OverflowPolicy != overflowPolicyDropOldest && cfg.OverflowPolicy != overflowPolicyDropNewest {
+		return fmt.Errorf("invalid overflow policy: %s", cfg.OverflowPolicy)
+	}
+
+	if cfg.MaxRetries < 0 {
+		return errors.New("maxRetries must not be negative")
+	}
+
+	if cfg.RetryInitialInterval <= 0 || cfg.RetryMaxInterval < cfg.RetryInitialInterval {
+		return errors.New("retryInitialInterval must be positive and not greater than retryMaxInterval")
+	}
+
+	if cfg.RetryJitter < 0 || cfg.RetryJitter > 1 {
+		return errors.New("retryJitter must be in [0, 1]")
+	}
+
+	return nil
+}
+
+// sendError tells the batcher whether a failed batch should be retried
+type sendError struct {
+	err        error
+	retriable  bool
+	retryAfter time.Duration
+	// tooLarge is set if the destination rejected the batch by its size
+	tooLarge bool
+	// unsent are the metrics of the batch which were not sent if some of them were,
+	// so that only they are retried
+	unsent []metric.Metric
+}
+
+func (e *sendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sendError) Unwrap() error {
+	return e.err
+}
+
+// checkHTTPResponse classifies the response of a request sending a batch
+// 429 and 5xx responses are retriable and may carry a Retry-After header
+func checkHTTPResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := &sendError{err: fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		err.retriable = true
+		err.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return err
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// gzipBody compresses a request body
+func gzipBody(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// batcher buffers metrics and sends them in batches with exponential backoff retries
+// It is not safe for concurrent use
+type batcher struct {
+	name   string
+	params *BatchingConfig
+	// size is the maximum number of metrics sent at once
+	size int
+	send func(batch []metric.Metric) error
+	// stopping interrupts the waits before retries, see setup
+	stopping <-chan struct{}
+
+	buffer  []metric.Metric
+	dropped int
+	// heldUntil holds back the sending of the received batches after a batch failed all its retries,
+	// until the next cron trigger or until retryMaxInterval has passed
+	heldUntil time.Time
+}
+
+func newBatcher(name string, params *BatchingConfig, size int, send func(batch []metric.Metric) error) *batcher {
+	return &batcher{
+		name:   name,
+		params: params,
+		size:   size,
+		send:   send,
+	}
+}
+
+// setup makes the waits before retries end once the processor is requested to stop
+// Batches sent after that, e.g. on close, are tried only once more
+func (b *batcher) setup(emitter processor.Emitter) {
+	b.stopping = nil
+	if emitter != nil {
+		b.stopping = emitter.Stopping()
+	}
+}
+
+// add buffers received metrics and sends all buffered metrics unless the sending is held back
+func (b *batcher) add(metrics []metric.Metric) error {
+	for _, mt := range metrics {
+		if len(b.buffer) >= b.params.BufferLimit {
+			b.dropped++
+			if b.params.OverflowPolicy == overflowPolicyDropNewest {
+				continue
+			}
+			b.buffer = b.buffer[1:]
+		}
+		b.buffer = append(b.buffer, mt)
+	}
+
+	if time.Now().Before(b.heldUntil) {
+		return nil
+	}
+	return b.sendBuffer()
+}
+
+// flush sends all buffered metrics even if the sending is held back
+func (b *batcher) flush() error {
+	b.heldUntil = time.Time{}
+	return b.sendBuffer()
+}
+
+// sendBuffer sends the buffered metrics in batches of up to the size of the batcher
+func (b *batcher) sendBuffer() error {
+	if b.dropped > 0 {
+		logrus.Warnf("Processor \"%s\" dropped %d metrics because the buffer is full", b.name, b.dropped)
+		b.dropped = 0
+	}
+
+	for len(b.buffer) > 0 {
+		err := b.flushBatch()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *batcher) flushBatch() error {
+	size := b.size
+	if size > len(b.buffer) {
+		size = len(b.buffer)
+	}
+	batch := b.buffer[:size]
+
+	var err error
+	attempt := 0
+	for ; ; attempt++ {
+		err = b.send(batch)
+		if err == nil {
+			b.buffer = b.buffer[size:]
+			return nil
+		}
+
+		var sendErr *sendError
+		if errors.As(err, &sendErr) && sendErr.unsent != nil {
+			batch = sendErr.unsent
+		}
+		if sendErr != nil && !sendErr.retriable {
+			logrus.Errorf("Processor \"%s\" dropped %d metrics on a non-retriable error: %v", b.name, len(batch), err)
+			b.buffer = b.buffer[size:]
+			return err
+		}
+
+		if attempt >= b.params.MaxRetries {
+			break
+		}
+
+		wait := b.backoff(attempt)
+		if sendErr != nil && sendErr.retryAfter > 0 {
+			wait = sendErr.retryAfter
+			if wait > time.Duration(b.params.RetryMaxInterval) {
+				wait = time.Duration(b.params.RetryMaxInterval)
+			}
+		}
+		logrus.Warnf("Processor \"%s\" failed to send %d metrics, retrying in %v: %v", b.name, len(batch), wait, err)
+		if !b.wait(wait) {
+			break
+		}
+	}
+
+	// keep only the unsent metrics of the batch buffered
+	if len(batch) < size {
+		buffer := make([]metric.Metric, 0, len(batch)+len(b.buffer)-size)
+		buffer = append(buffer, batch...)
+		b.buffer = append(buffer, b.buffer[size:]...)
+	}
+	b.heldUntil = time.Now().Add(time.Duration(b.params.RetryMaxInterval))
+	return fmt.Errorf("failed to send %d metrics after %d retries: %v", len(batch), attempt, err)
+}
+
+// wait waits before a retry, it returns false if the processor is being stopped
+func (b *batcher) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.stopping:
+		return false
+	}
+}
+
+// backoff returns the exponential interval before the retry with jitter
+func (b *batcher) backoff(attempt int) time.Duration {
+	interval := time.Duration(b.params.RetryInitialInterval)
+	for i := 0; i < attempt && interval < time.Duration(b.params.RetryMaxInterval); i++ {
+		interval *= 2
+	}
+	if interval > time.Duration(b.params.RetryMaxInterval) {
+		interval = time.Duration(b.params.RetryMaxInterval)
+	}
+
+	if b.params.RetryJitter > 0 {
+		delta := float64(interval) * b.params.RetryJitter
+		interval = time.Duration(float64(interval) - delta + rand.Float64()*2*delta)
+	}
+	return interval
+}
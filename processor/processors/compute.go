@@ -0,0 +1,101 @@
+package processors
+
+import (
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/expr"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeCompute = "compute"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeCompute, NewComputeProcessor)
+}
+
+type computeProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *ComputeConfig
+}
+
+var _ processor.Processor = (*computeProcessor)(nil)
+
+// NewComputeProcessor creates a new compute processor
+func NewComputeProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*ComputeConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for compute processor: %T", cfg.Params)
+	}
+
+	return &computeProcessor{
+		cfg:    cfg,
+		params: params,
+	}, nil
+}
+
+func (proc *computeProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *computeProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *computeProcessor) Close() error {
+	return nil
+}
+
+func (proc *computeProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	// setTag and setField modify the tags and the fields in place, which belong to the caller
+	mt = mt.Copy()
+	for _, rule := range proc.params.Rules {
+		if rule.condition != nil {
+			apply, err := rule.condition.EvalBool(&mt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate condition \"%s\": %w", rule.condition, err)
+			}
+			if !apply {
+				continue
+			}
+		}
+
+		value, err := rule.expression.Eval(&mt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate expression \"%s\": %w", rule.expression, err)
+		}
+		if value == nil {
+			continue
+		}
+
+		if rule.Tag != "" {
+			mt.Tags = setTag(mt.Tags, rule.Tag, expr.FormatValue(value))
+		} else {
+			mt.Fields = setField(mt.Fields, rule.Field, value)
+		}
+	}
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *computeProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, nil
+}
+
+// setField sets the value of the field with the key, or appends a field if there is none
+func setField(fields []metric.Field, key string, value interface{}) []metric.Field {
+	for i := range fields {
+		if fields[i].Key == key {
+			fields[i].Value = value
+			return fields
+		}
+	}
+	return append(fields, metric.Field{Key: key, Value: value})
+}
@@ -0,0 +1,75 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/expr"
+)
+
+// ComputeConfig assigns fields and tags computed by expressions, see the expr package
+type ComputeConfig struct {
+	// Rules are applied in order, and each rule sees the fields and tags assigned by the previous ones
+	Rules []ComputeRule `json:"rules"`
+}
+
+// ComputeRule assigns the value of an expression to either a field or a tag
+type ComputeRule struct {
+	// Field is the key of the assigned field
+	Field string `json:"field"`
+	// Tag is the key of the assigned tag, the value is formatted as a string
+	Tag string `json:"tag"`
+	// Expression computes the value, e.g. fields.used / fields.total * 100
+	// Nothing is assigned if the value is missing
+	Expression string `json:"expression"`
+	// Condition applies the rule only to the metrics for which it is true, optional
+	Condition string `json:"condition"`
+
+	expression, condition *expr.Program
+}
+
+var _ config.CustomConfig = (*ComputeConfig)(nil)
+
+func NewComputeConfig() config.CustomConfig {
+	return &ComputeConfig{}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeCompute, NewComputeConfig)
+}
+
+func (cfg *ComputeConfig) Validate() error {
+	if len(cfg.Rules) == 0 {
+		return fmt.Errorf("rules are required")
+	}
+
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if (rule.Field == "") == (rule.Tag == "") {
+			return fmt.Errorf("rule %d requires either a field or a tag", i)
+		}
+		if rule.Expression == "" {
+			return fmt.Errorf("rule %d requires an expression", i)
+		}
+
+		var err error
+		rule.expression, err = expr.Compile(rule.Expression)
+		if err != nil {
+			return fmt.Errorf("invalid expression \"%s\" of rule %d: %w", rule.Expression, i, err)
+		}
+		rule.condition = nil
+		if rule.Condition != "" {
+			rule.condition, err = expr.CompileCondition(rule.Condition)
+			if err != nil {
+				return fmt.Errorf("invalid condition \"%s\" of rule %d: %w", rule.Condition, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (cfg *ComputeConfig) UnmarshalJSON(data []byte) error {
+	type plain ComputeConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
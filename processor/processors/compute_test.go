@@ -0,0 +1,77 @@
+package processors
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestComputeRules(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeCompute, `{"rules": [
+		{"field": "used_percent", "expression": "fields.used / fields.total * 100"},
+		{"tag": "level", "expression": "\"high\"", "condition": "fields.used_percent > 50"},
+		{"tag": "level", "expression": "\"low\"", "condition": "!has(tags.level)"},
+		{"tag": "host", "expression": "upper(tags.host)"},
+		{"field": "total", "expression": "fields.total / 1024"},
+		{"field": "missing", "expression": "fields.absent * 2"}
+	]}`).(*computeProcessor)
+
+	mt := metric.Metric{
+		Name:   "disk",
+		Tags:   []metric.Tag{{Key: "host", Value: "web01"}},
+		Fields: []metric.Field{{Key: "used", Value: int64(768)}, {Key: "total", Value: int64(1024)}},
+	}
+	out, err := proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected 1 metric but got %d", len(out))
+	}
+	if !reflect.DeepEqual(out[0].Tags, []metric.Tag{{Key: "host", Value: "WEB01"}, {Key: "level", Value: "high"}}) {
+		t.Errorf("invalid tags: %v", out[0].Tags)
+	}
+	expected := []metric.Field{{Key: "used", Value: int64(768)}, {Key: "total", Value: 1.0}, {Key: "used_percent", Value: 75.0}}
+	if !reflect.DeepEqual(out[0].Fields, expected) {
+		t.Errorf("invalid fields: %v", out[0].Fields)
+	}
+	if mt.Tags[0].Value != "web01" || len(mt.Fields) != 2 {
+		t.Error("the received metric should not be modified")
+	}
+
+	mt.Fields[0].Value = int64(1)
+	out, err = proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level, _ := out[0].GetTag("level"); level != "low" {
+		t.Errorf("expected level low but got \"%s\"", level)
+	}
+
+	mt.Fields[0].Value = "full"
+	if _, err := proc.OnReceive(mt); err == nil {
+		t.Error("dividing a string field should fail")
+	}
+}
+
+func TestComputeConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{}`,
+		`{"rules": [{"expression": "1"}]}`,
+		`{"rules": [{"field": "a", "tag": "b", "expression": "1"}]}`,
+		`{"rules": [{"field": "a"}]}`,
+		`{"rules": [{"field": "a", "expression": "1 +"}]}`,
+		`{"rules": [{"field": "a", "expression": "1", "condition": "name"}]}`,
+	} {
+		cfg := NewComputeConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypeConvert = "convert"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeConvert, NewConvertProcessor)
+}
+
+// convertUnit is a unit as a multiple of the base unit of its dimension
+type convertUnit struct {
+	dimension string
+	factor    float64
+}
+
+var convertUnits = map[string]convertUnit{
+	"bit":   {"data", 1.0 / 8},
+	"bits":  {"data", 1.0 / 8},
+	"kbit":  {"data", 1e3 / 8},
+	"Mbit":  {"data", 1e6 / 8},
+	"Gbit":  {"data", 1e9 / 8},
+	"Tbit":  {"data", 1e12 / 8},
+	"byte":  {"data", 1},
+	"bytes": {"data", 1},
+	"B":     {"data", 1},
+	"kB":    {"data", 1e3},
+	"MB":    {"data", 1e6},
+	"GB":    {"data", 1e9},
+	"TB":    {"data", 1e12},
+	"KiB":   {"data", 1 << 10},
+	"MiB":   {"data", 1 << 20},
+	"GiB":   {"data", 1 << 30},
+	"TiB":   {"data", 1 << 40},
+
+	"ns":  {"time", 1e-9},
+	"us":  {"time", 1e-6},
+	"ms":  {"time", 1e-3},
+	"s":   {"time", 1},
+	"min": {"time", 60},
+	"h":   {"time", 3600},
+	"d":   {"time", 86400},
+
+	"ratio":   {"ratio", 1},
+	"percent": {"ratio", 0.01},
+}
+
+// unitScale returns the factor converting values in the from unit to the to unit
+func unitScale(from, to string) (float64, error) {
+	fromUnit, ok := convertUnits[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit \"%s\"", from)
+	}
+	toUnit, ok := convertUnits[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit \"%s\"", to)
+	}
+	if fromUnit.dimension != toUnit.dimension {
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return fromUnit.factor / toUnit.factor, nil
+}
+
+type convertProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *ConvertConfig
+}
+
+var _ processor.Processor = (*convertProcessor)(nil)
+
+// NewConvertProcessor creates a new convert processor
+func NewConvertProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*ConvertConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for convert processor: %T", cfg.Params)
+	}
+
+	return &convertProcessor{
+		cfg:    cfg,
+		params: params,
+	}, nil
+}
+
+func (proc *convertProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *convertProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *convertProcessor) Close() error {
+	return nil
+}
+
+func (proc *convertProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	params := proc.params
+	// the tags and the fields are filtered and set in place, which belong to the caller
+	mt = mt.Copy()
+	if params.Name != "" {
+		mt.Name = params.Name
+	}
+
+	if len(params.tagsToFields) > 0 {
+		tags := mt.Tags[:0]
+		for _, tag := range mt.Tags {
+			if params.tagsToFields.match(tag.Key) {
+				mt.Fields = setField(mt.Fields, tag.Key, tag.Value)
+			} else {
+				tags = append(tags, tag)
+			}
+		}
+		mt.Tags = tags
+	}
+
+	if len(params.Rules) > 0 {
+		fields := mt.Fields[:0]
+		for _, field := range mt.Fields {
+			value, ok := proc.convertField(field)
+			if !ok {
+				logrus.Debugf("Processor \"%s\" removed field \"%s\" of metric \"%s\": cannot convert %T value %v",
+					proc.cfg.Name, field.Key, mt.Name, field.Value, field.Value)
+				continue
+			}
+			fields = append(fields, metric.Field{Key: field.Key, Value: value})
+		}
+		mt.Fields = fields
+	}
+
+	if len(params.fieldsToTags) > 0 {
+		fields := mt.Fields[:0]
+		for _, field := range mt.Fields {
+			if params.fieldsToTags.match(field.Key) {
+				value, _ := convertType(field.Value, convertTypeString)
+				mt.Tags = setTag(mt.Tags, field.Key, value.(string))
+			} else {
+				fields = append(fields, field)
+			}
+		}
+		mt.Fields = fields
+	}
+
+	if len(mt.Fields) == 0 {
+		return []metric.Metric{}, nil
+	}
+	return []metric.Metric{mt}, nil
+}
+
+// convertField applies the matching rules to the value of a field
+func (proc *convertProcessor) convertField(field metric.Field) (interface{}, bool) {
+	value := field.Value
+	for _, rule := range proc.params.Rules {
+		if !rule.fields.match(field.Key) {
+			continue
+		}
+
+		if rule.scale != 0 {
+			f, ok := convertType(value, convertTypeFloat)
+			if !ok {
+				return nil, false
+			}
+			value = f.(float64) * rule.scale
+		}
+		if rule.Type != "" {
+			var ok bool
+			value, ok = convertType(value, rule.Type)
+			if !ok {
+				return nil, false
+			}
+		}
+	}
+	return value, true
+}
+
+func (proc *convertProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, nil
+}
+
+// convertType converts a field value to int64, float64, bool or string
+// It returns false if the value is not convertible
+func convertType(value interface{}, typ string) (interface{}, bool) {
+	switch typ {
+	case convertTypeInt:
+		return convertInt(value)
+
+	case convertTypeFloat:
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return 1.0, true
+			}
+			return 0.0, true
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return f, err == nil
+		}
+		return numericValue(value)
+
+	case convertTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			return parseConvertBool(v)
+		}
+		f, ok := numericValue(value)
+		return f != 0, ok
+
+	default:
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case bool:
+			return strconv.FormatBool(v), true
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), true
+		case float32:
+			return strconv.FormatFloat(float64(v), 'g', -1, 32), true
+		}
+		return fmt.Sprint(value), true
+	}
+}
+
+// convertInt converts a value to int64, floats are truncated and strings may be decimal, prefixed integers like 0x1f or floats
+func convertInt(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case bool:
+		if v {
+			return int64(1), true
+		}
+		return int64(0), true
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return floatToInt(f)
+		}
+		// prefixed integers like 0x1f, leading zeros are not octal
+		i, err := strconv.ParseInt(s, 0, 64)
+		return i, err == nil
+	}
+
+	f, ok := numericValue(value)
+	if !ok {
+		return nil, false
+	}
+	return floatToInt(f)
+}
+
+func floatToInt(f float64) (interface{}, bool) {
+	f = math.Trunc(f)
+	// NaN fails both comparisons
+	if !(f >= math.MinInt64 && f < math.MaxInt64) {
+		return nil, false
+	}
+	return int64(f), true
+}
+
+func parseConvertBool(s string) (interface{}, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, true
+	case "0", "f", "false", "n", "no", "off":
+		return false, true
+	}
+	return nil, false
+}
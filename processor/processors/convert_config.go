@@ -0,0 +1,113 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+)
+
+const (
+	convertTypeInt    = "int"
+	convertTypeFloat  = "float"
+	convertTypeBool   = "bool"
+	convertTypeString = "string"
+)
+
+// ConvertConfig converts the types and units of fields, moves fields to tags and tags to fields, and renames metrics
+// Tags are moved to fields first, then the rules are applied, and fields are moved to tags last,
+// so that the values of moved tags can be converted by the rules
+// All patterns are globs, or regular expressions enclosed in slashes like "/^cpu[0-9]+$/"
+type ConvertConfig struct {
+	// Name renames the metrics, optional
+	Name string `json:"name"`
+	// TagsToFields moves the tags whose keys match any pattern to string fields
+	TagsToFields []string `json:"tagsToFields"`
+	// Rules are applied in order to the fields whose keys match their patterns
+	Rules []ConvertRule `json:"rules"`
+	// FieldsToTags moves the fields whose keys match any pattern to tags
+	FieldsToTags []string `json:"fieldsToTags"`
+
+	tagsToFields, fieldsToTags patternList
+}
+
+// ConvertRule converts the unit and then the type of fields
+// Fields which cannot be converted are removed, and metrics left without fields are dropped
+type ConvertRule struct {
+	// Fields are the patterns of the field keys
+	Fields []string `json:"fields"`
+	// From is the unit of the values, e.g. bytes, it requires To
+	From string `json:"from"`
+	// To is the unit the values are scaled to, e.g. GiB
+	// Units are bit (or bits), kbit, Mbit, Gbit, Tbit, byte (or bytes, B), kB, MB, GB, TB, KiB, MiB, GiB, TiB,
+	// ns, us, ms, s, min, h, d, ratio and percent
+	// Scaled values are floats unless converted by Type
+	To string `json:"to"`
+	// Type is the type the values are converted to: int, float, bool or string, optional
+	// Strings are parsed, floats are truncated to ints, booleans are 1 or 0,
+	// and numbers are true if not zero
+	Type string `json:"type"`
+
+	fields patternList
+	scale  float64
+}
+
+var _ config.CustomConfig = (*ConvertConfig)(nil)
+
+func NewConvertConfig() config.CustomConfig {
+	return &ConvertConfig{}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeConvert, NewConvertConfig)
+}
+
+func (cfg *ConvertConfig) Validate() error {
+	if cfg.Name == "" && len(cfg.TagsToFields) == 0 && len(cfg.Rules) == 0 && len(cfg.FieldsToTags) == 0 {
+		return fmt.Errorf("nothing to convert")
+	}
+
+	var err error
+	cfg.tagsToFields, err = compilePatterns(cfg.TagsToFields)
+	if err != nil {
+		return err
+	}
+	cfg.fieldsToTags, err = compilePatterns(cfg.FieldsToTags)
+	if err != nil {
+		return err
+	}
+
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if len(rule.Fields) == 0 {
+			return fmt.Errorf("rule %d requires fields", i)
+		}
+		rule.fields, err = compilePatterns(rule.Fields)
+		if err != nil {
+			return err
+		}
+
+		switch rule.Type {
+		case "", convertTypeInt, convertTypeFloat, convertTypeBool, convertTypeString:
+		default:
+			return fmt.Errorf("invalid type of rule %d: %s", i, rule.Type)
+		}
+
+		if rule.From == "" && rule.To == "" {
+			if rule.Type == "" {
+				return fmt.Errorf("rule %d requires either units or a type", i)
+			}
+			continue
+		}
+		rule.scale, err = unitScale(rule.From, rule.To)
+		if err != nil {
+			return fmt.Errorf("invalid units of rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (cfg *ConvertConfig) UnmarshalJSON(data []byte) error {
+	type plain ConvertConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
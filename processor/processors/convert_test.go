@@ -0,0 +1,161 @@
+package processors
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestConvertType(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		typ      string
+		expected interface{}
+	}{
+		{" 42 ", convertTypeInt, int64(42)},
+		{"010", convertTypeInt, int64(10)},
+		{"0x1f", convertTypeInt, int64(31)},
+		{"-2.9", convertTypeInt, int64(-2)},
+		{3.7, convertTypeInt, int64(3)},
+		{uint32(7), convertTypeInt, int64(7)},
+		{true, convertTypeInt, int64(1)},
+		{"1e3", convertTypeFloat, 1000.0},
+		{int64(5), convertTypeFloat, 5.0},
+		{false, convertTypeFloat, 0.0},
+		{"Yes", convertTypeBool, true},
+		{"off", convertTypeBool, false},
+		{0.0, convertTypeBool, false},
+		{-1, convertTypeBool, true},
+		{2.5, convertTypeString, "2.5"},
+		{uint64(1 << 63), convertTypeString, "9223372036854775808"},
+		{true, convertTypeString, "true"},
+	}
+	for _, c := range cases {
+		value, ok := convertType(c.value, c.typ)
+		if !ok || !reflect.DeepEqual(value, c.expected) {
+			t.Errorf("converting %T %v to %s: expected %#v but got %#v, %v", c.value, c.value, c.typ, c.expected, value, ok)
+		}
+	}
+
+	failures := []struct {
+		value interface{}
+		typ   string
+	}{
+		{"abc", convertTypeInt},
+		{"1e30", convertTypeInt},
+		{uint64(1 << 63), convertTypeInt},
+		{"NaN", convertTypeInt},
+		{"12 MB", convertTypeFloat},
+		{"maybe", convertTypeBool},
+		{[]int{1}, convertTypeFloat},
+	}
+	for _, c := range failures {
+		if value, ok := convertType(c.value, c.typ); ok {
+			t.Errorf("converting %T %v to %s should fail but got %#v", c.value, c.value, c.typ, value)
+		}
+	}
+}
+
+func TestConvertUnits(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeConvert, `{
+		"rules": [
+			{"fields": ["*_bytes"], "from": "bytes", "to": "GiB"},
+			{"fields": ["mem_bytes"], "type": "int"},
+			{"fields": ["duration_ms"], "from": "ms", "to": "s"},
+			{"fields": ["usage"], "from": "ratio", "to": "percent"},
+			{"fields": ["speed"], "from": "Mbit", "to": "MB"}
+		]
+	}`).(*convertProcessor)
+	mt := metric.Metric{Name: "host", Fields: []metric.Field{
+		{Key: "disk_bytes", Value: uint64(3 << 29)},
+		{Key: "mem_bytes", Value: int64(5 << 30)},
+		{Key: "duration_ms", Value: "1500"},
+		{Key: "usage", Value: 0.25},
+		{Key: "speed", Value: 800},
+		{Key: "status", Value: "ok"},
+	}}
+	out, err := proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []metric.Field{
+		{Key: "disk_bytes", Value: 1.5},
+		{Key: "mem_bytes", Value: int64(5)},
+		{Key: "duration_ms", Value: 1.5},
+		{Key: "usage", Value: 25.0},
+		{Key: "speed", Value: 100.0},
+		{Key: "status", Value: "ok"},
+	}
+	if len(out) != 1 || !reflect.DeepEqual(out[0].Fields, expected) {
+		t.Errorf("expected %v but got %v", expected, out)
+	}
+	if mt.Fields[0].Value != uint64(3<<29) {
+		t.Error("the received metric should not be modified")
+	}
+}
+
+func TestConvertMoveAndRename(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeConvert, `{
+		"name": "service",
+		"tagsToFields": ["port"],
+		"rules": [
+			{"fields": ["port", "/^count/"], "type": "int"},
+			{"fields": ["version"], "type": "string"}
+		],
+		"fieldsToTags": ["version", "region"]
+	}`).(*convertProcessor)
+	mt := metric.Metric{
+		Name:   "svc",
+		Tags:   []metric.Tag{{Key: "host", Value: "web01"}, {Key: "port", Value: "8080"}},
+		Fields: []metric.Field{{Key: "version", Value: 2.1}, {Key: "count", Value: "n/a"}, {Key: "region", Value: "eu"}},
+	}
+	out, err := proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected a metric but got %v", out)
+	}
+	if out[0].Name != "service" {
+		t.Errorf("metric should be renamed: %s", out[0].Name)
+	}
+	expectedTags := []metric.Tag{{Key: "host", Value: "web01"}, {Key: "version", Value: "2.1"}, {Key: "region", Value: "eu"}}
+	if !reflect.DeepEqual(out[0].Tags, expectedTags) {
+		t.Errorf("expected tags %v but got %v", expectedTags, out[0].Tags)
+	}
+	if !reflect.DeepEqual(out[0].Fields, []metric.Field{{Key: "port", Value: int64(8080)}}) {
+		t.Errorf("unconvertible fields should be removed: %v", out[0].Fields)
+	}
+
+	out, err = proc.OnReceive(metric.Metric{Name: "svc", Fields: []metric.Field{{Key: "count", Value: "n/a"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || len(out) != 0 {
+		t.Errorf("metric without fields should be dropped: %v", out)
+	}
+}
+
+func TestConvertConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{}`,
+		`{"tagsToFields": ["port["]}`,
+		`{"rules": [{"type": "int"}]}`,
+		`{"rules": [{"fields": ["*"]}]}`,
+		`{"rules": [{"fields": ["*"], "type": "duration"}]}`,
+		`{"rules": [{"fields": ["*"], "from": "bytes"}]}`,
+		`{"rules": [{"fields": ["*"], "from": "bytes", "to": "ms"}]}`,
+		`{"rules": [{"fields": ["*"], "from": "furlong", "to": "m"}]}`,
+	} {
+		cfg := NewConvertConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
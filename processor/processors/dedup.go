@@ -0,0 +1,141 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeDedup = "dedup"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeDedup, NewDedupProcessor)
+}
+
+// dedupEntry is the last emitted metric of a series
+type dedupEntry struct {
+	fields  []metric.Field
+	time    time.Time
+	updated time.Time
+}
+
+type dedupProcessor struct {
+	cfg     *config.ProcessorConfig
+	params  *DedupConfig
+	entries map[string]*dedupEntry
+	// expired is the time of the last expiry, which also runs on receiving at most once per heartbeat
+	expired time.Time
+}
+
+var _ processor.Processor = (*dedupProcessor)(nil)
+
+// NewDedupProcessor creates a new dedup processor
+func NewDedupProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*DedupConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for dedup processor: %T", cfg.Params)
+	}
+
+	return &dedupProcessor{
+		cfg:     cfg,
+		params:  params,
+		entries: make(map[string]*dedupEntry),
+	}, nil
+}
+
+func (proc *dedupProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *dedupProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *dedupProcessor) Close() error {
+	return nil
+}
+
+func (proc *dedupProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	if !proc.changed(mt, time.Now()) {
+		return []metric.Metric{}, nil
+	}
+	return []metric.Metric{mt}, nil
+}
+
+// changed tells whether the metric is to be emitted, and records it if so
+// Metrics without a time are timed by the receive time for the heartbeat
+func (proc *dedupProcessor) changed(mt metric.Metric, now time.Time) bool {
+	if now.Sub(proc.expired) >= time.Duration(proc.params.Heartbeat) {
+		proc.expire(now)
+	}
+
+	key := mt.SeriesKey()
+	t := metricTime(mt, now)
+	entry, ok := proc.entries[key]
+	if ok && t.Sub(entry.time) < time.Duration(proc.params.Heartbeat) && proc.equalFields(entry.fields, mt.Fields) {
+		return false
+	}
+
+	fields := make([]metric.Field, len(mt.Fields))
+	copy(fields, mt.Fields)
+	proc.entries[key] = &dedupEntry{fields: fields, time: t, updated: now}
+	return true
+}
+
+// equalFields tells whether the fields have the same keys and values within the tolerance in any order
+func (proc *dedupProcessor) equalFields(previous, current []metric.Field) bool {
+	if len(previous) != len(current) {
+		return false
+	}
+
+	for _, field := range current {
+		found := false
+		for _, old := range previous {
+			if old.Key != field.Key {
+				continue
+			}
+			found = true
+
+			oldValue, oldNumeric := numericValue(old.Value)
+			value, numeric := numericValue(field.Value)
+			if oldNumeric && numeric {
+				if !(math.Abs(value-oldValue) <= proc.params.Tolerance) {
+					return false
+				}
+			} else if old.Value != field.Value {
+				return false
+			}
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (proc *dedupProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	proc.expire(time.Now())
+	return nil, nil
+}
+
+// expire removes the series not emitted within the heartbeat, whose next metrics are emitted anyway
+func (proc *dedupProcessor) expire(now time.Time) {
+	proc.expired = now
+	for key, entry := range proc.entries {
+		if now.Sub(entry.updated) > time.Duration(proc.params.Heartbeat) {
+			delete(proc.entries, key)
+		}
+	}
+}
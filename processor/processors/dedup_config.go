@@ -0,0 +1,45 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+)
+
+// DedupConfig suppresses metrics whose fields are unchanged from the last emitted metric of the same series
+type DedupConfig struct {
+	// Tolerance is the largest absolute difference of numeric fields considered unchanged
+	Tolerance float64 `json:"tolerance"`
+	// Heartbeat emits unchanged metrics once their time is the interval after the last emitted one
+	// The time of metrics without a time is the receive time
+	Heartbeat config.Duration `json:"heartbeat"`
+}
+
+var _ config.CustomConfig = (*DedupConfig)(nil)
+
+func NewDedupConfig() config.CustomConfig {
+	return &DedupConfig{
+		Heartbeat: config.Duration(10 * time.Minute),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeDedup, NewDedupConfig)
+}
+
+func (cfg *DedupConfig) Validate() error {
+	if !(cfg.Tolerance >= 0) {
+		return fmt.Errorf("invalid tolerance: %v", cfg.Tolerance)
+	}
+	if cfg.Heartbeat <= 0 {
+		return fmt.Errorf("invalid heartbeat: %v", time.Duration(cfg.Heartbeat))
+	}
+	return nil
+}
+
+func (cfg *DedupConfig) UnmarshalJSON(data []byte) error {
+	type plain DedupConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
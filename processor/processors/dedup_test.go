@@ -0,0 +1,110 @@
+package processors
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestDedupSuppress(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeDedup, `{"tolerance": 0.5, "heartbeat": "1m"}`).(*dedupProcessor)
+
+	newMetric := func(seconds int, host string, fields ...metric.Field) metric.Metric {
+		return metric.Metric{Name: "disk", Tags: []metric.Tag{{Key: "host", Value: host}}, Fields: fields, Time: time.Unix(int64(seconds), 0)}
+	}
+	cases := []struct {
+		mt      metric.Metric
+		emitted bool
+	}{
+		{newMetric(0, "web01", metric.Field{Key: "used", Value: 10.0}, metric.Field{Key: "mode", Value: "rw"}), true},
+		{newMetric(0, "web02", metric.Field{Key: "used", Value: 10.0}, metric.Field{Key: "mode", Value: "rw"}), true},
+		// unchanged in any order and type
+		{newMetric(10, "web01", metric.Field{Key: "mode", Value: "rw"}, metric.Field{Key: "used", Value: int64(10)}), false},
+		// within the tolerance of the last emitted value
+		{newMetric(20, "web01", metric.Field{Key: "used", Value: 10.4}, metric.Field{Key: "mode", Value: "rw"}), false},
+		{newMetric(30, "web01", metric.Field{Key: "used", Value: 10.8}, metric.Field{Key: "mode", Value: "rw"}), true},
+		{newMetric(35, "web01", metric.Field{Key: "used", Value: 10.8}, metric.Field{Key: "mode", Value: "ro"}), true},
+		{newMetric(40, "web01", metric.Field{Key: "used", Value: 10.8}), true},
+		{newMetric(50, "web01", metric.Field{Key: "free", Value: 10.8}), true},
+		// heartbeat
+		{newMetric(109, "web01", metric.Field{Key: "free", Value: 10.8}), false},
+		{newMetric(110, "web01", metric.Field{Key: "free", Value: 10.8}), true},
+		{newMetric(111, "web01", metric.Field{Key: "free", Value: 10.8}), false},
+	}
+	for i, c := range cases {
+		out, err := proc.OnReceive(c.mt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out == nil || (len(out) == 1) != c.emitted {
+			t.Errorf("case %d: expected emitted %v but got %v", i, c.emitted, out)
+		}
+	}
+}
+
+func TestDedupExpire(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeDedup, `{"heartbeat": "1m"}`).(*dedupProcessor)
+	now := time.Now()
+	proc.changed(newTestMetric("cpu", 1.0), now)
+	proc.changed(newTestMetric("mem", 1.0), now.Add(time.Minute))
+
+	proc.expire(now.Add(90 * time.Second))
+	if len(proc.entries) != 1 {
+		t.Errorf("expected 1 series but got %d", len(proc.entries))
+	}
+	if !proc.changed(newTestMetric("cpu", 1.0), now.Add(90*time.Second)) {
+		t.Error("metrics of expired series should be emitted")
+	}
+}
+
+func TestDedupExpireOnReceive(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeDedup, `{"heartbeat": "1m"}`).(*dedupProcessor)
+	now := time.Now()
+	proc.changed(newTestMetric("cpu", 1.0), now)
+	proc.changed(newTestMetric("mem", 1.0), now.Add(30*time.Second))
+
+	// idle series are evicted without cron triggers
+	proc.changed(newTestMetric("mem", 1.0), now.Add(90*time.Second))
+	if len(proc.entries) != 1 {
+		t.Errorf("idle series should be evicted on receiving: %v", proc.entries)
+	}
+}
+
+func TestDedupReceiveTime(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeDedup, `{"heartbeat": "1m"}`).(*dedupProcessor)
+	mt := newTestMetric("cpu", 1.0)
+	mt.Time = time.Time{}
+
+	now := time.Unix(1000, 0)
+	for i, c := range []struct {
+		now     time.Time
+		emitted bool
+	}{
+		{now, true},
+		{now.Add(30 * time.Second), false},
+		{now.Add(time.Minute), true},
+		{now.Add(90 * time.Second), false},
+	} {
+		if emitted := proc.changed(mt, c.now); emitted != c.emitted {
+			t.Errorf("case %d: expected emitted %v but got %v", i, c.emitted, emitted)
+		}
+	}
+}
+
+func TestDedupConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{"tolerance": -1}`,
+		`{"heartbeat": "0s"}`,
+	} {
+		cfg := NewDedupConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
@@ -46,7 +46,7 @@ func (proc *dummyProcessor) Config() *config.ProcessorConfig {
 	return proc.cfg
 }
 
-func (proc *dummyProcessor) Setup() error {
+func (proc *dummyProcessor) Setup(emitter processor.Emitter) error {
 	proc.hasSetup = true
 	return nil
 }
@@ -0,0 +1,156 @@
+package processors
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypeEnrich = "enrich"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeEnrich, NewEnrichProcessor)
+}
+
+type enrichProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *EnrichConfig
+
+	// table is replaced as a whole on reload
+	table atomic.Pointer[enrichTable]
+	// modTime and size of the loaded file, only accessed by the watching goroutine after Setup
+	modTime time.Time
+	size    int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+var _ processor.Processor = (*enrichProcessor)(nil)
+
+// NewEnrichProcessor creates a new enrich processor
+func NewEnrichProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*EnrichConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for enrich processor: %T", cfg.Params)
+	}
+
+	return &enrichProcessor{
+		cfg:    cfg,
+		params: params,
+	}, nil
+}
+
+func (proc *enrichProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *enrichProcessor) Setup(emitter processor.Emitter) error {
+	_, err := proc.reload()
+	if err != nil {
+		return err
+	}
+
+	proc.done = make(chan struct{})
+	proc.wg.Add(1)
+	go proc.watch()
+	return nil
+}
+
+// watch reloads the table when the file changes until the processor is closed
+func (proc *enrichProcessor) watch() {
+	defer proc.wg.Done()
+	ticker := time.NewTicker(time.Duration(proc.params.ReloadInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-proc.done:
+			return
+		case <-ticker.C:
+			reloaded, err := proc.reload()
+			if err != nil {
+				logrus.Warnf("Processor \"%s\" failed to reload %s, keeping the previous table: %v", proc.cfg.Name, proc.params.File, err)
+			} else if reloaded {
+				logrus.Infof("Processor \"%s\" reloaded %s", proc.cfg.Name, proc.params.File)
+			}
+		}
+	}
+}
+
+// reload loads the file if its modification time or size changed since the last load
+func (proc *enrichProcessor) reload() (bool, error) {
+	info, err := os.Stat(proc.params.File)
+	if err != nil {
+		return false, err
+	}
+	if proc.table.Load() != nil && info.ModTime().Equal(proc.modTime) && info.Size() == proc.size {
+		return false, nil
+	}
+
+	table, err := loadEnrichTable(proc.params.File, proc.params.format, proc.params.Match, proc.params.Tags)
+	if err != nil {
+		return false, err
+	}
+	proc.table.Store(table)
+	proc.modTime, proc.size = info.ModTime(), info.Size()
+	return true, nil
+}
+
+func (proc *enrichProcessor) Close() error {
+	if proc.done != nil {
+		close(proc.done)
+		proc.wg.Wait()
+		proc.done = nil
+	}
+	return nil
+}
+
+func (proc *enrichProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	var tags []metric.Tag
+	if row := proc.table.Load().lookup(&mt); row != nil {
+		tags = row.tags
+	} else {
+		switch proc.params.OnMiss {
+		case enrichMissDrop:
+			return []metric.Metric{}, nil
+		case enrichMissDefault:
+			for _, key := range sortedKeys(proc.params.Defaults) {
+				tags = append(tags, metric.Tag{Key: key, Value: proc.params.Defaults[key]})
+			}
+		}
+	}
+	if len(tags) == 0 {
+		return []metric.Metric{mt}, nil
+	}
+
+	// setTag modifies the tags in place, so they are copied to leave the caller's metric unchanged
+	enriched := make([]metric.Tag, len(mt.Tags), len(mt.Tags)+len(tags))
+	copy(enriched, mt.Tags)
+	for _, tag := range tags {
+		if _, err := mt.GetTag(tag.Key); err == nil && !proc.params.Overwrite {
+			continue
+		}
+		enriched = setTag(enriched, tag.Key, tag.Value)
+	}
+	mt.Tags = enriched
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *enrichProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, nil
+}
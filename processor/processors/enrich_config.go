@@ -0,0 +1,134 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+)
+
+// Match modes of the enrich processor
+const (
+	enrichMatchExact  = "exact"
+	enrichMatchPrefix = "prefix"
+	enrichMatchCIDR   = "cidr"
+)
+
+// Behaviours of the enrich processor on metrics matching no row
+const (
+	enrichMissPass    = "pass"
+	enrichMissDrop    = "drop"
+	enrichMissDefault = "default"
+)
+
+// Formats of lookup tables
+const (
+	enrichFormatCSV  = "csv"
+	enrichFormatJSON = "json"
+)
+
+// EnrichConfig adds tags from a lookup table file to the metrics matching its rows
+// The table is either a CSV file with a header of the column names,
+// or a JSON array of objects mapping the column names to string values
+// A row matches a metric if all match rules hold, and the most specific matching row is used:
+// prefixes are as specific as their lengths and CIDRs as their prefix bits, while ties keep the first row
+// The file is reloaded when its modification time or size changes, and kept as it was if invalid
+type EnrichConfig struct {
+	// File is the path of the lookup table
+	File string `json:"file"`
+	// Format is either "csv" or "json", by the file extension if empty
+	Format string `json:"format"`
+	// Match are the rules matching the tags of metrics with the columns of rows
+	Match []EnrichMatch `json:"match"`
+	// Tags maps the columns to the keys of the added tags, all other columns to the tags of their names if empty
+	// Empty values are not added
+	Tags map[string]string `json:"tags"`
+	// Overwrite replaces the existing tags of metrics, which are kept otherwise
+	Overwrite bool `json:"overwrite"`
+	// OnMiss is "pass" to pass metrics matching no row unchanged, "drop" to drop them
+	// or "default" to add the default tags
+	OnMiss string `json:"onMiss"`
+	// Defaults are the tags added to the metrics matching no row with the "default" behaviour
+	Defaults map[string]string `json:"defaults"`
+	// ReloadInterval is the interval of checking the file for changes
+	ReloadInterval config.Duration `json:"reloadInterval"`
+
+	format string
+}
+
+// EnrichMatch matches a tag of metrics with a column of the table
+type EnrichMatch struct {
+	// Tag is the key of the matched tag, metrics without it match no row
+	Tag string `json:"tag"`
+	// Column is the name of the column, the tag key if empty
+	Column string `json:"column"`
+	// Mode is either "exact", "prefix" to match the tag values starting with the column values,
+	// or "cidr" to match IP addresses in the networks of the column values like "10.0.0.0/8"
+	Mode string `json:"mode"`
+}
+
+var _ config.CustomConfig = (*EnrichConfig)(nil)
+
+func NewEnrichConfig() config.CustomConfig {
+	return &EnrichConfig{
+		OnMiss:         enrichMissPass,
+		ReloadInterval: config.Duration(30 * time.Second),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeEnrich, NewEnrichConfig)
+}
+
+func (cfg *EnrichConfig) Validate() error {
+	if cfg.File == "" {
+		return fmt.Errorf("file is required")
+	}
+
+	cfg.format = cfg.Format
+	if cfg.format == "" {
+		cfg.format = strings.TrimPrefix(strings.ToLower(filepath.Ext(cfg.File)), ".")
+	}
+	if cfg.format != enrichFormatCSV && cfg.format != enrichFormatJSON {
+		return fmt.Errorf("invalid format: %s", cfg.format)
+	}
+
+	if len(cfg.Match) == 0 {
+		return fmt.Errorf("match rules are required")
+	}
+	for i := range cfg.Match {
+		match := &cfg.Match[i]
+		if match.Tag == "" {
+			return fmt.Errorf("tag of match rule %d is required", i)
+		}
+		if match.Column == "" {
+			match.Column = match.Tag
+		}
+		switch match.Mode {
+		case "":
+			match.Mode = enrichMatchExact
+		case enrichMatchExact, enrichMatchPrefix, enrichMatchCIDR:
+		default:
+			return fmt.Errorf("invalid match mode: %s", match.Mode)
+		}
+	}
+
+	switch cfg.OnMiss {
+	case enrichMissPass, enrichMissDrop, enrichMissDefault:
+	default:
+		return fmt.Errorf("invalid miss behaviour: %s", cfg.OnMiss)
+	}
+
+	if cfg.ReloadInterval <= 0 {
+		return fmt.Errorf("invalid reload interval: %v", time.Duration(cfg.ReloadInterval))
+	}
+	return nil
+}
+
+func (cfg *EnrichConfig) UnmarshalJSON(data []byte) error {
+	type plain EnrichConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
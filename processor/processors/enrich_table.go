@@ -0,0 +1,239 @@
+package processors
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// enrichRow is a row of a lookup table
+type enrichRow struct {
+	// values are the values of the matched columns in the order of the match rules
+	values []string
+	// prefixes are the networks of the values of the cidr rules
+	prefixes []netip.Prefix
+	tags     []metric.Tag
+}
+
+// enrichTable is a loaded lookup table, which is not modified after loading
+type enrichTable struct {
+	match []EnrichMatch
+	rows  []enrichRow
+	// exact indexes the first rows by their joined values if all rules are exact
+	exact map[string]int
+}
+
+// readEnrichRecords reads the column names and the rows of a table file
+func readEnrichRecords(path string, format string) ([]string, []map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if format == enrichFormatCSV {
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(records) == 0 {
+			return nil, nil, fmt.Errorf("%s: header is missing", path)
+		}
+		columns := records[0]
+		rows := make([]map[string]string, 0, len(records)-1)
+		for _, record := range records[1:] {
+			row := make(map[string]string, len(columns))
+			for i, column := range columns {
+				row[column] = record[i]
+			}
+			rows = append(rows, row)
+		}
+		return columns, rows, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var objects []map[string]interface{}
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	var columns []string
+	rows := make([]map[string]string, 0, len(objects))
+	for _, object := range objects {
+		row := make(map[string]string, len(object))
+		for _, column := range sortedKeys(object) {
+			switch value := object[column].(type) {
+			case nil:
+				row[column] = ""
+			case string:
+				row[column] = value
+			case json.Number, bool:
+				row[column] = fmt.Sprint(value)
+			default:
+				return nil, nil, fmt.Errorf("%s: column \"%s\" has a value of %T", path, column, value)
+			}
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+		rows = append(rows, row)
+	}
+	sort.Strings(columns)
+	return columns, rows, nil
+}
+
+// loadEnrichTable loads a table file and maps its columns to tags
+func loadEnrichTable(path string, format string, match []EnrichMatch, tags map[string]string) (*enrichTable, error) {
+	columns, records, err := readEnrichRecords(path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	type mapping struct{ column, tag string }
+	var mappings []mapping
+	if len(tags) > 0 {
+		for _, column := range sortedKeys(tags) {
+			mappings = append(mappings, mapping{column, tags[column]})
+		}
+	} else {
+		matched := map[string]bool{}
+		for _, rule := range match {
+			matched[rule.Column] = true
+		}
+		for _, column := range columns {
+			if !matched[column] {
+				mappings = append(mappings, mapping{column, column})
+			}
+		}
+	}
+
+	table := &enrichTable{match: match, rows: make([]enrichRow, 0, len(records))}
+	allExact := true
+	for _, rule := range match {
+		allExact = allExact && rule.Mode == enrichMatchExact
+	}
+	if allExact {
+		table.exact = make(map[string]int, len(records))
+	}
+
+	for i, record := range records {
+		row := enrichRow{
+			values:   make([]string, len(match)),
+			prefixes: make([]netip.Prefix, len(match)),
+		}
+		for j, rule := range match {
+			value, ok := record[rule.Column]
+			if !ok {
+				return nil, fmt.Errorf("%s: row %d has no column \"%s\"", path, i+1, rule.Column)
+			}
+			row.values[j] = value
+			if rule.Mode == enrichMatchCIDR {
+				row.prefixes[j], err = parseEnrichPrefix(value)
+				if err != nil {
+					return nil, fmt.Errorf("%s: row %d: %w", path, i+1, err)
+				}
+			}
+		}
+		for _, m := range mappings {
+			if value := record[m.column]; value != "" {
+				row.tags = append(row.tags, metric.Tag{Key: m.tag, Value: value})
+			}
+		}
+
+		table.rows = append(table.rows, row)
+		if table.exact != nil {
+			key := strings.Join(row.values, "\x00")
+			if _, ok := table.exact[key]; !ok {
+				table.exact[key] = i
+			}
+		}
+	}
+	return table, nil
+}
+
+// parseEnrichPrefix parses a network like "10.0.0.0/8", or an address as the network of itself
+func parseEnrichPrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// lookup returns the most specific row matching the metric, or nil if there is none
+func (table *enrichTable) lookup(mt *metric.Metric) *enrichRow {
+	values := make([]string, len(table.match))
+	addrs := make([]netip.Addr, len(table.match))
+	for i, rule := range table.match {
+		value, err := mt.GetTag(rule.Tag)
+		if err != nil {
+			return nil
+		}
+		values[i] = value
+		if rule.Mode == enrichMatchCIDR {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil
+			}
+			addrs[i] = addr.Unmap()
+		}
+	}
+
+	if table.exact != nil {
+		if i, ok := table.exact[strings.Join(values, "\x00")]; ok {
+			return &table.rows[i]
+		}
+		return nil
+	}
+
+	var best *enrichRow
+	bestScore := -1
+	for i := range table.rows {
+		row := &table.rows[i]
+		if score, ok := table.score(row, values, addrs); ok && score > bestScore {
+			best, bestScore = row, score
+		}
+	}
+	return best
+}
+
+// score tells whether the row matches and how specific it is
+func (table *enrichTable) score(row *enrichRow, values []string, addrs []netip.Addr) (int, bool) {
+	score := 0
+	for i, rule := range table.match {
+		switch rule.Mode {
+		case enrichMatchExact:
+			if values[i] != row.values[i] {
+				return 0, false
+			}
+		case enrichMatchPrefix:
+			if !strings.HasPrefix(values[i], row.values[i]) {
+				return 0, false
+			}
+			score += len(row.values[i])
+		case enrichMatchCIDR:
+			if !row.prefixes[i].Contains(addrs[i]) {
+				return 0, false
+			}
+			score += row.prefixes[i].Bits()
+		}
+	}
+	return score, true
+}
@@ -0,0 +1,183 @@
+package processors
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func writeEnrichFile(t *testing.T, path string, content string, modTime time.Time) {
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func enrichTags(t *testing.T, proc *enrichProcessor, tags ...metric.Tag) []metric.Tag {
+	t.Helper()
+	mt := metric.Metric{Name: "cpu", Tags: tags, Fields: []metric.Field{{Key: "value", Value: 1}}}
+	received := mt.Copy()
+	out, err := proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mt, received) {
+		t.Errorf("the received metric should not be modified: %v", mt)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out[0].Tags
+}
+
+func TestEnrichExactCSVAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.csv")
+	writeEnrichFile(t, path, "host,owner,team\nweb01,alice,frontend\ndb01,bob,\n", time.Unix(1000, 0))
+
+	proc := newTestProcessor(t, ProcessorTypeEnrich, map[string]interface{}{
+		"file":  path,
+		"match": []map[string]string{{"tag": "host"}},
+	}).(*enrichProcessor)
+
+	tags := enrichTags(t, proc, metric.Tag{Key: "host", Value: "web01"}, metric.Tag{Key: "team", Value: "ops"})
+	expected := []metric.Tag{{Key: "host", Value: "web01"}, {Key: "team", Value: "ops"}, {Key: "owner", Value: "alice"}}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("existing tags should be kept: expected %v but got %v", expected, tags)
+	}
+	tags = enrichTags(t, proc, metric.Tag{Key: "host", Value: "db01"})
+	if !reflect.DeepEqual(tags, []metric.Tag{{Key: "host", Value: "db01"}, {Key: "owner", Value: "bob"}}) {
+		t.Errorf("empty values should not be added: %v", tags)
+	}
+	tags = enrichTags(t, proc, metric.Tag{Key: "host", Value: "web02"})
+	if len(tags) != 1 {
+		t.Errorf("misses should be passed unchanged: %v", tags)
+	}
+
+	// unchanged files are not reloaded
+	if reloaded, err := proc.reload(); err != nil || reloaded {
+		t.Errorf("unchanged file should not be reloaded: %v, %v", reloaded, err)
+	}
+
+	// invalid files keep the previous table
+	writeEnrichFile(t, path, "owner\nalice\n", time.Unix(2000, 0))
+	if _, err := proc.reload(); err == nil {
+		t.Error("file without the match column should fail to load")
+	}
+	if tags := enrichTags(t, proc, metric.Tag{Key: "host", Value: "web01"}); len(tags) != 3 {
+		t.Errorf("previous table should be kept: %v", tags)
+	}
+
+	writeEnrichFile(t, path, "host,owner\nweb02,carol\n", time.Unix(3000, 0))
+	if reloaded, err := proc.reload(); err != nil || !reloaded {
+		t.Fatalf("changed file should be reloaded: %v, %v", reloaded, err)
+	}
+	if tags := enrichTags(t, proc, metric.Tag{Key: "host", Value: "web02"}); len(tags) != 2 || tags[1].Value != "carol" {
+		t.Errorf("expected the reloaded table but got %v", tags)
+	}
+}
+
+func TestEnrichWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.json")
+	writeEnrichFile(t, path, `[{"host": "web01", "region": "eu"}]`, time.Unix(1000, 0))
+
+	proc := newTestProcessor(t, ProcessorTypeEnrich, map[string]interface{}{
+		"file":           path,
+		"match":          []map[string]string{{"tag": "host"}},
+		"reloadInterval": "10ms",
+	}).(*enrichProcessor)
+	writeEnrichFile(t, path, `[{"host": "web01", "region": "us"}]`, time.Unix(2000, 0))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tags := enrichTags(t, proc, metric.Tag{Key: "host", Value: "web01"})
+		if len(tags) == 2 && tags[1].Value == "us" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("table should be reloaded: %v", tags)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEnrichPrefixCIDRAndMiss(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networks.json")
+	writeEnrichFile(t, path, `[
+		{"net": "10.0.0.0/8", "host": "", "zone": "internal", "rack": null},
+		{"net": "10.1.0.0/16", "host": "", "zone": "dmz", "rack": 1},
+		{"net": "10.1.0.0/16", "host": "web", "zone": "web-dmz", "rack": 2},
+		{"net": "2001:db8::/32", "host": "", "zone": "v6", "rack": 3}
+	]`, time.Unix(1000, 0))
+
+	proc := newTestProcessor(t, ProcessorTypeEnrich, map[string]interface{}{
+		"file": path,
+		"match": []map[string]string{
+			{"tag": "ip", "column": "net", "mode": "cidr"},
+			{"tag": "host", "mode": "prefix"},
+		},
+		"tags":      map[string]string{"zone": "network_zone"},
+		"overwrite": true,
+		"onMiss":    "default",
+		"defaults":  map[string]string{"network_zone": "unknown"},
+	}).(*enrichProcessor)
+
+	cases := []struct {
+		ip, host, zone string
+	}{
+		{"10.2.3.4", "db01", "internal"},
+		{"10.1.3.4", "db01", "dmz"},
+		{"10.1.3.4", "web01", "web-dmz"},
+		{"::ffff:10.1.3.4", "web01", "web-dmz"},
+		{"2001:db8::1", "web01", "v6"},
+		{"192.168.0.1", "web01", "unknown"},
+		{"not-an-ip", "web01", "unknown"},
+	}
+	for _, c := range cases {
+		tags := enrichTags(t, proc, metric.Tag{Key: "ip", Value: c.ip}, metric.Tag{Key: "host", Value: c.host}, metric.Tag{Key: "network_zone", Value: "old"})
+		if len(tags) != 3 || tags[2].Value != c.zone {
+			t.Errorf("%s %s: expected zone %s but got %v", c.ip, c.host, c.zone, tags)
+		}
+	}
+
+	proc = newTestProcessor(t, ProcessorTypeEnrich, map[string]interface{}{
+		"file":   path,
+		"match":  []map[string]string{{"tag": "ip", "column": "net", "mode": "cidr"}},
+		"onMiss": "drop",
+	}).(*enrichProcessor)
+	if tags := enrichTags(t, proc, metric.Tag{Key: "ip", Value: "10.1.0.1"}); !reflect.DeepEqual(tags, []metric.Tag{{Key: "ip", Value: "10.1.0.1"}, {Key: "rack", Value: "1"}, {Key: "zone", Value: "dmz"}}) {
+		t.Errorf("expected the rack and zone tags but got %v", tags)
+	}
+	if tags := enrichTags(t, proc, metric.Tag{Key: "ip", Value: "192.168.0.1"}); tags != nil {
+		t.Errorf("misses should be dropped: %v", tags)
+	}
+}
+
+func TestEnrichConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{"match": [{"tag": "host"}]}`,
+		`{"file": "hosts.yaml", "match": [{"tag": "host"}]}`,
+		`{"file": "hosts.csv"}`,
+		`{"file": "hosts.csv", "match": [{"column": "host"}]}`,
+		`{"file": "hosts.csv", "match": [{"tag": "host", "mode": "regex"}]}`,
+		`{"file": "hosts.csv", "match": [{"tag": "host"}], "onMiss": "fail"}`,
+		`{"file": "hosts.csv", "match": [{"tag": "host"}], "reloadInterval": "0s"}`,
+	} {
+		cfg := NewEnrichConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package processors
+
+import (
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeFilter = "filter"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeFilter, NewFilterProcessor)
+}
+
+type filterProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *FilterConfig
+}
+
+var _ processor.Processor = (*filterProcessor)(nil)
+
+// NewFilterProcessor creates a new filter processor
+func NewFilterProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*FilterConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for filter processor: %T", cfg.Params)
+	}
+
+	return &filterProcessor{
+		cfg:    cfg,
+		params: params,
+	}, nil
+}
+
+func (proc *filterProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *filterProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *filterProcessor) Close() error {
+	return nil
+}
+
+func (proc *filterProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	if !proc.pass(mt) {
+		return []metric.Metric{}, nil
+	}
+	if proc.params.expression != nil {
+		pass, err := proc.params.expression.EvalBool(&mt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate expression \"%s\": %w", proc.params.expression, err)
+		}
+		if !pass {
+			return []metric.Metric{}, nil
+		}
+	}
+
+	pruned := proc.prune(mt)
+	// drop the metric only if the field rules removed all its fields
+	if len(pruned.Fields) == 0 && len(mt.Fields) > 0 {
+		return []metric.Metric{}, nil
+	}
+	return []metric.Metric{pruned}, nil
+}
+
+func (proc *filterProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, nil
+}
+
+// pass tells whether the metric passes the name, tag and field rules
+func (proc *filterProcessor) pass(mt metric.Metric) bool {
+	params := proc.params
+	if len(params.namePass) > 0 && !params.namePass.match(mt.Name) {
+		return false
+	}
+	if params.nameDrop.match(mt.Name) {
+		return false
+	}
+
+	if len(params.tagPass) > 0 && !matchTags(mt.Tags, params.tagPass) {
+		return false
+	}
+	if matchTags(mt.Tags, params.tagDrop) {
+		return false
+	}
+
+	if len(params.fieldPresent) > 0 && !matchFieldKeys(mt.Fields, params.fieldPresent) {
+		return false
+	}
+	if matchFieldKeys(mt.Fields, params.fieldAbsent) {
+		return false
+	}
+
+	return true
+}
+
+// prune removes the tags and the fields by the include and exclude rules
+func (proc *filterProcessor) prune(mt metric.Metric) metric.Metric {
+	params := proc.params
+	if len(params.tagInclude) > 0 || len(params.tagExclude) > 0 {
+		tags := make([]metric.Tag, 0, len(mt.Tags))
+		for _, tag := range mt.Tags {
+			if len(params.tagInclude) > 0 && !params.tagInclude.match(tag.Key) {
+				continue
+			}
+			if params.tagExclude.match(tag.Key) {
+				continue
+			}
+			tags = append(tags, tag)
+		}
+		mt.Tags = tags
+	}
+
+	if len(params.fieldPass) > 0 || len(params.fieldDrop) > 0 {
+		fields := make([]metric.Field, 0, len(mt.Fields))
+		for _, field := range mt.Fields {
+			if len(params.fieldPass) > 0 && !params.fieldPass.match(field.Key) {
+				continue
+			}
+			if params.fieldDrop.match(field.Key) {
+				continue
+			}
+			fields = append(fields, field)
+		}
+		mt.Fields = fields
+	}
+
+	return mt
+}
+
+// matchTags tells whether any tag has a value matching the patterns of its key
+func matchTags(tags []metric.Tag, patterns map[string]patternList) bool {
+	for _, tag := range tags {
+		if values, ok := patterns[tag.Key]; ok && values.match(tag.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchFieldKeys(fields []metric.Field, patterns patternList) bool {
+	for _, field := range fields {
+		if patterns.match(field.Key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/expr"
+)
+
+// FilterConfig passes or drops metrics and prunes their tags and fields
+// All patterns are globs, or regular expressions enclosed in slashes like "/^cpu[0-9]+$/"
+type FilterConfig struct {
+	// NamePass passes only the metrics whose names match any pattern
+	NamePass []string `json:"namePass"`
+	// NameDrop drops the metrics whose names match any pattern
+	NameDrop []string `json:"nameDrop"`
+	// TagPass maps tag keys to value patterns, it passes only the metrics having any matching tag
+	TagPass map[string][]string `json:"tagPass"`
+	// TagDrop maps tag keys to value patterns, it drops the metrics having any matching tag
+	TagDrop map[string][]string `json:"tagDrop"`
+	// FieldPresent passes only the metrics having a field whose key matches any pattern
+	FieldPresent []string `json:"fieldPresent"`
+	// FieldAbsent drops the metrics having a field whose key matches any pattern
+	FieldAbsent []string `json:"fieldAbsent"`
+	// Expression passes only the metrics for which the condition is true
+	// e.g. name == "cpu" && tags.host =~ "^web-" && fields.usage > 90, see the expr package
+	Expression string `json:"expression"`
+
+	// TagInclude keeps only the tags whose keys match any pattern
+	TagInclude []string `json:"tagInclude"`
+	// TagExclude removes the tags whose keys match any pattern
+	TagExclude []string `json:"tagExclude"`
+	// FieldPass keeps only the fields whose keys match any pattern
+	FieldPass []string `json:"fieldPass"`
+	// FieldDrop removes the fields whose keys match any pattern
+	// Metrics left without fields are dropped
+	FieldDrop []string `json:"fieldDrop"`
+
+	namePass, nameDrop        patternList
+	tagPass, tagDrop          map[string]patternList
+	fieldPresent, fieldAbsent patternList
+	tagInclude, tagExclude    patternList
+	fieldPass, fieldDrop      patternList
+	expression                *expr.Program
+}
+
+var _ config.CustomConfig = (*FilterConfig)(nil)
+
+func NewFilterConfig() config.CustomConfig {
+	return &FilterConfig{}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeFilter, NewFilterConfig)
+}
+
+func (cfg *FilterConfig) Validate() error {
+	var err error
+	lists := []struct {
+		strs     []string
+		patterns *patternList
+	}{
+		{cfg.NamePass, &cfg.namePass},
+		{cfg.NameDrop, &cfg.nameDrop},
+		{cfg.FieldPresent, &cfg.fieldPresent},
+		{cfg.FieldAbsent, &cfg.fieldAbsent},
+		{cfg.TagInclude, &cfg.tagInclude},
+		{cfg.TagExclude, &cfg.tagExclude},
+		{cfg.FieldPass, &cfg.fieldPass},
+		{cfg.FieldDrop, &cfg.fieldDrop},
+	}
+	for _, list := range lists {
+		*list.patterns, err = compilePatterns(list.strs)
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg.tagPass, err = compileTagPatterns(cfg.TagPass)
+	if err != nil {
+		return err
+	}
+	cfg.tagDrop, err = compileTagPatterns(cfg.TagDrop)
+	if err != nil {
+		return err
+	}
+
+	cfg.expression = nil
+	if cfg.Expression != "" {
+		cfg.expression, err = expr.CompileCondition(cfg.Expression)
+		if err != nil {
+			return fmt.Errorf("invalid expression \"%s\": %w", cfg.Expression, err)
+		}
+	}
+	return nil
+}
+
+func compileTagPatterns(tags map[string][]string) (map[string]patternList, error) {
+	compiled := make(map[string]patternList, len(tags))
+	for key, strs := range tags {
+		patterns, err := compilePatterns(strs)
+		if err != nil {
+			return nil, err
+		}
+		compiled[key] = patterns
+	}
+	return compiled, nil
+}
+
+func (cfg *FilterConfig) UnmarshalJSON(data []byte) error {
+	type plain FilterConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
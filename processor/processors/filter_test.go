@@ -0,0 +1,165 @@
+package processors
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestCompilePattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		str     string
+		match   bool
+	}{
+		{"cpu*", "cpu_usage", true},
+		{"cpu*", "mycpu", false},
+		{"cpu?", "cpu0", true},
+		{"cpu?", "cpu10", false},
+		{"/var/*", "/var/log/syslog", true},
+		{"disk[0-9]", "disk3", true},
+		{"disk[!0-9]", "disk3", false},
+		{"a.b", "axb", false},
+		{"température*", "température_c", true},
+		{"/^web-[0-9]+$/", "web-01", true},
+		{"/web/", "my-web-01", true},
+		{"/^web$/", "web-01", false},
+	}
+	for _, c := range cases {
+		re, err := compilePattern(c.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if re.MatchString(c.str) != c.match {
+			t.Errorf("pattern \"%s\" matching \"%s\" should be %v", c.pattern, c.str, c.match)
+		}
+	}
+
+	for _, pattern := range []string{"disk[0-9", "/web(/"} {
+		if _, err := compilePattern(pattern); err == nil {
+			t.Errorf("pattern \"%s\" should be invalid", pattern)
+		}
+	}
+}
+
+func TestFilterPassAndDrop(t *testing.T) {
+	metrics := []metric.Metric{
+		{Name: "cpu", Tags: []metric.Tag{{Key: "host", Value: "web-01"}}, Fields: []metric.Field{{Key: "usage", Value: 10}}},
+		{Name: "cpu", Tags: []metric.Tag{{Key: "host", Value: "db-01"}}, Fields: []metric.Field{{Key: "usage", Value: 20}}},
+		{Name: "mem", Tags: []metric.Tag{{Key: "host", Value: "web-02"}}, Fields: []metric.Field{{Key: "free", Value: 30}}},
+		{Name: "disk", Tags: []metric.Tag{{Key: "path", Value: "/var"}}, Fields: []metric.Field{{Key: "used", Value: 40}, {Key: "inodes", Value: 1}}},
+	}
+
+	cases := []struct {
+		params string
+		passed []int
+	}{
+		{`{}`, []int{0, 1, 2, 3}},
+		{`{"namePass": ["cpu", "/^m/"]}`, []int{0, 1, 2}},
+		{`{"nameDrop": ["c*"]}`, []int{2, 3}},
+		{`{"tagPass": {"host": ["web-*"], "path": ["/var"]}}`, []int{0, 2, 3}},
+		{`{"tagDrop": {"host": ["/db/"]}}`, []int{0, 2, 3}},
+		{`{"fieldPresent": ["inodes", "free"]}`, []int{2, 3}},
+		{`{"fieldAbsent": ["usage"]}`, []int{2, 3}},
+		{`{"namePass": ["cpu"], "tagDrop": {"host": ["db-*"]}}`, []int{0}},
+		{`{"expression": "name == \"cpu\" && tags.host =~ \"^web-\" || fields.used > 30"}`, []int{0, 3}},
+		{`{"namePass": ["cpu"], "expression": "fields.usage >= 20"}`, []int{1}},
+	}
+	for _, c := range cases {
+		proc := newTestProcessor(t, ProcessorTypeFilter, c.params).(*filterProcessor)
+		passed := []int{}
+		for i, mt := range metrics {
+			out, err := proc.OnReceive(mt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out == nil {
+				t.Fatalf("dropped metrics should produce an empty slice: %s", c.params)
+			}
+			if len(out) == 1 {
+				passed = append(passed, i)
+			}
+		}
+		if !reflect.DeepEqual(passed, c.passed) {
+			t.Errorf("params %s passed %v, expected %v", c.params, passed, c.passed)
+		}
+	}
+}
+
+func TestFilterPrune(t *testing.T) {
+	mt := metric.Metric{
+		Name:   "net",
+		Tags:   []metric.Tag{{Key: "host", Value: "web-01"}, {Key: "interface", Value: "eth0"}, {Key: "dc", Value: "eu"}},
+		Fields: []metric.Field{{Key: "bytes_recv", Value: 1}, {Key: "bytes_sent", Value: 2}, {Key: "err_in", Value: 3}},
+	}
+
+	proc := newTestProcessor(t, ProcessorTypeFilter, `{
+		"tagInclude": ["host", "interface"],
+		"tagExclude": ["interface"],
+		"fieldPass": ["bytes_*", "err_*"],
+		"fieldDrop": ["/sent$/"]
+	}`).(*filterProcessor)
+	out, err := proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatal("metric should pass")
+	}
+	if !reflect.DeepEqual(out[0].Tags, []metric.Tag{{Key: "host", Value: "web-01"}}) {
+		t.Errorf("invalid tags: %v", out[0].Tags)
+	}
+	if !reflect.DeepEqual(out[0].Fields, []metric.Field{{Key: "bytes_recv", Value: 1}, {Key: "err_in", Value: 3}}) {
+		t.Errorf("invalid fields: %v", out[0].Fields)
+	}
+	if len(mt.Tags) != 3 || len(mt.Fields) != 3 {
+		t.Error("the received metric should not be modified")
+	}
+
+	proc = newTestProcessor(t, ProcessorTypeFilter, `{"fieldDrop": ["*"]}`).(*filterProcessor)
+	out, err = proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || len(out) != 0 {
+		t.Errorf("metric without fields should be dropped: %v", out)
+	}
+
+	proc = newTestProcessor(t, ProcessorTypeFilter, `{"tagExclude": ["dc"]}`).(*filterProcessor)
+	out, err = proc.OnReceive(metric.Metric{Name: "event", Tags: mt.Tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || len(out[0].Tags) != 2 {
+		t.Errorf("metric received without fields should pass: %v", out)
+	}
+}
+
+func TestFilterConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{"namePass": ["cpu["]}`,
+		`{"tagPass": {"host": ["/(/"]}}`,
+		`{"fieldDrop": ["/[/"]}`,
+		`{"expression": "name =="}`,
+		`{"expression": "tags.host"}`,
+	} {
+		cfg := NewFilterConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
+
+func TestFilterExpressionError(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeFilter, `{"expression": "fields.usage > 10"}`).(*filterProcessor)
+	mt := metric.Metric{Name: "cpu", Fields: []metric.Field{{Key: "usage", Value: "high"}}}
+	if _, err := proc.OnReceive(mt); err == nil {
+		t.Error("comparing a string field with a number should fail")
+	}
+}
@@ -0,0 +1,244 @@
+package processors
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeGraphiteOutput = "graphite_output"
+)
+
+var (
+	// graphitePathSanitizer replaces the characters that would break a plaintext line or a path node
+	graphitePathSanitizer = strings.NewReplacer(" ", "_", "\t", "_", "\n", "_", ".", "_", ";", "_", "=", "_", "/", "_")
+	// graphiteTagSanitizer keeps dots which are valid in the values of tagged series
+	graphiteTagSanitizer = strings.NewReplacer(" ", "_", "\t", "_", "\n", "_", ";", "_", "=", "_", "~", "_")
+	// graphiteFallbackTemplate is used for metrics matched by none of the templates
+	graphiteFallbackTemplate = &graphiteTemplate{tokens: []string{"tags", "measurement", "field"}}
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeGraphiteOutput, NewGraphiteOutputProcessor)
+}
+
+// graphiteTemplate builds a dot separated metric path from the name, a field and the tags of a metric
+type graphiteTemplate struct {
+	filter string
+	tokens []string
+}
+
+func parseGraphiteTemplate(str string) (*graphiteTemplate, error) {
+	parts := strings.Fields(str)
+	switch len(parts) {
+	case 1:
+		return &graphiteTemplate{tokens: strings.Split(parts[0], ".")}, nil
+	case 2:
+		if _, err := path.Match(parts[0], ""); err != nil {
+			return nil, fmt.Errorf("invalid template filter \"%s\": %v", parts[0], err)
+		}
+		return &graphiteTemplate{filter: parts[0], tokens: strings.Split(parts[1], ".")}, nil
+	default:
+		return nil, fmt.Errorf("invalid template: \"%s\"", str)
+	}
+}
+
+func (tmpl *graphiteTemplate) match(name string) bool {
+	if tmpl.filter == "" {
+		return true
+	}
+	matched, _ := path.Match(tmpl.filter, name)
+	return matched
+}
+
+// apply returns the path of a field of the metric
+// Missing tags and the field named "value" are left out of the path
+func (tmpl *graphiteTemplate) apply(mt metric.Metric, field string) string {
+	used := map[string]bool{}
+	for _, token := range tmpl.tokens {
+		used[token] = true
+	}
+
+	nodes := []string{}
+	for _, token := range tmpl.tokens {
+		switch token {
+		case "":
+		case "measurement":
+			nodes = append(nodes, graphitePathSanitizer.Replace(mt.Name))
+		case "field":
+			if field != "value" {
+				nodes = append(nodes, graphitePathSanitizer.Replace(field))
+			}
+		case "tags":
+			tags := make([]metric.Tag, 0, len(mt.Tags))
+			for _, tag := range mt.Tags {
+				if !used[tag.Key] && tag.Value != "" {
+					tags = append(tags, tag)
+				}
+			}
+			sort.Slice(tags, func(i, j int) bool {
+				return tags[i].Key < tags[j].Key
+			})
+			for _, tag := range tags {
+				nodes = append(nodes, graphitePathSanitizer.Replace(tag.Value))
+			}
+		default:
+			if value, err := mt.GetTag(token); err == nil && value != "" {
+				nodes = append(nodes, graphitePathSanitizer.Replace(value))
+			}
+		}
+	}
+	return strings.Join(nodes, ".")
+}
+
+type graphiteOutputProcessor struct {
+	cfg     *config.ProcessorConfig
+	params  *GraphiteOutputConfig
+	sender  *tcpSender
+	batcher *batcher
+}
+
+var _ processor.BatchProcessor = (*graphiteOutputProcessor)(nil)
+
+// NewGraphiteOutputProcessor creates a new Graphite plaintext output processor
+func NewGraphiteOutputProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*GraphiteOutputConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for graphite output processor: %T", cfg.Params)
+	}
+
+	proc := &graphiteOutputProcessor{
+		cfg:    cfg,
+		params: params,
+	}
+	size, _ := processor.BatchLimits(cfg)
+	proc.batcher = newBatcher(cfg.Name, &params.BatchingConfig, size, proc.send)
+	return proc, nil
+}
+
+func (proc *graphiteOutputProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *graphiteOutputProcessor) Setup(emitter processor.Emitter) error {
+	proc.batcher.setup(emitter)
+	proc.sender = newTCPSender(proc.cfg.Name, proc.params.Address, time.Duration(proc.params.Timeout))
+	return nil
+}
+
+func (proc *graphiteOutputProcessor) Close() error {
+	err := proc.batcher.flush()
+	proc.sender.close()
+	return err
+}
+
+func (proc *graphiteOutputProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.OnReceiveBatch([]metric.Metric{mt})
+}
+
+func (proc *graphiteOutputProcessor) OnReceiveBatch(metrics []metric.Metric) ([]metric.Metric, error) {
+	return nil, proc.batcher.add(metrics)
+}
+
+func (proc *graphiteOutputProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, proc.batcher.flush()
+}
+
+func (proc *graphiteOutputProcessor) send(batch []metric.Metric) error {
+	var buf bytes.Buffer
+	for _, mt := range batch {
+		for _, line := range proc.serialize(mt) {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return proc.sender.write(buf.Bytes())
+}
+
+// serialize converts every numeric or boolean field of a metric to a plaintext line
+func (proc *graphiteOutputProcessor) serialize(mt metric.Metric) []string {
+	timestamp := mt.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	suffix := " " + strconv.FormatInt(timestamp.Unix(), 10)
+
+	var tmpl *graphiteTemplate
+	if !proc.params.TaggedSyntax {
+		for _, t := range proc.params.templates {
+			if t.match(mt.Name) {
+				tmpl = t
+				break
+			}
+		}
+		if tmpl == nil {
+			tmpl = graphiteFallbackTemplate
+		}
+	}
+
+	lines := []string{}
+	for _, field := range mt.Fields {
+		value, ok := formatTCPOutputValue(field.Value)
+		if !ok {
+			continue
+		}
+
+		var p string
+		if tmpl != nil {
+			p = tmpl.apply(mt, field.Key)
+		} else {
+			p = proc.taggedPath(mt, field.Key)
+		}
+		if p == "" {
+			continue
+		}
+		if proc.params.Prefix != "" {
+			p = proc.params.Prefix + "." + p
+		}
+		lines = append(lines, p+" "+value+suffix)
+	}
+	return lines
+}
+
+// taggedPath returns the path of a field in the tagged syntax, e.g. "cpu.usage;host=web01"
+func (proc *graphiteOutputProcessor) taggedPath(mt metric.Metric, field string) string {
+	var builder strings.Builder
+	builder.WriteString(graphiteTagSanitizer.Replace(mt.Name))
+	if field != "value" {
+		builder.WriteByte('.')
+		builder.WriteString(graphiteTagSanitizer.Replace(field))
+	}
+
+	tags := make([]metric.Tag, len(mt.Tags))
+	copy(tags, mt.Tags)
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	for _, tag := range tags {
+		if tag.Key == "" || tag.Value == "" {
+			continue
+		}
+		builder.WriteByte(';')
+		builder.WriteString(graphiteTagSanitizer.Replace(tag.Key))
+		builder.WriteByte('=')
+		builder.WriteString(graphiteTagSanitizer.Replace(tag.Value))
+	}
+	return builder.String()
+}
@@ -0,0 +1,63 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/expinc/melegraf/config"
+)
+
+type GraphiteOutputConfig struct {
+	// Address of the Graphite plaintext receiver, e.g. "localhost:2003"
+	Address string `json:"address"`
+	// Prefix is prepended to every metric path
+	Prefix string `json:"prefix"`
+	// Templates build metric paths from metrics, e.g. "cpu* host.measurement.field"
+	// The first template whose optional name filter matches is used, or "tags.measurement.field" if none
+	// Tokens are "measurement", "field", "tags" for all tags not used by the template, or tag keys
+	Templates []string `json:"templates"`
+	// TaggedSyntax writes "measurement.field;tag=value" paths of Graphite 1.1 instead of templates
+	TaggedSyntax bool `json:"taggedSyntax"`
+	// BatchingConfig contains timeout, bufferLimit, overflowPolicy and retry options
+	BatchingConfig
+
+	templates []*graphiteTemplate
+}
+
+var _ config.CustomConfig = (*GraphiteOutputConfig)(nil)
+
+func NewGraphiteOutputConfig() config.CustomConfig {
+	return &GraphiteOutputConfig{
+		Address:        "localhost:2003",
+		Templates:      []string{"host.tags.measurement.field"},
+		BatchingConfig: defaultBatchingConfig(),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeGraphiteOutput, NewGraphiteOutputConfig)
+}
+
+func (cfg *GraphiteOutputConfig) Validate() error {
+	if strings.TrimSpace(cfg.Address) == "" {
+		return errors.New("address is required")
+	}
+
+	templates := make([]*graphiteTemplate, 0, len(cfg.Templates))
+	for _, str := range cfg.Templates {
+		tmpl, err := parseGraphiteTemplate(str)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, tmpl)
+	}
+	cfg.templates = templates
+
+	return cfg.BatchingConfig.Validate()
+}
+
+func (cfg *GraphiteOutputConfig) UnmarshalJSON(data []byte) error {
+	type plain GraphiteOutputConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
@@ -0,0 +1,255 @@
+package processors
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// fakeLineServer records the lines received over TCP
+type fakeLineServer struct {
+	sync.Mutex
+	listener net.Listener
+	conns    []net.Conn
+	lines    []string
+}
+
+func newFakeLineServer(t *testing.T, address string) *fakeLineServer {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &fakeLineServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.Lock()
+			server.conns = append(server.conns, conn)
+			server.Unlock()
+
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					server.Lock()
+					server.lines = append(server.lines, scanner.Text())
+					server.Unlock()
+				}
+			}()
+		}
+	}()
+	return server
+}
+
+// dropConnections closes the accepted connections
+func (server *fakeLineServer) dropConnections() {
+	server.Lock()
+	defer server.Unlock()
+	for _, conn := range server.conns {
+		conn.Close()
+	}
+	server.conns = nil
+}
+
+func (server *fakeLineServer) close() {
+	server.listener.Close()
+	server.dropConnections()
+}
+
+// waitLines waits until the number of received lines reaches count and returns them
+func (server *fakeLineServer) waitLines(t *testing.T, count int) []string {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		server.Lock()
+		lines := append([]string{}, server.lines...)
+		server.Unlock()
+		if len(lines) >= count {
+			return lines
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d lines, expected %d: %v", len(lines), count, lines)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGraphiteOutputTemplates(t *testing.T) {
+	server := newFakeLineServer(t, "127.0.0.1:0")
+	defer server.close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "graphite",
+		"type": "graphite_output",
+		"params": {
+			"address": "%s",
+			"prefix": "melegraf",
+			"templates": ["disk* host.measurement.path.field", "host.tags.measurement.field"]
+		}
+	}`, server.listener.Addr())).(*graphiteOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	metrics := []metric.Metric{
+		{
+			Name:   "disk",
+			Tags:   []metric.Tag{{Key: "host", Value: "web01.example"}, {Key: "path", Value: "/var"}},
+			Fields: []metric.Field{{Key: "used", Value: 42}, {Key: "mount", Value: "rw"}},
+			Time:   time.Unix(1700000000, 0),
+		},
+		{
+			Name:   "cpu",
+			Tags:   []metric.Tag{{Key: "host", Value: "web01"}, {Key: "dc", Value: "eu"}, {Key: "core", Value: "0"}},
+			Fields: []metric.Field{{Key: "value", Value: 12.5}, {Key: "busy", Value: true}},
+			Time:   time.Unix(1700000000, 0),
+		},
+	}
+	_, err = proc.OnReceiveBatch(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := server.waitLines(t, 3)
+	expected := []string{
+		"melegraf.web01_example.disk._var.used 42 1700000000",
+		"melegraf.web01.0.eu.cpu 12.5 1700000000",
+		"melegraf.web01.0.eu.cpu.busy 1 1700000000",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("invalid line %d: %s", i, lines[i])
+		}
+	}
+}
+
+func TestGraphiteOutputTaggedSyntax(t *testing.T) {
+	server := newFakeLineServer(t, "127.0.0.1:0")
+	defer server.close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "graphite",
+		"type": "graphite_output",
+		"params": {
+			"address": "%s",
+			"taggedSyntax": true
+		}
+	}`, server.listener.Addr())).(*graphiteOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	_, err = proc.OnReceive(metric.Metric{
+		Name:   "cpu",
+		Tags:   []metric.Tag{{Key: "host", Value: "web01.example"}, {Key: "dc", Value: "eu west"}},
+		Fields: []metric.Field{{Key: "usage", Value: 3}},
+		Time:   time.Unix(1700000000, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := server.waitLines(t, 1)
+	if lines[0] != "cpu.usage;dc=eu_west;host=web01.example 3 1700000000" {
+		t.Errorf("invalid tagged line: %s", lines[0])
+	}
+}
+
+func TestGraphiteOutputReconnect(t *testing.T) {
+	server := newFakeLineServer(t, "127.0.0.1:0")
+	address := server.listener.Addr().String()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "graphite",
+		"type": "graphite_output",
+		"params": {
+			"address": "%s",
+			"templates": ["measurement.field"],
+			"maxRetries": 1,
+			"retryInitialInterval": "1ms",
+			"retryMaxInterval": "1ms"
+		}
+	}`, address)).(*graphiteOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	proc.OnReceive(newTestMetric("first", 1))
+	_, err = proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.waitLines(t, 1)
+
+	// the connection lost while idle is detected before the next write
+	server.dropConnections()
+	time.Sleep(50 * time.Millisecond)
+	proc.OnReceive(newTestMetric("second", 2))
+	_, err = proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.waitLines(t, 2)
+
+	// metrics are kept in the buffer while the server is down and resent after it is back
+	server.close()
+	time.Sleep(50 * time.Millisecond)
+	proc.OnReceive(newTestMetric("third", 3))
+	_, err = proc.OnCronTrigger()
+	if err == nil {
+		t.Fatal("write should fail while the server is down")
+	}
+	if len(proc.batcher.buffer) != 1 {
+		t.Fatalf("failed metrics should be kept in the buffer: %d", len(proc.batcher.buffer))
+	}
+
+	restarted := newFakeLineServer(t, address)
+	defer restarted.close()
+	_, err = proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := restarted.waitLines(t, 1)
+	if lines[0] != "third 3 0" {
+		t.Errorf("invalid resent line: %s", lines[0])
+	}
+}
+
+func TestGraphiteOutputConfigInvalid(t *testing.T) {
+	cfgs := []string{
+		`{"address": ""}`,
+		`{"address": "localhost:2003", "templates": ["cpu* host.measurement extra"]}`,
+		`{"address": "localhost:2003", "templates": ["[ host.measurement"]}`,
+	}
+	for _, cfgStr := range cfgs {
+		cfg := NewGraphiteOutputConfig()
+		err := json.Unmarshal([]byte(cfgStr), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", cfgStr)
+		}
+	}
+}
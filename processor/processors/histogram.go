@@ -0,0 +1,157 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeHistogram = "histogram"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeHistogram, NewHistogramProcessor)
+}
+
+// histogramCounts holds the counts of the values in every bucket, the last bucket is +Inf
+type histogramCounts struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// histogramSeries holds the histograms of the fields of a series
+type histogramSeries struct {
+	name       string
+	tags       []metric.Tag
+	keys       []string
+	histograms map[string]*histogramCounts
+}
+
+type histogramProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *HistogramConfig
+	series map[string]*histogramSeries
+}
+
+var _ processor.Processor = (*histogramProcessor)(nil)
+
+// NewHistogramProcessor creates a new histogram processor
+func NewHistogramProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*HistogramConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for histogram processor: %T", cfg.Params)
+	}
+
+	return &histogramProcessor{
+		cfg:    cfg,
+		params: params,
+		series: make(map[string]*histogramSeries),
+	}, nil
+}
+
+func (proc *histogramProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *histogramProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *histogramProcessor) Close() error {
+	return nil
+}
+
+func (proc *histogramProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	proc.add(mt)
+	if proc.params.DropOriginal {
+		return []metric.Metric{}, nil
+	}
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *histogramProcessor) add(mt metric.Metric) {
+	var series *histogramSeries
+	for _, field := range mt.Fields {
+		value, ok := numericValue(field.Value)
+		if !ok || math.IsNaN(value) || (len(proc.params.fields) > 0 && !proc.params.fields.match(field.Key)) {
+			continue
+		}
+
+		if series == nil {
+			key := mt.SeriesKey()
+			series = proc.series[key]
+			if series == nil {
+				tags := make([]metric.Tag, len(mt.Tags))
+				copy(tags, mt.Tags)
+				series = &histogramSeries{name: mt.Name, tags: tags, histograms: make(map[string]*histogramCounts)}
+				proc.series[key] = series
+			}
+		}
+		histogram, ok := series.histograms[field.Key]
+		if !ok {
+			histogram = &histogramCounts{buckets: make([]uint64, len(proc.params.bounds)+1)}
+			series.histograms[field.Key] = histogram
+			series.keys = append(series.keys, field.Key)
+		}
+
+		// the first bucket whose upper bound is not less than the value
+		histogram.buckets[sort.SearchFloat64s(proc.params.bounds, value)]++
+		histogram.sum += value
+		histogram.count++
+	}
+}
+
+func (proc *histogramProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return proc.flush(time.Now()), nil
+}
+
+// flush emits the histograms ordered by series key, and resets them unless they are accumulated
+func (proc *histogramProcessor) flush(now time.Time) []metric.Metric {
+	var out []metric.Metric
+	for _, key := range sortedKeys(proc.series) {
+		series := proc.series[key]
+		for _, field := range series.keys {
+			histogram := series.histograms[field]
+			tags := make([]metric.Tag, len(series.tags))
+			copy(tags, series.tags)
+			mt := metric.Metric{
+				Name:   series.name + "_" + field,
+				Tags:   setTag(tags, prometheusTypeTag, prometheusTypeHistogram),
+				Fields: make([]metric.Field, 0, len(histogram.buckets)+2),
+				Time:   now,
+			}
+
+			var cumulative uint64
+			for i, count := range histogram.buckets {
+				cumulative += count
+				bound := "+Inf"
+				if i < len(proc.params.bounds) {
+					bound = formatPrometheusValue(proc.params.bounds[i])
+				}
+				mt.Fields = append(mt.Fields, metric.Field{Key: bound, Value: float64(cumulative)})
+			}
+			mt.Fields = append(mt.Fields,
+				metric.Field{Key: "sum", Value: histogram.sum},
+				metric.Field{Key: "count", Value: float64(histogram.count)},
+			)
+			out = append(out, mt)
+		}
+	}
+
+	if !proc.params.Accumulate {
+		proc.series = make(map[string]*histogramSeries)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/expinc/melegraf/config"
+)
+
+// HistogramConfig counts the values of the numeric fields of every series into buckets between cron triggers
+// On every trigger a prometheus histogram is emitted per series and field, named "<name>_<field>"
+// with the tag metric_type=histogram, the cumulative counts of the buckets keyed by their upper bounds
+// like "0.5" and "+Inf", and the fields "sum" and "count"
+type HistogramConfig struct {
+	// Buckets are the increasing upper bounds of the buckets
+	Buckets []float64 `json:"buckets"`
+	// Linear generates the bounds start, start + width, ... instead of buckets
+	Linear *HistogramLinearBuckets `json:"linear"`
+	// Exponential generates the bounds start, start * factor, ... instead of buckets
+	Exponential *HistogramExponentialBuckets `json:"exponential"`
+	// Fields are patterns of the counted field keys, all numeric fields if empty, NaN values are skipped
+	Fields []string `json:"fields"`
+	// Accumulate keeps counting across triggers instead of resetting the buckets after each trigger
+	Accumulate bool `json:"accumulate"`
+	// DropOriginal drops the received metrics instead of passing them through
+	DropOriginal bool `json:"dropOriginal"`
+
+	bounds []float64
+	fields patternList
+}
+
+type HistogramLinearBuckets struct {
+	Start float64 `json:"start"`
+	Width float64 `json:"width"`
+	Count int     `json:"count"`
+}
+
+type HistogramExponentialBuckets struct {
+	Start  float64 `json:"start"`
+	Factor float64 `json:"factor"`
+	Count  int     `json:"count"`
+}
+
+var _ config.CustomConfig = (*HistogramConfig)(nil)
+
+func NewHistogramConfig() config.CustomConfig {
+	return &HistogramConfig{}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeHistogram, NewHistogramConfig)
+}
+
+func (cfg *HistogramConfig) Validate() error {
+	generators := 0
+	for _, defined := range []bool{len(cfg.Buckets) > 0, cfg.Linear != nil, cfg.Exponential != nil} {
+		if defined {
+			generators++
+		}
+	}
+	if generators != 1 {
+		return fmt.Errorf("exactly one of buckets, linear and exponential is required")
+	}
+
+	bounds := cfg.Buckets
+	switch {
+	case cfg.Linear != nil:
+		if cfg.Linear.Width <= 0 || cfg.Linear.Count <= 0 {
+			return fmt.Errorf("linear buckets require a positive width and count")
+		}
+		bounds = make([]float64, cfg.Linear.Count)
+		for i := range bounds {
+			bounds[i] = cfg.Linear.Start + float64(i)*cfg.Linear.Width
+		}
+	case cfg.Exponential != nil:
+		if cfg.Exponential.Start <= 0 || cfg.Exponential.Factor <= 1 || cfg.Exponential.Count <= 0 {
+			return fmt.Errorf("exponential buckets require a positive start, a factor greater than 1 and a positive count")
+		}
+		bounds = make([]float64, cfg.Exponential.Count)
+		for i := range bounds {
+			bounds[i] = cfg.Exponential.Start * math.Pow(cfg.Exponential.Factor, float64(i))
+		}
+	}
+
+	for i, bound := range bounds {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("invalid bucket bound: %v", bound)
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return fmt.Errorf("bucket bounds must be increasing: %v", bounds)
+		}
+	}
+	cfg.bounds = bounds
+
+	var err error
+	cfg.fields, err = compilePatterns(cfg.Fields)
+	return err
+}
+
+func (cfg *HistogramConfig) UnmarshalJSON(data []byte) error {
+	type plain HistogramConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
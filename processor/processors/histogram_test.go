@@ -0,0 +1,116 @@
+package processors
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeHistogram, `{"buckets": [0.1, 0.5, 1], "fields": ["duration"]}`).(*histogramProcessor)
+	// NaN values are not counted
+	for _, value := range []interface{}{0.05, 0.1, 0.3, int64(1), 2.5, math.NaN(), 0.7} {
+		mt := newTestMetric("http", "ok")
+		mt.Fields = append(mt.Fields, metric.Field{Key: "duration", Value: value})
+		out, err := proc.OnReceive(mt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 {
+			t.Fatal("metrics should be passed through")
+		}
+	}
+
+	now := time.Unix(100, 0)
+	out := proc.flush(now)
+	expected := []metric.Metric{{
+		Name: "http_duration",
+		Tags: []metric.Tag{{Key: "host", Value: "web01"}, {Key: prometheusTypeTag, Value: prometheusTypeHistogram}},
+		Fields: []metric.Field{
+			{Key: "0.1", Value: 2.0},
+			{Key: "0.5", Value: 3.0},
+			{Key: "1", Value: 5.0},
+			{Key: "+Inf", Value: 6.0},
+			{Key: "sum", Value: 0.05 + 0.1 + 0.3 + 1 + 2.5 + 0.7},
+			{Key: "count", Value: 6.0},
+		},
+		Time: now,
+	}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %v but got %v", expected, out)
+	}
+
+	if out := proc.flush(now); len(out) != 0 {
+		t.Errorf("histograms should be reset after flush: %v", out)
+	}
+}
+
+func TestHistogramAccumulate(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeHistogram, `{"linear": {"start": 10, "width": 10, "count": 2}, "accumulate": true, "dropOriginal": true}`).(*histogramProcessor)
+
+	out, err := proc.OnReceive(newTestMetric("queue", 15))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || len(out) != 0 {
+		t.Fatalf("metrics should be dropped: %v", out)
+	}
+	proc.flush(time.Unix(0, 0))
+
+	proc.OnReceive(newTestMetric("queue", 5))
+	out = proc.flush(time.Unix(0, 0))
+	if len(out) != 1 {
+		t.Fatalf("expected 1 metric but got %v", out)
+	}
+	expected := []metric.Field{
+		{Key: "10", Value: 1.0},
+		{Key: "20", Value: 2.0},
+		{Key: "+Inf", Value: 2.0},
+		{Key: "sum", Value: 20.0},
+		{Key: "count", Value: 2.0},
+	}
+	if out[0].Name != "queue_value" || !reflect.DeepEqual(out[0].Fields, expected) {
+		t.Errorf("expected the accumulated fields %v but got %v", expected, out[0])
+	}
+}
+
+func TestHistogramGeneratedBounds(t *testing.T) {
+	cfg := NewHistogramConfig().(*HistogramConfig)
+	err := json.Unmarshal([]byte(`{"exponential": {"start": 0.5, "factor": 2, "count": 4}}`), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.bounds, []float64{0.5, 1, 2, 4}) {
+		t.Errorf("invalid exponential bounds: %v", cfg.bounds)
+	}
+}
+
+func TestHistogramConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{}`,
+		`{"buckets": [1], "linear": {"start": 0, "width": 1, "count": 2}}`,
+		`{"buckets": [1, 1]}`,
+		`{"buckets": [2, 1]}`,
+		`{"linear": {"start": 0, "width": 0, "count": 2}}`,
+		`{"exponential": {"start": 0, "factor": 2, "count": 2}}`,
+		`{"exponential": {"start": 1, "factor": 1, "count": 2}}`,
+		`{"exponential": {"start": 1, "factor": 10, "count": 400}}`,
+		`{"buckets": [1], "fields": ["/(/"]}`,
+	} {
+		cfg := NewHistogramConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/expinc/melegraf/serializer"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypeHTTPOutput = "http_output"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeHTTPOutput, NewHTTPOutputProcessor)
+}
+
+type httpOutputProcessor struct {
+	cfg        *config.ProcessorConfig
+	params     *HTTPOutputConfig
+	serializer serializer.Serializer
+	client     *http.Client
+	batcher    *batcher
+}
+
+var _ processor.BatchProcessor = (*httpOutputProcessor)(nil)
+
+// NewHTTPOutputProcessor creates a new HTTP output processor
+func NewHTTPOutputProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*HTTPOutputConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for http output processor: %T", cfg.Params)
+	}
+
+	s, err := serializer.NewSerializer(params.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	proc := &httpOutputProcessor{
+		cfg:        cfg,
+		params:     params,
+		serializer: s,
+	}
+	size, _ := processor.BatchLimits(cfg)
+	proc.batcher = newBatcher(cfg.Name, &params.BatchingConfig, size, proc.send)
+	return proc, nil
+}
+
+func (proc *httpOutputProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *httpOutputProcessor) Setup(emitter processor.Emitter) error {
+	proc.batcher.setup(emitter)
+	proc.client = &http.Client{Timeout: time.Duration(proc.params.Timeout)}
+	return nil
+}
+
+func (proc *httpOutputProcessor) Close() error {
+	err := proc.batcher.flush()
+	proc.client.CloseIdleConnections()
+	return err
+}
+
+func (proc *httpOutputProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.OnReceiveBatch([]metric.Metric{mt})
+}
+
+func (proc *httpOutputProcessor) OnReceiveBatch(metrics []metric.Metric) ([]metric.Metric, error) {
+	return nil, proc.batcher.add(metrics)
+}
+
+func (proc *httpOutputProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, proc.batcher.flush()
+}
+
+func (proc *httpOutputProcessor) send(batch []metric.Metric) error {
+	data, err := proc.serializer.Serialize(batch)
+	if err != nil {
+		// skip the metrics which cannot be serialized instead of dropping the whole batch
+		batch = proc.serializable(batch)
+		if len(batch) == 0 {
+			return nil
+		}
+		data, err = proc.serializer.Serialize(batch)
+		if err != nil {
+			return &sendError{err: err}
+		}
+	}
+
+	if proc.params.ContentEncoding == "gzip" {
+		data, err = gzipBody(data)
+		if err != nil {
+			return &sendError{err: err}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(proc.params.Timeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, proc.params.Method, proc.params.URL, bytes.NewReader(data))
+	if err != nil {
+		return &sendError{err: err}
+	}
+	req.Header.Set("Content-Type", proc.serializer.ContentType())
+	if proc.params.ContentEncoding == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range proc.params.Headers {
+		if http.CanonicalHeaderKey(key) == "Host" {
+			req.Host = value
+		} else {
+			req.Header.Set(key, value)
+		}
+	}
+
+	resp, err := proc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkHTTPResponse(resp)
+}
+
+// serializable returns the metrics which can be serialized one by one
+func (proc *httpOutputProcessor) serializable(batch []metric.Metric) []metric.Metric {
+	metrics := make([]metric.Metric, 0, len(batch))
+	for _, mt := range batch {
+		if _, err := proc.serializer.Serialize([]metric.Metric{mt}); err != nil {
+			logrus.Warnf("Processor \"%s\" skipped metric: %v", proc.cfg.Name, err)
+			continue
+		}
+		metrics = append(metrics, mt)
+	}
+	return metrics
+}
@@ -0,0 +1,69 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/serializer"
+)
+
+type HTTPOutputConfig struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	// Format is the name of a registered serializer
+	Format string `json:"format"`
+	// ContentEncoding is either "gzip" or "identity"
+	ContentEncoding string `json:"contentEncoding"`
+	// BatchingConfig contains timeout, bufferLimit, overflowPolicy and retry options
+	BatchingConfig
+}
+
+var _ config.CustomConfig = (*HTTPOutputConfig)(nil)
+
+func NewHTTPOutputConfig() config.CustomConfig {
+	return &HTTPOutputConfig{
+		Method:          http.MethodPost,
+		Format:          serializer.FormatInflux,
+		ContentEncoding: "gzip",
+		BatchingConfig:  defaultBatchingConfig(),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeHTTPOutput, NewHTTPOutputConfig)
+}
+
+func (cfg *HTTPOutputConfig) Validate() error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url: %s", cfg.URL)
+	}
+
+	switch cfg.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("invalid method: %s", cfg.Method)
+	}
+
+	if !serializer.IsRegistered(cfg.Format) {
+		return fmt.Errorf("invalid format: %s", cfg.Format)
+	}
+
+	if cfg.ContentEncoding != "gzip" && cfg.ContentEncoding != "identity" {
+		return fmt.Errorf("invalid content encoding: %s", cfg.ContentEncoding)
+	}
+
+	return cfg.BatchingConfig.Validate()
+}
+
+func (cfg *HTTPOutputConfig) UnmarshalJSON(data []byte) error {
+	type plain HTTPOutputConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
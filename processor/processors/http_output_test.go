@@ -0,0 +1,376 @@
+package processors
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+)
+
+// fakeCollector replies with the queued statuses and records the received bodies
+type fakeCollector struct {
+	sync.Mutex
+	statuses []int
+	headers  []http.Header
+	bodies   []string
+}
+
+func (c *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.Lock()
+	defer c.Unlock()
+
+	var body []byte
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ = ioutil.ReadAll(reader)
+	} else {
+		body, _ = ioutil.ReadAll(r.Body)
+	}
+	c.headers = append(c.headers, r.Header.Clone())
+	c.bodies = append(c.bodies, string(body))
+
+	status := http.StatusNoContent
+	if len(c.statuses) > 0 {
+		status = c.statuses[0]
+		c.statuses = c.statuses[1:]
+	}
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "0")
+	}
+	w.WriteHeader(status)
+}
+
+func newTestMetric(name string, value interface{}) metric.Metric {
+	return metric.Metric{
+		Name:   name,
+		Tags:   []metric.Tag{{Key: "host", Value: "web01"}},
+		Fields: []metric.Field{{Key: "value", Value: value}},
+		Time:   time.Unix(0, 1),
+	}
+}
+
+func TestHTTPOutputBatchingAndRetry(t *testing.T) {
+	collector := &fakeCollector{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "http",
+		"type": "http_output",
+		"batchMaxSize": 2,
+		"params": {
+			"url": "%s/write",
+			"headers": {"Authorization": "Token secret"},
+			"retryInitialInterval": "1ms",
+			"retryMaxInterval": "10ms"
+		}
+	}`, server.URL)).(*httpOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the metrics are sent in batches of up to batchMaxSize, the first one after two retries
+	_, err = proc.OnReceiveBatch([]metric.Metric{newTestMetric("cpu", 0), newTestMetric("cpu", 1), newTestMetric("cpu", 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collector.bodies) != 4 {
+		t.Fatalf("invalid number of requests: %d", len(collector.bodies))
+	}
+	if collector.bodies[2] != "cpu,host=web01 value=0i 1\ncpu,host=web01 value=1i 1\n" {
+		t.Errorf("invalid body: %q", collector.bodies[2])
+	}
+	if collector.headers[2].Get("Authorization") != "Token secret" || collector.headers[2].Get("Content-Encoding") != "gzip" {
+		t.Errorf("invalid headers: %v", collector.headers[2])
+	}
+	if collector.bodies[3] != "cpu,host=web01 value=2i 1\n" {
+		t.Errorf("invalid body of the rest: %q", collector.bodies[3])
+	}
+
+	err = proc.Close()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHTTPOutputNonRetriable(t *testing.T) {
+	collector := &fakeCollector{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "http",
+		"type": "http_output",
+		"params": {
+			"url": "%s",
+			"format": "json",
+			"contentEncoding": "identity"
+		}
+	}`, server.URL)).(*httpOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	_, err = proc.OnReceive(newTestMetric("cpu", 1.5))
+	if err == nil {
+		t.Error("bad request should fail")
+	}
+	if len(collector.bodies) != 1 || len(proc.batcher.buffer) != 0 {
+		t.Errorf("bad request should not be retried: %d requests, %d buffered", len(collector.bodies), len(proc.batcher.buffer))
+	}
+	if !strings.Contains(collector.bodies[0], `"metrics":[{"name":"cpu"`) || collector.headers[0].Get("Content-Type") != "application/json" {
+		t.Errorf("invalid json request: %s", collector.bodies[0])
+	}
+}
+
+func TestHTTPOutputSkipUnserializable(t *testing.T) {
+	for _, format := range []string{"influx", "json"} {
+		collector := &fakeCollector{}
+		server := httptest.NewServer(collector)
+
+		proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+		{
+			"name": "http",
+			"type": "http_output",
+			"params": {
+				"url": "%s",
+				"format": "%s",
+				"contentEncoding": "identity"
+			}
+		}`, server.URL, format)).(*httpOutputProcessor)
+		err := proc.Setup(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// metrics with only a NaN field cannot be serialized in any format
+		_, err = proc.OnReceiveBatch([]metric.Metric{newTestMetric("cpu", 1.5), newTestMetric("nan", math.NaN()), newTestMetric("mem", 2.5)})
+		if err != nil {
+			t.Errorf("%s: unserializable metrics should be skipped: %v", format, err)
+		}
+		if len(collector.bodies) != 1 || !strings.Contains(collector.bodies[0], "cpu") || !strings.Contains(collector.bodies[0], "mem") ||
+			strings.Contains(collector.bodies[0], "nan") {
+			t.Errorf("%s: expected the serializable metrics but got %v", format, collector.bodies)
+		}
+
+		proc.Close()
+		server.Close()
+	}
+}
+
+func TestHTTPOutputOverflow(t *testing.T) {
+	for _, policy := range []string{overflowPolicyDropOldest, overflowPolicyDropNewest} {
+		collector := &fakeCollector{statuses: []int{500, 500}}
+		server := httptest.NewServer(collector)
+
+		proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+		{
+			"name": "http",
+			"type": "http_output",
+			"batchMaxSize": 2,
+			"params": {
+				"url": "%s",
+				"bufferLimit": 3,
+				"overflowPolicy": "%s",
+				"maxRetries": 1,
+				"retryInitialInterval": "1ms",
+				"retryMaxInterval": "1h"
+			}
+		}`, server.URL, policy)).(*httpOutputProcessor)
+		err := proc.Setup(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = proc.OnReceiveBatch([]metric.Metric{newTestMetric("m0", 0), newTestMetric("m1", 1)})
+		if err == nil {
+			t.Error("sending should fail after retries")
+		}
+		// no more batches are sent until the next cron trigger or until retryMaxInterval has passed
+		for i := 2; i < 5; i++ {
+			_, err = proc.OnReceive(newTestMetric(fmt.Sprintf("m%d", i), i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(collector.bodies) != 2 {
+			t.Errorf("invalid number of requests: %d", len(collector.bodies))
+		}
+
+		names := []string{}
+		for _, mt := range proc.batcher.buffer {
+			names = append(names, mt.Name)
+		}
+		expected := "m2,m3,m4"
+		if policy == overflowPolicyDropNewest {
+			expected = "m0,m1,m2"
+		}
+		if strings.Join(names, ",") != expected {
+			t.Errorf("invalid buffer with %s: %v", policy, names)
+		}
+
+		_, err = proc.OnCronTrigger()
+		if err != nil {
+			t.Error(err)
+		}
+		if len(proc.batcher.buffer) != 0 {
+			t.Errorf("buffer should be flushed: %d", len(proc.batcher.buffer))
+		}
+		proc.Close()
+		server.Close()
+	}
+}
+
+// stoppingEmitter is an emitter of a processor which is requested to stop
+type stoppingEmitter struct {
+	collectingEmitter
+	stopping chan struct{}
+}
+
+func (emitter *stoppingEmitter) Stopping() <-chan struct{} {
+	return emitter.stopping
+}
+
+func TestHTTPOutputRetryWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	newProc := func(interval string) *httpOutputProcessor {
+		return newTestProcessorFromConfig(t, fmt.Sprintf(`
+		{
+			"name": "http",
+			"type": "http_output",
+			"params": {
+				"url": "%s/write",
+				"maxRetries": 2,
+				"retryInitialInterval": "%s",
+				"retryMaxInterval": "%s"
+			}
+		}`, server.URL, interval, interval)).(*httpOutputProcessor)
+	}
+
+	// Retry-After is capped by retryMaxInterval
+	proc := newProc("10ms")
+	if err := proc.Setup(nil); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := proc.OnReceive(newTestMetric("cpu", 1)); err == nil {
+		t.Error("expected a failure after retries")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retries waited too long: %v", elapsed)
+	}
+
+	// retries are not waited for once the processor is being stopped
+	proc = newProc("1h")
+	emitter := &stoppingEmitter{stopping: make(chan struct{})}
+	if err := proc.Setup(emitter); err != nil {
+		t.Fatal(err)
+	}
+	close(emitter.stopping)
+	start = time.Now()
+	if _, err := proc.OnReceive(newTestMetric("cpu", 1)); err == nil {
+		t.Error("expected a failure when stopping")
+	}
+	if err := proc.Close(); err == nil {
+		t.Error("expected a failure on close")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retries waited too long when stopping: %v", elapsed)
+	}
+}
+
+func TestBatcherRetryUnsent(t *testing.T) {
+	params := defaultBatchingConfig()
+	params.MaxRetries = 1
+	params.RetryInitialInterval = config.Duration(time.Millisecond)
+	params.RetryMaxInterval = config.Duration(time.Millisecond)
+
+	// every send writes the first metric of the batch and fails
+	sent := []string{}
+	b := newBatcher("test", &params, 3, func(batch []metric.Metric) error {
+		sent = append(sent, batch[0].Name)
+		return &sendError{err: fmt.Errorf("unavailable"), retriable: true, unsent: batch[1:]}
+	})
+	for _, name := range []string{"m0", "m1", "m2", "m3"} {
+		b.buffer = append(b.buffer, newTestMetric(name, 1))
+	}
+
+	if err := b.flushBatch(); err == nil {
+		t.Fatal("expected a failure after retries")
+	}
+	names := []string{}
+	for _, mt := range b.buffer {
+		names = append(names, mt.Name)
+	}
+	if fmt.Sprint(sent) != "[m0 m1]" || fmt.Sprint(names) != "[m2 m3]" {
+		t.Errorf("only unsent metrics should be retried and kept, sent %v and kept %v", sent, names)
+	}
+}
+
+func TestBatcherHoldAfterRetries(t *testing.T) {
+	params := defaultBatchingConfig()
+	params.MaxRetries = 0
+	params.RetryInitialInterval = config.Duration(time.Millisecond)
+	params.RetryMaxInterval = config.Duration(10 * time.Millisecond)
+
+	sends := 0
+	b := newBatcher("test", &params, 1, func(batch []metric.Metric) error {
+		sends++
+		if sends == 1 {
+			return &sendError{err: fmt.Errorf("unavailable"), retriable: true}
+		}
+		return nil
+	})
+
+	if err := b.add([]metric.Metric{newTestMetric("m0", 1)}); err == nil {
+		t.Fatal("expected a failure")
+	}
+	if err := b.add([]metric.Metric{newTestMetric("m1", 1)}); err != nil || sends != 1 {
+		t.Errorf("sending should be held back after a failure: %d sends, %v", sends, err)
+	}
+
+	// the sending resumes without a cron trigger
+	time.Sleep(20 * time.Millisecond)
+	if err := b.add([]metric.Metric{newTestMetric("m2", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if sends != 4 || len(b.buffer) != 0 {
+		t.Errorf("sending should resume once retryMaxInterval has passed: %d sends, %d buffered", sends, len(b.buffer))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	if d := parseRetryAfter("120", now); d != 2*time.Minute {
+		t.Errorf("invalid retry after in seconds: %v", d)
+	}
+	if d := parseRetryAfter("Thu, 01 Jun 2023 00:00:30 GMT", now); d != 30*time.Second {
+		t.Errorf("invalid retry after as date: %v", d)
+	}
+	if d := parseRetryAfter("invalid", now); d != 0 {
+		t.Errorf("invalid retry after should be ignored: %v", d)
+	}
+}
@@ -0,0 +1,257 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/expinc/melegraf/serializer"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypeInfluxDBOutput = "influxdb_output"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeInfluxDBOutput, NewInfluxDBOutputProcessor)
+}
+
+type influxDBOutputProcessor struct {
+	cfg     *config.ProcessorConfig
+	params  *InfluxDBOutputConfig
+	client  *http.Client
+	batcher *batcher
+}
+
+var _ processor.BatchProcessor = (*influxDBOutputProcessor)(nil)
+
+// NewInfluxDBOutputProcessor creates a new InfluxDB output processor
+func NewInfluxDBOutputProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*InfluxDBOutputConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for influxdb output processor: %T", cfg.Params)
+	}
+
+	proc := &influxDBOutputProcessor{
+		cfg:    cfg,
+		params: params,
+	}
+	size, _ := processor.BatchLimits(cfg)
+	proc.batcher = newBatcher(cfg.Name, &params.BatchingConfig, size, proc.send)
+	return proc, nil
+}
+
+func (proc *influxDBOutputProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *influxDBOutputProcessor) Setup(emitter processor.Emitter) error {
+	proc.batcher.setup(emitter)
+	proc.client = &http.Client{Timeout: time.Duration(proc.params.Timeout)}
+	return nil
+}
+
+func (proc *influxDBOutputProcessor) Close() error {
+	err := proc.batcher.flush()
+	proc.client.CloseIdleConnections()
+	return err
+}
+
+func (proc *influxDBOutputProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.OnReceiveBatch([]metric.Metric{mt})
+}
+
+func (proc *influxDBOutputProcessor) OnReceiveBatch(metrics []metric.Metric) ([]metric.Metric, error) {
+	return nil, proc.batcher.add(metrics)
+}
+
+func (proc *influxDBOutputProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, proc.batcher.flush()
+}
+
+// defaultBucket returns the database of v1 or the bucket of v2
+func (proc *influxDBOutputProcessor) defaultBucket() string {
+	if proc.params.Version == "v1" {
+		return proc.params.Database
+	}
+	return proc.params.Bucket
+}
+
+// send routes the batch to buckets by the bucket tag and writes each of them
+// If a write fails, the error tells the metrics not written so that the others are not written again
+// A bucket rejecting its metrics does not stop the other buckets from being written,
+// and the error of a send with only rejected metrics tells the rejected ones
+func (proc *influxDBOutputProcessor) send(batch []metric.Metric) error {
+	buckets := []string{}
+	routed := map[string][]metric.Metric{}
+	for _, mt := range batch {
+		bucket := proc.bucket(mt)
+		if _, ok := routed[bucket]; !ok {
+			buckets = append(buckets, bucket)
+		}
+		routed[bucket] = append(routed[bucket], mt)
+	}
+
+	var rejectErr error
+	var rejected []metric.Metric
+	for i, bucket := range buckets {
+		unsent, err := proc.write(bucket, routed[bucket])
+		if err == nil {
+			continue
+		}
+
+		var sendErr *sendError
+		if errors.As(err, &sendErr) && !sendErr.retriable {
+			rejectErr = err
+			rejected = append(rejected, unsent...)
+			continue
+		}
+
+		if len(rejected) > 0 {
+			logrus.Errorf("Processor \"%s\" dropped %d metrics on a non-retriable error: %v", proc.cfg.Name, len(rejected), rejectErr)
+		}
+		for _, rest := range buckets[i+1:] {
+			unsent = append(unsent, routed[rest]...)
+		}
+		return withUnsent(err, unsent)
+	}
+	if rejectErr != nil {
+		return withUnsent(rejectErr, rejected)
+	}
+	return nil
+}
+
+// bucket returns the bucket of a metric by the bucket tag, or the default one
+func (proc *influxDBOutputProcessor) bucket(mt metric.Metric) string {
+	if proc.params.BucketTag != "" {
+		if value, err := mt.GetTag(proc.params.BucketTag); err == nil && value != "" {
+			return value
+		}
+	}
+	return proc.defaultBucket()
+}
+
+// withUnsent sets the unsent metrics of a failed send
+func withUnsent(err error, unsent []metric.Metric) error {
+	var sendErr *sendError
+	if errors.As(err, &sendErr) {
+		sendErr.unsent = unsent
+		return err
+	}
+	return &sendError{err: err, retriable: true, unsent: unsent}
+}
+
+// write writes metrics to a bucket, and returns the metrics not written on failure
+// Batches rejected as too large are split in halves and written separately
+func (proc *influxDBOutputProcessor) write(bucket string, metrics []metric.Metric) ([]metric.Metric, error) {
+	var body bytes.Buffer
+	for _, mt := range metrics {
+		if proc.params.ExcludeBucketTag && proc.params.BucketTag != "" {
+			mt = mt.Copy()
+			for i, tag := range mt.Tags {
+				if tag.Key == proc.params.BucketTag {
+					mt.Tags = append(mt.Tags[:i], mt.Tags[i+1:]...)
+					break
+				}
+			}
+		}
+		line, err := serializer.SerializeInfluxLine(mt)
+		if err != nil {
+			logrus.Warnf("Processor \"%s\" skipped metric: %v", proc.cfg.Name, err)
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	if body.Len() == 0 {
+		return nil, nil
+	}
+
+	err := proc.post(bucket, body.Bytes())
+	var sendErr *sendError
+	if errors.As(err, &sendErr) && sendErr.tooLarge && len(metrics) > 1 {
+		half := len(metrics) / 2
+		logrus.Warnf("Processor \"%s\" splits a batch of %d metrics rejected as too large", proc.cfg.Name, len(metrics))
+		unsent, err := proc.write(bucket, metrics[:half])
+		if err != nil {
+			// the metrics not written are always the last ones
+			return metrics[half-len(unsent):], err
+		}
+		return proc.write(bucket, metrics[half:])
+	}
+	if err != nil {
+		return metrics, err
+	}
+	return nil, nil
+}
+
+func (proc *influxDBOutputProcessor) post(bucket string, data []byte) error {
+	var endpoint string
+	query := url.Values{}
+	query.Set("precision", "ns")
+	if proc.params.Version == "v1" {
+		endpoint = "/write"
+		query.Set("db", bucket)
+		if proc.params.RetentionPolicy != "" {
+			query.Set("rp", proc.params.RetentionPolicy)
+		}
+	} else {
+		endpoint = "/api/v2/write"
+		query.Set("org", proc.params.Org)
+		query.Set("bucket", bucket)
+	}
+	u := strings.TrimSuffix(proc.params.URL, "/") + endpoint + "?" + query.Encode()
+
+	var err error
+	if proc.params.ContentEncoding == "gzip" {
+		data, err = gzipBody(data)
+		if err != nil {
+			return &sendError{err: err}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(proc.params.Timeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return &sendError{err: err}
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if proc.params.ContentEncoding == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if proc.params.Version == "v1" && proc.params.Username != "" {
+		req.SetBasicAuth(proc.params.Username, proc.params.Password)
+	}
+	if proc.params.Version == "v2" && proc.params.Token != "" {
+		req.Header.Set("Authorization", "Token "+proc.params.Token)
+	}
+
+	resp, err := proc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// partial writes are reported as 400 and are not retriable like other 4xx responses
+	err = checkHTTPResponse(resp)
+	var sendErr *sendError
+	if errors.As(err, &sendErr) && resp.StatusCode == http.StatusRequestEntityTooLarge {
+		sendErr.tooLarge = true
+	}
+	return err
+}
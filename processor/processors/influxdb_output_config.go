@@ -0,0 +1,84 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/expinc/melegraf/config"
+)
+
+type InfluxDBOutputConfig struct {
+	// URL is the base URL of the InfluxDB server, e.g. "http://localhost:8086"
+	URL string `json:"url"`
+	// Version is either "v1" or "v2"
+	Version string `json:"version"`
+	// Database, RetentionPolicy, Username and Password are used by v1
+	Database        string `json:"database"`
+	RetentionPolicy string `json:"retentionPolicy"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	// Org, Bucket and Token are used by v2
+	Org    string `json:"org"`
+	Bucket string `json:"bucket"`
+	Token  string `json:"token"`
+	// BucketTag routes metrics to the database or bucket named by the tag value
+	// Metrics without the tag are written to the default database or bucket
+	BucketTag string `json:"bucketTag"`
+	// ExcludeBucketTag removes the bucket tag from the written metrics
+	ExcludeBucketTag bool `json:"excludeBucketTag"`
+	// ContentEncoding is either "gzip" or "identity"
+	ContentEncoding string `json:"contentEncoding"`
+	// BatchingConfig contains timeout, bufferLimit, overflowPolicy and retry options
+	BatchingConfig
+}
+
+var _ config.CustomConfig = (*InfluxDBOutputConfig)(nil)
+
+func NewInfluxDBOutputConfig() config.CustomConfig {
+	return &InfluxDBOutputConfig{
+		Version:         "v2",
+		ContentEncoding: "gzip",
+		BatchingConfig:  defaultBatchingConfig(),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeInfluxDBOutput, NewInfluxDBOutputConfig)
+}
+
+func (cfg *InfluxDBOutputConfig) Validate() error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url: %s", cfg.URL)
+	}
+
+	switch cfg.Version {
+	case "v1":
+		if strings.TrimSpace(cfg.Database) == "" {
+			return errors.New("database is required for InfluxDB v1")
+		}
+	case "v2":
+		if strings.TrimSpace(cfg.Org) == "" || strings.TrimSpace(cfg.Bucket) == "" {
+			return errors.New("org and bucket are required for InfluxDB v2")
+		}
+	default:
+		return fmt.Errorf("invalid InfluxDB version: %s", cfg.Version)
+	}
+
+	if cfg.ContentEncoding != "gzip" && cfg.ContentEncoding != "identity" {
+		return fmt.Errorf("invalid content encoding: %s", cfg.ContentEncoding)
+	}
+
+	return cfg.BatchingConfig.Validate()
+}
+
+func (cfg *InfluxDBOutputConfig) UnmarshalJSON(data []byte) error {
+	type plain InfluxDBOutputConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
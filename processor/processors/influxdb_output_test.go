@@ -0,0 +1,285 @@
+package processors
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// fakeInfluxDB accepts at most maxLines lines per write and stores the lines per bucket
+// The first write with a line containing failOnce is unavailable
+type fakeInfluxDB struct {
+	sync.Mutex
+	maxLines int
+	failOnce string
+	writes   int
+	auth     []string
+	lines    map[string][]string
+}
+
+func (db *fakeInfluxDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	db.Lock()
+	defer db.Unlock()
+	db.writes++
+
+	var bucket string
+	switch r.URL.Path {
+	case "/write":
+		bucket = r.URL.Query().Get("db") + "/" + r.URL.Query().Get("rp")
+		user, password, _ := r.BasicAuth()
+		db.auth = append(db.auth, user+":"+password)
+	case "/api/v2/write":
+		bucket = r.URL.Query().Get("org") + "/" + r.URL.Query().Get("bucket")
+		db.auth = append(db.auth, r.Header.Get("Authorization"))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.URL.Query().Get("precision") != "ns" {
+		http.Error(w, "invalid precision", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, _ := ioutil.ReadAll(reader)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+
+	if len(lines) > db.maxLines {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	for _, line := range lines {
+		if db.failOnce != "" && strings.Contains(line, db.failOnce) {
+			db.failOnce = ""
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if strings.Contains(line, "invalid") {
+			http.Error(w, `{"error":"partial write: field type conflict"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if db.lines == nil {
+		db.lines = map[string][]string{}
+	}
+	db.lines[bucket] = append(db.lines[bucket], lines...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestInfluxDBOutputV2RoutingAndSplitting(t *testing.T) {
+	db := &fakeInfluxDB{maxLines: 2}
+	server := httptest.NewServer(db)
+	defer server.Close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "influxdb",
+		"type": "influxdb_output",
+		"params": {
+			"url": "%s",
+			"org": "acme",
+			"bucket": "default",
+			"token": "secret",
+			"bucketTag": "bucket",
+			"excludeBucketTag": true
+		}
+	}`, server.URL)).(*influxDBOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	metrics := []metric.Metric{}
+	for i := 0; i < 5; i++ {
+		mt := newTestMetric("cpu", i)
+		if i%2 == 1 {
+			mt.Tags = append(mt.Tags, metric.Tag{Key: "bucket", Value: "ops"})
+		}
+		metrics = append(metrics, mt)
+	}
+	_, err = proc.OnReceiveBatch(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.lines["acme/default"]) != 3 || len(db.lines["acme/ops"]) != 2 {
+		t.Fatalf("invalid routing: %v", db.lines)
+	}
+	if db.lines["acme/ops"][0] != "cpu,host=web01 value=1i 1" {
+		t.Errorf("bucket tag should be excluded: %s", db.lines["acme/ops"][0])
+	}
+	// the 3 metrics of the default bucket are rejected once and written in 2 halves
+	if db.writes != 4 {
+		t.Errorf("invalid number of writes: %d", db.writes)
+	}
+	if db.auth[0] != "Token secret" {
+		t.Errorf("invalid authorization: %s", db.auth[0])
+	}
+}
+
+func TestInfluxDBOutputRetryUnwritten(t *testing.T) {
+	db := &fakeInfluxDB{maxLines: 2, failOnce: "fail"}
+	server := httptest.NewServer(db)
+	defer server.Close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "influxdb",
+		"type": "influxdb_output",
+		"params": {
+			"url": "%s",
+			"org": "acme",
+			"bucket": "default",
+			"bucketTag": "bucket",
+			"retryInitialInterval": "1ms",
+			"retryMaxInterval": "1ms"
+		}
+	}`, server.URL)).(*influxDBOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	// the default bucket is split, its first half is written and the second half fails once,
+	// and the ops bucket is not written until the retry
+	mt := newTestMetric("cpu3", 1)
+	mt.Tags = append(mt.Tags, metric.Tag{Key: "bucket", Value: "ops"})
+	_, err = proc.OnReceiveBatch([]metric.Metric{newTestMetric("cpu0", 1), newTestMetric("cpu1", 1), newTestMetric("fail2", 1), mt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(db.lines["acme/default"]) != "[cpu0,host=web01 value=1i 1 cpu1,host=web01 value=1i 1 fail2,host=web01 value=1i 1]" {
+		t.Errorf("written metrics should not be written again: %v", db.lines["acme/default"])
+	}
+	if fmt.Sprint(db.lines["acme/ops"]) != "[cpu3,bucket=ops,host=web01 value=1i 1]" {
+		t.Errorf("unwritten buckets should be retried: %v", db.lines["acme/ops"])
+	}
+}
+
+func TestInfluxDBOutputV1PartialWrite(t *testing.T) {
+	db := &fakeInfluxDB{maxLines: 100}
+	server := httptest.NewServer(db)
+	defer server.Close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "influxdb",
+		"type": "influxdb_output",
+		"params": {
+			"url": "%s/",
+			"version": "v1",
+			"database": "telemetry",
+			"retentionPolicy": "week",
+			"username": "melegraf",
+			"password": "pass",
+			"retryInitialInterval": "1ms",
+			"retryMaxInterval": "1ms"
+		}
+	}`, server.URL)).(*influxDBOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	_, err = proc.OnReceiveBatch([]metric.Metric{newTestMetric("cpu", 1), newTestMetric("cpu", "invalid")})
+	if err == nil {
+		t.Error("partial write should fail")
+	}
+	if db.writes != 1 {
+		t.Errorf("partial write should not be retried: %d writes", db.writes)
+	}
+	if len(proc.batcher.buffer) != 0 {
+		t.Errorf("partially written batch should be dropped: %d", len(proc.batcher.buffer))
+	}
+
+	_, err = proc.OnReceive(newTestMetric("mem", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.lines["telemetry/week"]) != 1 || db.auth[1] != "melegraf:pass" {
+		t.Errorf("invalid v1 write: %v %v", db.lines, db.auth)
+	}
+}
+
+func TestInfluxDBOutputRejectedBucket(t *testing.T) {
+	db := &fakeInfluxDB{maxLines: 100}
+	server := httptest.NewServer(db)
+	defer server.Close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "influxdb",
+		"type": "influxdb_output",
+		"params": {
+			"url": "%s",
+			"org": "acme",
+			"bucket": "default",
+			"bucketTag": "bucket",
+			"retryInitialInterval": "1ms",
+			"retryMaxInterval": "1ms"
+		}
+	}`, server.URL)).(*influxDBOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	// the default bucket is rejected with a 400, the ops bucket after it is still written
+	mt := newTestMetric("mem", 1)
+	mt.Tags = append(mt.Tags, metric.Tag{Key: "bucket", Value: "ops"})
+	_, err = proc.OnReceiveBatch([]metric.Metric{newTestMetric("cpu", "invalid"), mt})
+	if err == nil {
+		t.Error("rejected bucket should fail")
+	}
+
+	if db.writes != 2 {
+		t.Errorf("rejected bucket should not be retried: %d writes", db.writes)
+	}
+	if fmt.Sprint(db.lines["acme/ops"]) != "[mem,bucket=ops,host=web01 value=1i 1]" {
+		t.Errorf("buckets after the rejected one should be written: %v", db.lines)
+	}
+	if len(proc.batcher.buffer) != 0 {
+		t.Errorf("rejected metrics should be dropped: %d", len(proc.batcher.buffer))
+	}
+}
+
+func TestInfluxDBOutputConfigInvalid(t *testing.T) {
+	cfgs := []string{
+		`{"url": "http://localhost:8086", "version": "v1"}`,
+		`{"url": "http://localhost:8086", "version": "v2", "org": "acme"}`,
+		`{"url": "http://localhost:8086", "version": "v3"}`,
+		`{"url": "localhost", "version": "v1", "database": "db"}`,
+	}
+	for _, cfgStr := range cfgs {
+		cfg := NewInfluxDBOutputConfig()
+		err := json.Unmarshal([]byte(cfgStr), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", cfgStr)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package processors
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypeOpenTSDBOutput = "opentsdb_output"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeOpenTSDBOutput, NewOpenTSDBOutputProcessor)
+}
+
+// openTSDBSanitize replaces the characters not allowed in metric names, tag keys and tag values
+// OpenTSDB accepts letters, digits and "-_./"
+func openTSDBSanitize(str string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./", r) {
+			return r
+		}
+		return '_'
+	}, str)
+}
+
+type openTSDBOutputProcessor struct {
+	cfg     *config.ProcessorConfig
+	params  *OpenTSDBOutputConfig
+	sender  *tcpSender
+	batcher *batcher
+}
+
+var _ processor.BatchProcessor = (*openTSDBOutputProcessor)(nil)
+
+// NewOpenTSDBOutputProcessor creates a new OpenTSDB telnet output processor
+func NewOpenTSDBOutputProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*OpenTSDBOutputConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for opentsdb output processor: %T", cfg.Params)
+	}
+
+	proc := &openTSDBOutputProcessor{
+		cfg:    cfg,
+		params: params,
+	}
+	size, _ := processor.BatchLimits(cfg)
+	proc.batcher = newBatcher(cfg.Name, &params.BatchingConfig, size, proc.send)
+	return proc, nil
+}
+
+func (proc *openTSDBOutputProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *openTSDBOutputProcessor) Setup(emitter processor.Emitter) error {
+	proc.batcher.setup(emitter)
+	proc.sender = newTCPSender(proc.cfg.Name, proc.params.Address, time.Duration(proc.params.Timeout))
+	return nil
+}
+
+func (proc *openTSDBOutputProcessor) Close() error {
+	err := proc.batcher.flush()
+	proc.sender.close()
+	return err
+}
+
+func (proc *openTSDBOutputProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.OnReceiveBatch([]metric.Metric{mt})
+}
+
+func (proc *openTSDBOutputProcessor) OnReceiveBatch(metrics []metric.Metric) ([]metric.Metric, error) {
+	return nil, proc.batcher.add(metrics)
+}
+
+func (proc *openTSDBOutputProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, proc.batcher.flush()
+}
+
+func (proc *openTSDBOutputProcessor) send(batch []metric.Metric) error {
+	var buf bytes.Buffer
+	for _, mt := range batch {
+		for _, line := range proc.serialize(mt) {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return proc.sender.write(buf.Bytes())
+}
+
+// serialize converts every numeric or boolean field of a metric to a line in the form of
+// "put <prefix.name.field> <timestamp> <value> <tagk1=tagv1 ...>"
+// OpenTSDB rejects data points without tags, so such metrics are skipped
+func (proc *openTSDBOutputProcessor) serialize(mt metric.Metric) []string {
+	tags := make([]metric.Tag, 0, len(mt.Tags))
+	for _, tag := range mt.Tags {
+		if tag.Key != "" && tag.Value != "" {
+			tags = append(tags, metric.Tag{Key: openTSDBSanitize(tag.Key), Value: openTSDBSanitize(tag.Value)})
+		}
+	}
+	if len(tags) == 0 {
+		logrus.Warnf("Processor \"%s\" skipped metric \"%s\" without tags", proc.cfg.Name, mt.Name)
+		return nil
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	var tagStr strings.Builder
+	for _, tag := range tags {
+		tagStr.WriteByte(' ')
+		tagStr.WriteString(tag.Key)
+		tagStr.WriteByte('=')
+		tagStr.WriteString(tag.Value)
+	}
+
+	timestamp := mt.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	var ts string
+	if proc.params.MillisecondTimestamps {
+		ts = strconv.FormatInt(timestamp.UnixNano()/int64(time.Millisecond), 10)
+	} else {
+		ts = strconv.FormatInt(timestamp.Unix(), 10)
+	}
+
+	lines := []string{}
+	for _, field := range mt.Fields {
+		value, ok := formatTCPOutputValue(field.Value)
+		if !ok {
+			continue
+		}
+
+		parts := []string{}
+		if proc.params.Prefix != "" {
+			parts = append(parts, proc.params.Prefix)
+		}
+		parts = append(parts, mt.Name)
+		if field.Key != "value" {
+			parts = append(parts, field.Key)
+		}
+		name := openTSDBSanitize(strings.Join(parts, proc.params.Separator))
+		lines = append(lines, "put "+name+" "+ts+" "+value+tagStr.String())
+	}
+	return lines
+}
@@ -0,0 +1,53 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/expinc/melegraf/config"
+)
+
+type OpenTSDBOutputConfig struct {
+	// Address of the OpenTSDB telnet interface, e.g. "localhost:4242"
+	Address string `json:"address"`
+	// Prefix is prepended to every metric name
+	Prefix string `json:"prefix"`
+	// Separator joins the prefix, the metric name and the field
+	Separator string `json:"separator"`
+	// MillisecondTimestamps writes timestamps in milliseconds instead of seconds
+	MillisecondTimestamps bool `json:"millisecondTimestamps"`
+	// BatchingConfig contains timeout, bufferLimit, overflowPolicy and retry options
+	BatchingConfig
+}
+
+var _ config.CustomConfig = (*OpenTSDBOutputConfig)(nil)
+
+func NewOpenTSDBOutputConfig() config.CustomConfig {
+	return &OpenTSDBOutputConfig{
+		Address:        "localhost:4242",
+		Separator:      ".",
+		BatchingConfig: defaultBatchingConfig(),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeOpenTSDBOutput, NewOpenTSDBOutputConfig)
+}
+
+func (cfg *OpenTSDBOutputConfig) Validate() error {
+	if strings.TrimSpace(cfg.Address) == "" {
+		return errors.New("address is required")
+	}
+
+	if cfg.Separator != openTSDBSanitize(cfg.Separator) {
+		return errors.New("separator contains characters not allowed by OpenTSDB")
+	}
+
+	return cfg.BatchingConfig.Validate()
+}
+
+func (cfg *OpenTSDBOutputConfig) UnmarshalJSON(data []byte) error {
+	type plain OpenTSDBOutputConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
@@ -0,0 +1,79 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestOpenTSDBOutput(t *testing.T) {
+	server := newFakeLineServer(t, "127.0.0.1:0")
+	defer server.close()
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "opentsdb",
+		"type": "opentsdb_output",
+		"params": {
+			"address": "%s",
+			"prefix": "melegraf",
+			"millisecondTimestamps": true
+		}
+	}`, server.listener.Addr())).(*openTSDBOutputProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	metrics := []metric.Metric{
+		{
+			Name:   "cpu",
+			Tags:   []metric.Tag{{Key: "host", Value: "web01"}, {Key: "dc", Value: "eu west"}},
+			Fields: []metric.Field{{Key: "usage idle", Value: 87.5}, {Key: "state", Value: "ok"}, {Key: "cores", Value: uint8(4)}},
+			Time:   time.Unix(1700000000, 123000000),
+		},
+		{
+			Name:   "untagged",
+			Fields: []metric.Field{{Key: "value", Value: 1}},
+		},
+		newTestMetric("mem", 1024),
+	}
+	_, err = proc.OnReceiveBatch(metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := server.waitLines(t, 3)
+	expected := []string{
+		"put melegraf.cpu.usage_idle 1700000000123 87.5 dc=eu_west host=web01",
+		"put melegraf.cpu.cores 1700000000123 4 dc=eu_west host=web01",
+		"put melegraf.mem 0 1024 host=web01",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("invalid line %d: %s", i, lines[i])
+		}
+	}
+}
+
+func TestOpenTSDBOutputConfigInvalid(t *testing.T) {
+	cfgs := []string{
+		`{"address": " "}`,
+		`{"address": "localhost:4242", "separator": " "}`,
+		`{"address": "localhost:4242", "bufferLimit": 0}`,
+	}
+	for _, cfgStr := range cfgs {
+		cfg := NewOpenTSDBOutputConfig()
+		err := json.Unmarshal([]byte(cfgStr), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", cfgStr)
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package processors
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/otlp"
+)
+
+// The OTLP metric types are carried by the prometheusTypeTag
+// Non-monotonic sums are up-down counters, delta temporality is marked by otlpTemporalityTag
+const (
+	otlpTypeGauge                = "gauge"
+	otlpTypeCounter              = "counter"
+	otlpTypeUpDownCounter        = "updowncounter"
+	otlpTypeHistogram            = "histogram"
+	otlpTypeExponentialHistogram = "exponential_histogram"
+
+	otlpTemporalityTag   = "temporality"
+	otlpTemporalityDelta = "delta"
+
+	otlpScopeName = "melegraf"
+)
+
+// otlpToMetrics converts OTLP metrics to metrics tagged with the resource and the data point attributes
+// Gauges, counters and up-down counters have a "gauge", "counter" or "value" field
+// Histograms have cumulative bucket counts keyed by the upper bounds like prometheus histograms
+// Exponential histograms have "positive_<index>" and "negative_<index>" bucket counts with their "scale"
+func otlpToMetrics(req *otlp.ExportMetricsServiceRequest, now time.Time) []metric.Metric {
+	out := []metric.Metric{}
+	for _, rm := range req.ResourceMetrics {
+		resourceTags := otlpAttributesToTags(nil, rm.Resource.Attributes)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				out = append(out, otlpMetricToMetrics(m, resourceTags, now)...)
+			}
+		}
+	}
+	return out
+}
+
+func otlpMetricToMetrics(m *otlp.Metric, resourceTags []metric.Tag, now time.Time) []metric.Metric {
+	out := []metric.Metric{}
+	newMetric := func(attributes []otlp.KeyValue, timestamp otlp.Uint64, mtype string, temporality int32) metric.Metric {
+		tags := make([]metric.Tag, len(resourceTags))
+		copy(tags, resourceTags)
+		tags = otlpAttributesToTags(tags, attributes)
+		tags = setTag(tags, prometheusTypeTag, mtype)
+		if temporality == otlp.AggregationTemporalityDelta {
+			tags = setTag(tags, otlpTemporalityTag, otlpTemporalityDelta)
+		}
+
+		mt := metric.Metric{Name: m.Name, Tags: tags, Time: now}
+		if timestamp != 0 {
+			mt.Time = time.Unix(0, int64(timestamp))
+		}
+		return mt
+	}
+
+	switch {
+	case m.Gauge != nil:
+		for _, dp := range m.Gauge.DataPoints {
+			mt := newMetric(dp.Attributes, dp.TimeUnixNano, otlpTypeGauge, otlp.AggregationTemporalityUnspecified)
+			if value, ok := otlpNumberValue(dp); ok {
+				mt.AddField(otlpTypeGauge, value)
+				out = append(out, mt)
+			}
+		}
+
+	case m.Sum != nil:
+		mtype, field := otlpTypeUpDownCounter, "value"
+		if m.Sum.IsMonotonic {
+			mtype, field = otlpTypeCounter, otlpTypeCounter
+		}
+		for _, dp := range m.Sum.DataPoints {
+			mt := newMetric(dp.Attributes, dp.TimeUnixNano, mtype, m.Sum.AggregationTemporality)
+			if value, ok := otlpNumberValue(dp); ok {
+				mt.AddField(field, value)
+				out = append(out, mt)
+			}
+		}
+
+	case m.Histogram != nil:
+		for _, dp := range m.Histogram.DataPoints {
+			mt := newMetric(dp.Attributes, dp.TimeUnixNano, otlpTypeHistogram, m.Histogram.AggregationTemporality)
+			var cumulative uint64
+			for i, count := range dp.BucketCounts {
+				cumulative += uint64(count)
+				bound := math.Inf(1)
+				if i < len(dp.ExplicitBounds) {
+					bound = dp.ExplicitBounds[i]
+				}
+				mt.AddField(formatPrometheusValue(bound), float64(cumulative))
+			}
+			otlpAddSummaryFields(&mt, dp.Count, dp.Sum, dp.Min, dp.Max)
+			out = append(out, mt)
+		}
+
+	case m.ExponentialHistogram != nil:
+		for _, dp := range m.ExponentialHistogram.DataPoints {
+			mt := newMetric(dp.Attributes, dp.TimeUnixNano, otlpTypeExponentialHistogram, m.ExponentialHistogram.AggregationTemporality)
+			mt.AddField("scale", int64(dp.Scale))
+			mt.AddField("zero_count", float64(dp.ZeroCount))
+			if dp.ZeroThreshold != 0 {
+				mt.AddField("zero_threshold", dp.ZeroThreshold)
+			}
+			for sign, buckets := range map[string]otlp.Buckets{"positive": dp.Positive, "negative": dp.Negative} {
+				for i, count := range buckets.BucketCounts {
+					if count > 0 {
+						mt.AddField(sign+"_"+strconv.Itoa(int(buckets.Offset)+i), float64(count))
+					}
+				}
+			}
+			sort.SliceStable(mt.Fields, func(i, j int) bool {
+				return mt.Fields[i].Key < mt.Fields[j].Key
+			})
+			otlpAddSummaryFields(&mt, dp.Count, dp.Sum, dp.Min, dp.Max)
+			out = append(out, mt)
+		}
+	}
+	return out
+}
+
+func otlpAddSummaryFields(mt *metric.Metric, count otlp.Uint64, sum, min, max *float64) {
+	mt.AddField("count", float64(count))
+	if sum != nil {
+		mt.AddField("sum", *sum)
+	}
+	if min != nil {
+		mt.AddField("min", *min)
+	}
+	if max != nil {
+		mt.AddField("max", *max)
+	}
+}
+
+func otlpNumberValue(dp *otlp.NumberDataPoint) (interface{}, bool) {
+	switch {
+	case dp.AsInt != nil:
+		return int64(*dp.AsInt), true
+	case dp.AsDouble != nil:
+		return *dp.AsDouble, true
+	default:
+		return nil, false
+	}
+}
+
+// otlpAttributesToTags appends the attributes to tags, replacing the tags of the same keys
+func otlpAttributesToTags(tags []metric.Tag, attributes []otlp.KeyValue) []metric.Tag {
+	for _, kv := range attributes {
+		value := kv.Value.String()
+		if kv.Key != "" && value != "" {
+			tags = setTag(tags, kv.Key, value)
+		}
+	}
+	return tags
+}
+
+func setTag(tags []metric.Tag, key, value string) []metric.Tag {
+	for i := range tags {
+		if tags[i].Key == key {
+			tags[i].Value = value
+			return tags
+		}
+	}
+	return append(tags, metric.Tag{Key: key, Value: value})
+}
+
+// metricsToOTLP converts metrics to an OTLP request, the reverse of otlpToMetrics
+// Tags listed in resourceTags become resource attributes and the others data point attributes
+// Metrics without a known type produce a gauge per numeric field named "<name>.<field>",
+// or "<name>" for the field "value"
+func metricsToOTLP(metrics []metric.Metric, resourceTags []string) *otlp.ExportMetricsServiceRequest {
+	isResourceTag := map[string]bool{}
+	for _, key := range resourceTags {
+		isResourceTag[key] = true
+	}
+
+	req := &otlp.ExportMetricsServiceRequest{}
+	scopes := map[string]*otlp.ScopeMetrics{}
+	families := map[string]*otlp.Metric{}
+	for _, mt := range metrics {
+		var mtype string
+		temporality := otlp.AggregationTemporalityCumulative
+		resource := []otlp.KeyValue{}
+		attributes := []otlp.KeyValue{}
+		for _, tag := range mt.Tags {
+			switch {
+			case tag.Key == prometheusTypeTag:
+				mtype = tag.Value
+			case tag.Key == otlpTemporalityTag && tag.Value == otlpTemporalityDelta:
+				temporality = otlp.AggregationTemporalityDelta
+			case isResourceTag[tag.Key]:
+				resource = append(resource, otlp.KeyValue{Key: tag.Key, Value: otlp.StringValue(tag.Value)})
+			default:
+				attributes = append(attributes, otlp.KeyValue{Key: tag.Key, Value: otlp.StringValue(tag.Value)})
+			}
+		}
+		sort.Slice(resource, func(i, j int) bool {
+			return resource[i].Key < resource[j].Key
+		})
+
+		var resourceKey strings.Builder
+		for _, kv := range resource {
+			resourceKey.WriteString(kv.Key + "=" + *kv.Value.StringValue + ",")
+		}
+		scope, ok := scopes[resourceKey.String()]
+		if !ok {
+			scope = &otlp.ScopeMetrics{Scope: otlp.Scope{Name: otlpScopeName}}
+			scopes[resourceKey.String()] = scope
+			req.ResourceMetrics = append(req.ResourceMetrics, &otlp.ResourceMetrics{
+				Resource:     otlp.Resource{Attributes: resource},
+				ScopeMetrics: []*otlp.ScopeMetrics{scope},
+			})
+		}
+
+		// family returns the metric of the scope with the name and the type of the data point
+		family := func(name string, newFamily func() *otlp.Metric) *otlp.Metric {
+			key := resourceKey.String() + "\n" + name + "\n" + mtype + "\n" + strconv.Itoa(int(temporality))
+			m, ok := families[key]
+			if !ok {
+				m = newFamily()
+				m.Name = name
+				families[key] = m
+				scope.Metrics = append(scope.Metrics, m)
+			}
+			return m
+		}
+		timestamp := otlp.Uint64(0)
+		if !mt.Time.IsZero() {
+			timestamp = otlp.Uint64(mt.Time.UnixNano())
+		}
+
+		switch mtype {
+		case otlpTypeGauge, otlpTypeCounter, otlpTypeUpDownCounter:
+			dp := otlpNumberDataPoint(mt, attributes, timestamp)
+			if dp == nil {
+				continue
+			}
+			m := family(mt.Name, func() *otlp.Metric {
+				if mtype == otlpTypeGauge {
+					return &otlp.Metric{Gauge: &otlp.Gauge{}}
+				}
+				return &otlp.Metric{Sum: &otlp.Sum{
+					AggregationTemporality: temporality,
+					IsMonotonic:            mtype == otlpTypeCounter,
+				}}
+			})
+			if m.Gauge != nil {
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+			} else {
+				m.Sum.DataPoints = append(m.Sum.DataPoints, dp)
+			}
+
+		case otlpTypeHistogram:
+			dp := otlpHistogramDataPoint(mt, attributes, timestamp)
+			m := family(mt.Name, func() *otlp.Metric {
+				return &otlp.Metric{Histogram: &otlp.Histogram{AggregationTemporality: temporality}}
+			})
+			m.Histogram.DataPoints = append(m.Histogram.DataPoints, dp)
+
+		case otlpTypeExponentialHistogram:
+			dp := otlpExponentialHistogramDataPoint(mt, attributes, timestamp)
+			m := family(mt.Name, func() *otlp.Metric {
+				return &otlp.Metric{ExponentialHistogram: &otlp.ExponentialHistogram{AggregationTemporality: temporality}}
+			})
+			m.ExponentialHistogram.DataPoints = append(m.ExponentialHistogram.DataPoints, dp)
+
+		default:
+			mtype = otlpTypeGauge
+			for _, field := range mt.Fields {
+				dp := otlpNumberDataPointOf(field.Value, attributes, timestamp)
+				if dp == nil {
+					continue
+				}
+				name := mt.Name
+				if field.Key != "value" {
+					name += "." + field.Key
+				}
+				m := family(name, func() *otlp.Metric {
+					return &otlp.Metric{Gauge: &otlp.Gauge{}}
+				})
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+			}
+		}
+	}
+	return req
+}
+
+// otlpNumberDataPoint converts the field named by the type, or the first numeric field
+func otlpNumberDataPoint(mt metric.Metric, attributes []otlp.KeyValue, timestamp otlp.Uint64) *otlp.NumberDataPoint {
+	for _, key := range []string{otlpTypeGauge, otlpTypeCounter, "value"} {
+		if value, err := mt.GetField(key); err == nil {
+			if dp := otlpNumberDataPointOf(value, attributes, timestamp); dp != nil {
+				return dp
+			}
+		}
+	}
+	for _, field := range mt.Fields {
+		if dp := otlpNumberDataPointOf(field.Value, attributes, timestamp); dp != nil {
+			return dp
+		}
+	}
+	return nil
+}
+
+// otlpNumberDataPointOf keeps integers and booleans as integers and converts the other numbers to doubles
+func otlpNumberDataPointOf(value interface{}, attributes []otlp.KeyValue, timestamp otlp.Uint64) *otlp.NumberDataPoint {
+	dp := &otlp.NumberDataPoint{Attributes: attributes, TimeUnixNano: timestamp}
+	var i otlp.Int64
+	switch v := value.(type) {
+	case float32, float64:
+		f, _ := metric.ToFloat(v)
+		dp.AsDouble = &f
+		return dp
+	case int:
+		i = otlp.Int64(v)
+	case int64:
+		i = otlp.Int64(v)
+	case uint:
+		i = otlp.Int64(v)
+	case uint64:
+		i = otlp.Int64(v)
+	default:
+		// the smaller integers and booleans are exact in float64
+		f, ok := metric.ToFloat(v)
+		if !ok {
+			return nil
+		}
+		i = otlp.Int64(f)
+	}
+	dp.AsInt = &i
+	return dp
+}
+
+// otlpFloatField returns a numeric field as float64
+func otlpFloatField(mt metric.Metric, key string) (float64, bool) {
+	value, err := mt.GetField(key)
+	if err != nil {
+		return 0, false
+	}
+	return metric.ToFloat(value)
+}
+
+func otlpOptionalFloatField(mt metric.Metric, key string) *float64 {
+	if f, ok := otlpFloatField(mt, key); ok {
+		return &f
+	}
+	return nil
+}
+
+func otlpHistogramDataPoint(mt metric.Metric, attributes []otlp.KeyValue, timestamp otlp.Uint64) *otlp.HistogramDataPoint {
+	dp := &otlp.HistogramDataPoint{
+		Attributes:   attributes,
+		TimeUnixNano: timestamp,
+		Sum:          otlpOptionalFloatField(mt, "sum"),
+		Min:          otlpOptionalFloatField(mt, "min"),
+		Max:          otlpOptionalFloatField(mt, "max"),
+	}
+	count, hasCount := otlpFloatField(mt, "count")
+
+	type bucket struct {
+		bound float64
+		count float64
+	}
+	buckets := []bucket{}
+	for _, field := range mt.Fields {
+		switch field.Key {
+		case "sum", "count", "min", "max":
+			continue
+		}
+		bound, err := parsePrometheusValue(field.Key)
+		value, ok := metric.ToFloat(field.Value)
+		if err != nil || !ok || math.IsNaN(bound) {
+			continue
+		}
+		buckets = append(buckets, bucket{bound: bound, count: value})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].bound < buckets[j].bound
+	})
+
+	// the cumulative counts are converted to the counts of each bucket
+	var previous float64
+	for _, b := range buckets {
+		if math.IsInf(b.bound, 1) {
+			break
+		}
+		dp.ExplicitBounds = append(dp.ExplicitBounds, b.bound)
+		dp.BucketCounts = append(dp.BucketCounts, otlp.Uint64(b.count-previous))
+		previous = b.count
+	}
+	if !hasCount {
+		count = previous
+		if len(buckets) > 0 && math.IsInf(buckets[len(buckets)-1].bound, 1) {
+			count = buckets[len(buckets)-1].count
+		}
+	}
+	dp.Count = otlp.Uint64(count)
+	dp.BucketCounts = append(dp.BucketCounts, otlp.Uint64(count-previous))
+	return dp
+}
+
+func otlpExponentialHistogramDataPoint(mt metric.Metric, attributes []otlp.KeyValue, timestamp otlp.Uint64) *otlp.ExponentialHistogramDataPoint {
+	dp := &otlp.ExponentialHistogramDataPoint{
+		Attributes:   attributes,
+		TimeUnixNano: timestamp,
+		Sum:          otlpOptionalFloatField(mt, "sum"),
+		Min:          otlpOptionalFloatField(mt, "min"),
+		Max:          otlpOptionalFloatField(mt, "max"),
+	}
+	if count, ok := otlpFloatField(mt, "count"); ok {
+		dp.Count = otlp.Uint64(count)
+	}
+	if scale, ok := otlpFloatField(mt, "scale"); ok {
+		dp.Scale = int32(scale)
+	}
+	if zeroCount, ok := otlpFloatField(mt, "zero_count"); ok {
+		dp.ZeroCount = otlp.Uint64(zeroCount)
+	}
+	if zeroThreshold, ok := otlpFloatField(mt, "zero_threshold"); ok {
+		dp.ZeroThreshold = zeroThreshold
+	}
+
+	positive := map[int]float64{}
+	negative := map[int]float64{}
+	for _, field := range mt.Fields {
+		var counts map[int]float64
+		var index string
+		if strings.HasPrefix(field.Key, "positive_") {
+			counts, index = positive, strings.TrimPrefix(field.Key, "positive_")
+		} else if strings.HasPrefix(field.Key, "negative_") {
+			counts, index = negative, strings.TrimPrefix(field.Key, "negative_")
+		} else {
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		value, ok := metric.ToFloat(field.Value)
+		if err == nil && ok {
+			counts[i] = value
+		}
+	}
+	dp.Positive = otlpBuckets(positive)
+	dp.Negative = otlpBuckets(negative)
+	return dp
+}
+
+// otlpBuckets converts sparse bucket counts by index to consecutive buckets
+func otlpBuckets(counts map[int]float64) otlp.Buckets {
+	if len(counts) == 0 {
+		return otlp.Buckets{}
+	}
+
+	first, last := math.MaxInt32, math.MinInt32
+	for i := range counts {
+		if i < first {
+			first = i
+		}
+		if i > last {
+			last = i
+		}
+	}
+	buckets := otlp.Buckets{Offset: int32(first), BucketCounts: make([]otlp.Uint64, last-first+1)}
+	for i, count := range counts {
+		buckets.BucketCounts[i-first] = otlp.Uint64(count)
+	}
+	return buckets
+}
@@ -0,0 +1,130 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/otlp"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeOTLPExporter = "otlp_exporter"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeOTLPExporter, NewOTLPExporterProcessor)
+}
+
+type otlpExporterProcessor struct {
+	cfg     *config.ProcessorConfig
+	params  *OTLPExporterConfig
+	client  *http.Client
+	batcher *batcher
+}
+
+var _ processor.BatchProcessor = (*otlpExporterProcessor)(nil)
+
+// NewOTLPExporterProcessor creates a new OTLP/HTTP metrics exporter processor
+func NewOTLPExporterProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*OTLPExporterConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for otlp exporter processor: %T", cfg.Params)
+	}
+
+	proc := &otlpExporterProcessor{
+		cfg:    cfg,
+		params: params,
+	}
+	size, _ := processor.BatchLimits(cfg)
+	proc.batcher = newBatcher(cfg.Name, &params.BatchingConfig, size, proc.send)
+	return proc, nil
+}
+
+func (proc *otlpExporterProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *otlpExporterProcessor) Setup(emitter processor.Emitter) error {
+	proc.batcher.setup(emitter)
+	proc.client = &http.Client{Timeout: time.Duration(proc.params.Timeout)}
+	return nil
+}
+
+func (proc *otlpExporterProcessor) Close() error {
+	err := proc.batcher.flush()
+	proc.client.CloseIdleConnections()
+	return err
+}
+
+func (proc *otlpExporterProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.OnReceiveBatch([]metric.Metric{mt})
+}
+
+func (proc *otlpExporterProcessor) OnReceiveBatch(metrics []metric.Metric) ([]metric.Metric, error) {
+	return nil, proc.batcher.add(metrics)
+}
+
+func (proc *otlpExporterProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, proc.batcher.flush()
+}
+
+func (proc *otlpExporterProcessor) send(batch []metric.Metric) error {
+	req := metricsToOTLP(batch, proc.params.ResourceTags)
+	if len(req.ResourceMetrics) == 0 {
+		return nil
+	}
+
+	var data []byte
+	var err error
+	contentType := otlp.ContentTypeProtobuf
+	if proc.params.Encoding == "json" {
+		contentType = otlp.ContentTypeJSON
+		data, err = json.Marshal(req)
+		if err != nil {
+			return &sendError{err: err}
+		}
+	} else {
+		data = otlp.MarshalProto(req)
+	}
+
+	if proc.params.ContentEncoding == "gzip" {
+		data, err = gzipBody(data)
+		if err != nil {
+			return &sendError{err: err}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(proc.params.Timeout))
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, proc.params.URL, bytes.NewReader(data))
+	if err != nil {
+		return &sendError{err: err}
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	if proc.params.ContentEncoding == "gzip" {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range proc.params.Headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := proc.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkHTTPResponse(resp)
+}
@@ -0,0 +1,65 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/otlp"
+)
+
+type OTLPExporterConfig struct {
+	// URL of the OTLP/HTTP metrics endpoint, e.g. "http://localhost:4318/v1/metrics"
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Encoding is either "protobuf" or "json"
+	Encoding string `json:"encoding"`
+	// ContentEncoding is either "gzip" or "identity"
+	ContentEncoding string `json:"contentEncoding"`
+	// ResourceTags are the tags exported as resource attributes instead of data point attributes
+	ResourceTags []string `json:"resourceTags"`
+	// BatchingConfig contains timeout, bufferLimit, overflowPolicy and retry options
+	BatchingConfig
+}
+
+var _ config.CustomConfig = (*OTLPExporterConfig)(nil)
+
+func NewOTLPExporterConfig() config.CustomConfig {
+	return &OTLPExporterConfig{
+		URL:             "http://localhost:4318" + otlp.MetricsPath,
+		Encoding:        "protobuf",
+		ContentEncoding: "gzip",
+		ResourceTags:    []string{"service.name", "service.namespace", "service.instance.id", "host.name"},
+		BatchingConfig:  defaultBatchingConfig(),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeOTLPExporter, NewOTLPExporterConfig)
+}
+
+func (cfg *OTLPExporterConfig) Validate() error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url: %s", cfg.URL)
+	}
+
+	if cfg.Encoding != "protobuf" && cfg.Encoding != "json" {
+		return fmt.Errorf("invalid encoding: %s", cfg.Encoding)
+	}
+
+	if cfg.ContentEncoding != "gzip" && cfg.ContentEncoding != "identity" {
+		return fmt.Errorf("invalid content encoding: %s", cfg.ContentEncoding)
+	}
+
+	return cfg.BatchingConfig.Validate()
+}
+
+func (cfg *OTLPExporterConfig) UnmarshalJSON(data []byte) error {
+	type plain OTLPExporterConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
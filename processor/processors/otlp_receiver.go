@@ -0,0 +1,177 @@
+package processors
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/otlp"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypeOTLPReceiver = "otlp_receiver"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeOTLPReceiver, NewOTLPReceiverProcessor)
+}
+
+type otlpReceiverProcessor struct {
+	cfg     *config.ProcessorConfig
+	params  *OTLPReceiverConfig
+	emitter processor.Emitter
+
+	server   *http.Server
+	listener net.Listener
+}
+
+var _ processor.Processor = (*otlpReceiverProcessor)(nil)
+
+// NewOTLPReceiverProcessor creates a new OTLP/HTTP metrics receiver processor
+func NewOTLPReceiverProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*OTLPReceiverConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for otlp receiver processor: %T", cfg.Params)
+	}
+
+	return &otlpReceiverProcessor{
+		cfg:    cfg,
+		params: params,
+	}, nil
+}
+
+func (proc *otlpReceiverProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *otlpReceiverProcessor) Setup(emitter processor.Emitter) error {
+	proc.emitter = emitter
+
+	listener, err := net.Listen("tcp", proc.params.Address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(proc.params.Path, proc.serveMetrics)
+	proc.listener = listener
+	proc.server = &http.Server{Handler: mux}
+
+	go func(server *http.Server) {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Processor \"%s\" failed to receive metrics: %v", proc.cfg.Name, err)
+		}
+	}(proc.server)
+
+	return nil
+}
+
+func (proc *otlpReceiverProcessor) Close() error {
+	if proc.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := proc.server.Shutdown(ctx)
+	proc.server = nil
+	proc.listener = nil
+	return err
+}
+
+// addr returns the address the processor is listening on
+func (proc *otlpReceiverProcessor) addr() net.Addr {
+	if proc.listener != nil {
+		return proc.listener.Addr()
+	}
+	return nil
+}
+
+func (proc *otlpReceiverProcessor) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != otlp.ContentTypeProtobuf && contentType != otlp.ContentTypeJSON) {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer reader.Close()
+		body = reader
+	default:
+		http.Error(w, "unsupported content encoding", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(body, proc.params.MaxBodySize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > proc.params.MaxBodySize {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var req *otlp.ExportMetricsServiceRequest
+	if contentType == otlp.ContentTypeProtobuf {
+		req, err = otlp.UnmarshalProto(data)
+	} else {
+		req = &otlp.ExportMetricsServiceRequest{}
+		err = json.Unmarshal(data, req)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	err = proc.emitter.Emit(otlpToMetrics(req, time.Now())...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// an empty response means all data points are accepted
+	w.Header().Set("Content-Type", contentType)
+	if contentType == otlp.ContentTypeJSON {
+		w.Write([]byte("{}"))
+	}
+}
+
+func (proc *otlpReceiverProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *otlpReceiverProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, nil
+}
@@ -0,0 +1,54 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/otlp"
+)
+
+type OTLPReceiverConfig struct {
+	// Address is the address to listen on, e.g. ":4318"
+	Address string `json:"address"`
+	// Path is the HTTP path receiving the metrics
+	Path string `json:"path"`
+	// MaxBodySize is the maximum size in bytes of a decompressed request body
+	MaxBodySize int64 `json:"maxBodySize"`
+}
+
+var _ config.CustomConfig = (*OTLPReceiverConfig)(nil)
+
+func NewOTLPReceiverConfig() config.CustomConfig {
+	return &OTLPReceiverConfig{
+		Address:     ":4318",
+		Path:        otlp.MetricsPath,
+		MaxBodySize: 4 * 1024 * 1024,
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeOTLPReceiver, NewOTLPReceiverConfig)
+}
+
+func (cfg *OTLPReceiverConfig) Validate() error {
+	if strings.TrimSpace(cfg.Address) == "" {
+		return errors.New("address is required")
+	}
+
+	if !strings.HasPrefix(cfg.Path, "/") {
+		return errors.New("path must start with \"/\"")
+	}
+
+	if cfg.MaxBodySize <= 0 {
+		return errors.New("maxBodySize must be a positive number")
+	}
+
+	return nil
+}
+
+func (cfg *OTLPReceiverConfig) UnmarshalJSON(data []byte) error {
+	type plain OTLPReceiverConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
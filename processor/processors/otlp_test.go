@@ -0,0 +1,258 @@
+package processors
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/otlp"
+)
+
+const otlpJSONRequest = `
+{
+	"resourceMetrics": [{
+		"resource": {"attributes": [
+			{"key": "service.name", "value": {"stringValue": "checkout"}},
+			{"key": "host.name", "value": {"stringValue": "web01"}}
+		]},
+		"scopeMetrics": [{
+			"scope": {"name": "meter"},
+			"metrics": [
+				{
+					"name": "queue.size",
+					"gauge": {"dataPoints": [
+						{"attributes": [{"key": "queue", "value": {"stringValue": "orders"}}], "timeUnixNano": "1700000000000000000", "asInt": "12"},
+						{"attributes": [{"key": "queue", "value": {"stringValue": "refunds"}}], "timeUnixNano": "1700000000000000000", "asDouble": 0.5}
+					]}
+				},
+				{
+					"name": "http.requests",
+					"sum": {
+						"dataPoints": [{"attributes": [{"key": "code", "value": {"intValue": "200"}}], "timeUnixNano": "1700000000000000000", "asInt": "1027"}],
+						"aggregationTemporality": 2,
+						"isMonotonic": true
+					}
+				},
+				{
+					"name": "connections",
+					"sum": {
+						"dataPoints": [{"timeUnixNano": "1700000000000000000", "asInt": "-3"}],
+						"aggregationTemporality": 1
+					}
+				},
+				{
+					"name": "http.duration",
+					"histogram": {
+						"dataPoints": [{
+							"timeUnixNano": "1700000000000000000",
+							"count": "10",
+							"sum": 3.5,
+							"bucketCounts": ["2", "5", "3"],
+							"explicitBounds": [0.1, 0.5],
+							"min": 0.01,
+							"max": 1.2
+						}],
+						"aggregationTemporality": 2
+					}
+				},
+				{
+					"name": "payload.size",
+					"exponentialHistogram": {
+						"dataPoints": [{
+							"timeUnixNano": "1700000000000000000",
+							"count": "9",
+							"sum": 1200,
+							"scale": 3,
+							"zeroCount": "1",
+							"positive": {"offset": 5, "bucketCounts": ["4", "0", "2"]},
+							"negative": {"offset": -2, "bucketCounts": ["2"]}
+						}],
+						"aggregationTemporality": 1
+					}
+				}
+			]
+		}]
+	}]
+}`
+
+func startOTLPReceiver(t *testing.T) (*otlpReceiverProcessor, *collectingEmitter) {
+	proc := newTestProcessorFromConfig(t, `
+	{
+		"name": "otlp_receiver",
+		"type": "otlp_receiver",
+		"params": {
+			"address": "127.0.0.1:0"
+		}
+	}`).(*otlpReceiverProcessor)
+	emitter := &collectingEmitter{}
+	err := proc.Setup(emitter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proc, emitter
+}
+
+// otlpComparable returns the metrics keyed by series with their fields, independent of the order
+func otlpComparable(metrics []metric.Metric) map[string]map[string]interface{} {
+	out := map[string]map[string]interface{}{}
+	for _, mt := range metrics {
+		fields := map[string]interface{}{}
+		for _, field := range mt.Fields {
+			fields[field.Key] = field.Value
+		}
+		out[mt.SeriesKey()+" "+mt.Time.UTC().String()] = fields
+	}
+	return out
+}
+
+func TestOTLPReceiver(t *testing.T) {
+	proc, emitter := startOTLPReceiver(t)
+	defer proc.Close()
+
+	url := "http://" + proc.addr().String() + otlp.MetricsPath
+	resp, err := http.Post(url, otlp.ContentTypeJSON, strings.NewReader(otlpJSONRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != otlp.ContentTypeJSON {
+		t.Fatalf("invalid response: %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	metrics := emitter.waitFor(6, time.Second)
+	if len(metrics) != 6 {
+		t.Fatalf("invalid number of metrics: %d", len(metrics))
+	}
+	resource := []metric.Tag{{Key: "service.name", Value: "checkout"}, {Key: "host.name", Value: "web01"}}
+	tags := func(extra ...metric.Tag) []metric.Tag {
+		return append(append([]metric.Tag{}, resource...), extra...)
+	}
+
+	mt := findMetric(metrics, "queue.size", tags(metric.Tag{Key: "queue", Value: "orders"}, metric.Tag{Key: prometheusTypeTag, Value: "gauge"})...)
+	if mt == nil || !reflect.DeepEqual(mt.Fields, []metric.Field{{Key: "gauge", Value: int64(12)}}) {
+		t.Errorf("invalid gauge: %+v", mt)
+	}
+	if mt != nil && !mt.Time.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("invalid time: %v", mt.Time)
+	}
+	mt = findMetric(metrics, "http.requests", tags(metric.Tag{Key: "code", Value: "200"}, metric.Tag{Key: prometheusTypeTag, Value: "counter"})...)
+	if mt == nil || !reflect.DeepEqual(mt.Fields, []metric.Field{{Key: "counter", Value: int64(1027)}}) {
+		t.Errorf("invalid counter: %+v", mt)
+	}
+	mt = findMetric(metrics, "connections", tags(metric.Tag{Key: prometheusTypeTag, Value: "updowncounter"}, metric.Tag{Key: "temporality", Value: "delta"})...)
+	if mt == nil || !reflect.DeepEqual(mt.Fields, []metric.Field{{Key: "value", Value: int64(-3)}}) {
+		t.Errorf("invalid up-down counter: %+v", mt)
+	}
+	mt = findMetric(metrics, "http.duration", tags(metric.Tag{Key: prometheusTypeTag, Value: "histogram"})...)
+	expected := []metric.Field{
+		{Key: "0.1", Value: 2.0}, {Key: "0.5", Value: 7.0}, {Key: "+Inf", Value: 10.0},
+		{Key: "count", Value: 10.0}, {Key: "sum", Value: 3.5}, {Key: "min", Value: 0.01}, {Key: "max", Value: 1.2},
+	}
+	if mt == nil || !reflect.DeepEqual(mt.Fields, expected) {
+		t.Errorf("invalid histogram: %+v", mt)
+	}
+	mt = findMetric(metrics, "payload.size", tags(metric.Tag{Key: prometheusTypeTag, Value: "exponential_histogram"}, metric.Tag{Key: "temporality", Value: "delta"})...)
+	expected = []metric.Field{
+		{Key: "negative_-2", Value: 2.0}, {Key: "positive_5", Value: 4.0}, {Key: "positive_7", Value: 2.0},
+		{Key: "scale", Value: int64(3)}, {Key: "zero_count", Value: 1.0}, {Key: "count", Value: 9.0}, {Key: "sum", Value: 1200.0},
+	}
+	if mt == nil || !reflect.DeepEqual(mt.Fields, expected) {
+		t.Errorf("invalid exponential histogram: %+v", mt)
+	}
+
+	resp, err = http.Post(url, "text/plain", strings.NewReader("cpu value=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("unsupported content type should be rejected: %s", resp.Status)
+	}
+
+	resp, err = http.Post(url, otlp.ContentTypeProtobuf, strings.NewReader("\x0a\xff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid protobuf should be rejected: %s", resp.Status)
+	}
+}
+
+func TestOTLPRoundTrip(t *testing.T) {
+	source, sourceEmitter := startOTLPReceiver(t)
+	defer source.Close()
+
+	resp, err := http.Post("http://"+source.addr().String()+otlp.MetricsPath, otlp.ContentTypeJSON, strings.NewReader(otlpJSONRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	received := sourceEmitter.waitFor(6, time.Second)
+
+	for _, encoding := range []string{"protobuf", "json"} {
+		t.Run(encoding, func(t *testing.T) {
+			sink, sinkEmitter := startOTLPReceiver(t)
+			defer sink.Close()
+
+			exporter := newTestProcessorFromConfig(t, fmt.Sprintf(`
+			{
+				"name": "otlp_exporter",
+				"type": "otlp_exporter",
+				"params": {
+					"url": "http://%s/v1/metrics",
+					"encoding": "%s",
+					"resourceTags": ["service.name", "host.name"]
+				}
+			}`, sink.addr(), encoding)).(*otlpExporterProcessor)
+			err := exporter.Setup(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mt := range received {
+				_, err = exporter.OnReceive(mt)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = exporter.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exported := sinkEmitter.waitFor(len(received), time.Second)
+			if !reflect.DeepEqual(otlpComparable(received), otlpComparable(exported)) {
+				t.Errorf("round trip mismatch:\n%v\n%v", received, exported)
+			}
+		})
+	}
+}
+
+func TestOTLPExportUntyped(t *testing.T) {
+	req := metricsToOTLP([]metric.Metric{{
+		Name:   "cpu",
+		Tags:   []metric.Tag{{Key: "host.name", Value: "web01"}, {Key: "core", Value: "0"}},
+		Fields: []metric.Field{{Key: "value", Value: 1.5}, {Key: "busy", Value: true}, {Key: "state", Value: "ok"}},
+		Time:   time.Unix(1, 0),
+	}}, []string{"host.name"})
+
+	if len(req.ResourceMetrics) != 1 || req.ResourceMetrics[0].Resource.Attributes[0].Key != "host.name" {
+		t.Fatalf("invalid resource: %+v", req.ResourceMetrics)
+	}
+	names := []string{}
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Gauge == nil || len(m.Gauge.DataPoints) != 1 || m.Gauge.DataPoints[0].Attributes[0].Key != "core" {
+			t.Errorf("invalid untyped gauge: %+v", m)
+		}
+		names = append(names, m.Name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"cpu", "cpu.busy"}) {
+		t.Errorf("invalid gauge names: %v", names)
+	}
+}
@@ -0,0 +1,77 @@
+package processors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compilePattern compiles a glob, or a regular expression enclosed in slashes like "/^cpu[0-9]+$/"
+// Globs match the whole string, "*" matches any characters, "?" matches a single character
+// and "[...]" matches a character class
+// Regular expressions match any part of the string unless anchored
+func compilePattern(str string) (*regexp.Regexp, error) {
+	if len(str) >= 2 && strings.HasPrefix(str, "/") && strings.HasSuffix(str, "/") {
+		re, err := regexp.Compile(str[1 : len(str)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern \"%s\": %v", str, err)
+		}
+		return re, nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("^")
+	runes := []rune(str)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		case '[':
+			end := strings.IndexRune(string(runes[i+1:]), ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid pattern \"%s\": unclosed character class", str)
+			}
+			class := []rune(string(runes[i+1:])[:end])
+			if len(class) > 0 && class[0] == '!' {
+				class[0] = '^'
+			}
+			builder.WriteString("[" + strings.ReplaceAll(string(class), `\`, `\\`) + "]")
+			i += len(class) + 1
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	builder.WriteString("$")
+
+	re, err := regexp.Compile(builder.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern \"%s\": %v", str, err)
+	}
+	return re, nil
+}
+
+// patternList matches a string if any of its patterns matches
+type patternList []*regexp.Regexp
+
+func compilePatterns(strs []string) (patternList, error) {
+	patterns := make(patternList, 0, len(strs))
+	for _, str := range strs {
+		re, err := compilePattern(str)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+func (patterns patternList) match(str string) bool {
+	for _, re := range patterns {
+		if re.MatchString(str) {
+			return true
+		}
+	}
+	return false
+}
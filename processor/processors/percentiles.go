@@ -0,0 +1,185 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/expinc/melegraf/sketch"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypePercentiles = "percentiles"
+)
+
+// percentilesSketchSuffix is the suffix of the fields holding encoded sketches
+const percentilesSketchSuffix = "_sketch"
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypePercentiles, NewPercentilesProcessor)
+}
+
+// percentilesSeries holds the sketches of the fields of a series
+type percentilesSeries struct {
+	name     string
+	tags     []metric.Tag
+	keys     []string
+	sketches map[string]*sketch.DDSketch
+}
+
+type percentilesProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *PercentilesConfig
+	// suffixes are the field key suffixes of the quantiles
+	suffixes []string
+	series   map[string]*percentilesSeries
+}
+
+var _ processor.Processor = (*percentilesProcessor)(nil)
+
+// NewPercentilesProcessor creates a new percentiles processor
+func NewPercentilesProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*PercentilesConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for percentiles processor: %T", cfg.Params)
+	}
+
+	suffixes := make([]string, len(params.Quantiles))
+	for i, q := range params.Quantiles {
+		suffixes[i] = percentileSuffix(q)
+	}
+
+	return &percentilesProcessor{
+		cfg:      cfg,
+		params:   params,
+		suffixes: suffixes,
+		series:   make(map[string]*percentilesSeries),
+	}, nil
+}
+
+// percentileSuffix formats a quantile like 0.999 as "_p99_9"
+func percentileSuffix(q float64) string {
+	percentile := math.Round(q*100*1e6) / 1e6
+	return "_p" + strings.ReplaceAll(strconv.FormatFloat(percentile, 'f', -1, 64), ".", "_")
+}
+
+func (proc *percentilesProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *percentilesProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *percentilesProcessor) Close() error {
+	return nil
+}
+
+func (proc *percentilesProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	proc.add(mt)
+	if proc.params.DropOriginal {
+		return []metric.Metric{}, nil
+	}
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *percentilesProcessor) add(mt metric.Metric) {
+	var series *percentilesSeries
+	sketchOf := func(key string) *sketch.DDSketch {
+		if series == nil {
+			seriesKey := mt.SeriesKey()
+			series = proc.series[seriesKey]
+			if series == nil {
+				tags := make([]metric.Tag, len(mt.Tags))
+				copy(tags, mt.Tags)
+				series = &percentilesSeries{name: mt.Name, tags: tags, sketches: make(map[string]*sketch.DDSketch)}
+				proc.series[seriesKey] = series
+			}
+		}
+
+		s, ok := series.sketches[key]
+		if !ok {
+			s, _ = sketch.NewDDSketch(proc.params.RelativeAccuracy)
+			series.sketches[key] = s
+			series.keys = append(series.keys, key)
+		}
+		return s
+	}
+
+	for _, field := range mt.Fields {
+		if str, ok := field.Value.(string); ok && strings.HasSuffix(field.Key, percentilesSketchSuffix) {
+			key := strings.TrimSuffix(field.Key, percentilesSketchSuffix)
+			if !proc.aggregates(key) {
+				continue
+			}
+			partial, err := sketch.Decode(str)
+			if err == nil {
+				err = sketchOf(key).Merge(partial)
+			}
+			if err != nil {
+				logrus.Warnf("Processor \"%s\" skipped field \"%s\" of metric \"%s\": %v", proc.cfg.Name, field.Key, mt.Name, err)
+			}
+			continue
+		}
+
+		value, ok := numericValue(field.Value)
+		if !ok || !proc.aggregates(field.Key) {
+			continue
+		}
+		sketchOf(field.Key).Add(value)
+	}
+}
+
+func (proc *percentilesProcessor) aggregates(key string) bool {
+	return len(proc.params.fields) == 0 || proc.params.fields.match(key)
+}
+
+func (proc *percentilesProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return proc.flush(time.Now()), nil
+}
+
+// flush emits the quantiles of every series ordered by series key and resets the sketches
+func (proc *percentilesProcessor) flush(now time.Time) []metric.Metric {
+	var out []metric.Metric
+	for _, key := range sortedKeys(proc.series) {
+		series := proc.series[key]
+		mt := metric.Metric{
+			Name: series.name,
+			Tags: series.tags,
+			Time: now,
+		}
+		for _, field := range series.keys {
+			s := series.sketches[field]
+			if s.Count() == 0 {
+				continue
+			}
+			for i, q := range proc.params.Quantiles {
+				value, _ := s.Quantile(q)
+				mt.Fields = append(mt.Fields, metric.Field{Key: field + proc.suffixes[i], Value: value})
+			}
+			if len(proc.params.Quantiles) > 0 {
+				mt.Fields = append(mt.Fields, metric.Field{Key: field + "_count", Value: int64(s.Count())})
+			}
+			if proc.params.EmitSketch {
+				mt.Fields = append(mt.Fields, metric.Field{Key: field + percentilesSketchSuffix, Value: s.Encode()})
+			}
+		}
+		if len(mt.Fields) > 0 {
+			out = append(out, mt)
+		}
+	}
+
+	proc.series = make(map[string]*percentilesSeries)
+	return out
+}
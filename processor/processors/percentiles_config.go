@@ -0,0 +1,66 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/sketch"
+)
+
+// PercentilesConfig estimates quantiles of the numeric fields of every series between cron triggers
+// Each field is aggregated in a DDSketch, and on every trigger a metric with the name and tags
+// of the series is emitted with the fields "<field>_p<percentile>" like "latency_p99" or "latency_p99_9"
+// and "<field>_count", then the sketches are reset
+// String fields "<field>_sketch" holding sketches emitted by other instances are merged into "<field>"
+type PercentilesConfig struct {
+	// Quantiles in [0, 1] to emit, may be empty if the sketches are emitted
+	Quantiles []float64 `json:"quantiles"`
+	// RelativeAccuracy bounds the relative error of the quantiles, sketches are merged only with the same accuracy
+	RelativeAccuracy float64 `json:"relativeAccuracy"`
+	// Fields are patterns of the aggregated field keys, all numeric fields if empty
+	Fields []string `json:"fields"`
+	// EmitSketch emits the sketches as the string fields "<field>_sketch" to be merged downstream
+	EmitSketch bool `json:"emitSketch"`
+	// DropOriginal drops the received metrics instead of passing them through
+	DropOriginal bool `json:"dropOriginal"`
+
+	fields patternList
+}
+
+var _ config.CustomConfig = (*PercentilesConfig)(nil)
+
+func NewPercentilesConfig() config.CustomConfig {
+	return &PercentilesConfig{
+		Quantiles:        []float64{0.5, 0.9, 0.95, 0.99},
+		RelativeAccuracy: 0.01,
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypePercentiles, NewPercentilesConfig)
+}
+
+func (cfg *PercentilesConfig) Validate() error {
+	if len(cfg.Quantiles) == 0 && !cfg.EmitSketch {
+		return fmt.Errorf("quantiles are required unless the sketches are emitted")
+	}
+	for _, q := range cfg.Quantiles {
+		if !(q >= 0 && q <= 1) {
+			return fmt.Errorf("invalid quantile: %v", q)
+		}
+	}
+
+	if _, err := sketch.NewDDSketch(cfg.RelativeAccuracy); err != nil {
+		return err
+	}
+
+	var err error
+	cfg.fields, err = compilePatterns(cfg.Fields)
+	return err
+}
+
+func (cfg *PercentilesConfig) UnmarshalJSON(data []byte) error {
+	type plain PercentilesConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
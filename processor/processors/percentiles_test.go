@@ -0,0 +1,129 @@
+package processors
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestPercentileSuffix(t *testing.T) {
+	for q, suffix := range map[float64]string{0.5: "_p50", 0.99: "_p99", 0.999: "_p99_9", 0: "_p0", 1: "_p100", 0.9999: "_p99_99"} {
+		if actual := percentileSuffix(q); actual != suffix {
+			t.Errorf("expected %s for %v but got %s", suffix, q, actual)
+		}
+	}
+}
+
+func TestPercentilesQuantiles(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypePercentiles, `{"quantiles": [0.5, 0.99], "fields": ["latency"]}`).(*percentilesProcessor)
+
+	for i := 1; i <= 1000; i++ {
+		mt := newTestMetric("http", "fast")
+		mt.Fields = append(mt.Fields, metric.Field{Key: "latency", Value: float64(i)}, metric.Field{Key: "size", Value: i})
+		out, err := proc.OnReceive(mt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 {
+			t.Fatal("metrics should be passed through")
+		}
+	}
+
+	now := time.Unix(100, 0)
+	out := proc.flush(now)
+	if len(out) != 1 || out[0].Name != "http" || !out[0].Time.Equal(now) {
+		t.Fatalf("expected 1 metric but got %v", out)
+	}
+	if len(out[0].Fields) != 3 {
+		t.Errorf("expected only the fields of latency but got %v", out[0].Fields)
+	}
+	for key, exact := range map[string]float64{"latency_p50": 500, "latency_p99": 990} {
+		value, err := out[0].GetField(key)
+		if err != nil {
+			t.Fatalf("field %s is missing", key)
+		}
+		if math.Abs(value.(float64)-exact) > exact*0.01 {
+			t.Errorf("expected %s about %v but got %v", key, exact, value)
+		}
+	}
+	if count, _ := out[0].GetField("latency_count"); count != int64(1000) {
+		t.Errorf("expected count 1000 but got %v", count)
+	}
+
+	if out := proc.flush(now); len(out) != 0 {
+		t.Errorf("sketches should be reset after flush: %v", out)
+	}
+}
+
+func TestPercentilesMergeSketches(t *testing.T) {
+	downstream := newTestProcessor(t, ProcessorTypePercentiles, `{"quantiles": [0.1, 0.5, 0.9, 0.999], "relativeAccuracy": 0.02}`).(*percentilesProcessor)
+
+	var values []float64
+	for host := 0; host < 4; host++ {
+		upstream := newTestProcessor(t, ProcessorTypePercentiles, `{"quantiles": [], "relativeAccuracy": 0.02, "emitSketch": true, "dropOriginal": true}`).(*percentilesProcessor)
+		for i := 0; i < 500; i++ {
+			value := math.Pow(1.01, float64(i)) * float64(host+1)
+			values = append(values, value)
+			out, err := upstream.OnReceive(metric.Metric{Name: "rpc", Fields: []metric.Field{{Key: "duration", Value: value}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out == nil || len(out) != 0 {
+				t.Fatal("metrics should be dropped")
+			}
+		}
+
+		partials := upstream.flush(time.Unix(0, 0))
+		if len(partials) != 1 || len(partials[0].Fields) != 1 || partials[0].Fields[0].Key != "duration_sketch" {
+			t.Fatalf("expected only the sketch but got %v", partials)
+		}
+		downstream.OnReceive(partials[0])
+	}
+
+	out := downstream.flush(time.Unix(0, 0))
+	if len(out) != 1 {
+		t.Fatalf("expected 1 metric but got %v", out)
+	}
+	sort.Float64s(values)
+	for i, q := range []float64{0.1, 0.5, 0.9, 0.999} {
+		exact := values[int(q*float64(len(values)-1))]
+		value := out[0].Fields[i].Value.(float64)
+		if math.Abs(value-exact) > exact*0.02 {
+			t.Errorf("quantile %v estimated %v, expected %v", q, value, exact)
+		}
+	}
+	if count, _ := out[0].GetField("duration_count"); count != int64(2000) {
+		t.Errorf("expected count 2000 but got %v", count)
+	}
+
+	// invalid sketches and sketches of other accuracies are skipped
+	downstream.OnReceive(metric.Metric{Name: "rpc", Fields: []metric.Field{{Key: "duration_sketch", Value: "invalid"}}})
+	other := newTestProcessor(t, ProcessorTypePercentiles, `{"quantiles": [], "emitSketch": true}`).(*percentilesProcessor)
+	other.OnReceive(metric.Metric{Name: "rpc", Fields: []metric.Field{{Key: "duration", Value: 1.0}}})
+	downstream.OnReceive(other.flush(time.Unix(0, 0))[0])
+	if out := downstream.flush(time.Unix(0, 0)); len(out) != 0 {
+		t.Errorf("invalid sketches should be skipped: %v", out)
+	}
+}
+
+func TestPercentilesConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{"quantiles": []}`,
+		`{"quantiles": [1.5]}`,
+		`{"relativeAccuracy": 0}`,
+		`{"fields": ["/(/"]}`,
+	} {
+		cfg := NewPercentilesConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
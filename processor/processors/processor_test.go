@@ -11,6 +11,44 @@ import (
 	"github.com/expinc/melegraf/processor"
 )
 
+// newTestProcessorFromConfig creates a processor from a JSON processor config without setting it up
+func newTestProcessorFromConfig(t *testing.T, cfgStr string) processor.Processor {
+	var cfg config.ProcessorConfig
+	err := json.Unmarshal([]byte(cfgStr), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proc, err := processor.NewProcessor(cfg.Type, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proc
+}
+
+// newTestProcessor creates and sets up a processor named after its type, it is closed when the test ends
+// The params are a JSON string or a value marshaled to JSON
+func newTestProcessor(t *testing.T, typ string, params interface{}) processor.Processor {
+	data, ok := params.(string)
+	if !ok {
+		marshaled, err := json.Marshal(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = string(marshaled)
+	}
+
+	proc := newTestProcessorFromConfig(t, `{"name": "`+typ+`", "type": "`+typ+`", "params": `+data+`}`)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		proc.Close()
+	})
+	return proc
+}
+
 func TestDummyProcessorOrdinary(t *testing.T) {
 	// prepare config string
 	cfgStr := `
@@ -123,3 +161,28 @@ func TestDummyProcessorOrdinary(t *testing.T) {
 		t.Errorf("invalid number of metrics received in outpu2: %d", cntReceiveMetrics2)
 	}
 }
+
+func TestOutputBatchOptions(t *testing.T) {
+	for typ, params := range map[string]string{
+		ProcessorTypeHTTPOutput:     `{"url": "http://localhost"}`,
+		ProcessorTypeInfluxDBOutput: `{"url": "http://localhost", "org": "acme", "bucket": "default"}`,
+		ProcessorTypeOTLPExporter:   `{"url": "http://localhost"}`,
+		ProcessorTypeGraphiteOutput: `{"address": "localhost:2003"}`,
+		ProcessorTypeOpenTSDBOutput: `{"address": "localhost:4242"}`,
+		ProcessorTypeWebhookOutput:  `{"url": "http://localhost", "preset": "slack"}`,
+	} {
+		cfgStr := `{"name": "output", "type": "` + typ + `", "batchMaxSize": 500, "batchMaxWait": "200ms", "params": ` + params + `}`
+		if _, ok := newTestProcessorFromConfig(t, cfgStr).(processor.BatchProcessor); !ok {
+			t.Errorf("%s should receive metrics in batches", typ)
+		}
+	}
+
+	var cfg config.ProcessorConfig
+	err := json.Unmarshal([]byte(`{"name": "filter", "type": "filter", "batchMaxSize": 500, "params": {}}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = processor.NewProcessor(cfg.Type, &cfg); err == nil {
+		t.Error("batch options of processors not receiving batches should be rejected")
+	}
+}
@@ -0,0 +1,393 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypePrometheusExporter = "prometheus_exporter"
+
+	prometheusTextContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	prometheusOpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	prometheusHelp                   = "Melegraf collected metric"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypePrometheusExporter, NewPrometheusExporterProcessor)
+}
+
+// prometheusSeries is the latest value of a series
+// Histograms and summaries keep their buckets or quantiles, sum and count
+type prometheusSeries struct {
+	labels  []metric.Tag
+	value   float64
+	buckets map[float64]float64
+	sum     float64
+	count   float64
+	time    time.Time
+	updated time.Time
+}
+
+type prometheusExportFamily struct {
+	name   string
+	mtype  string
+	series map[string]*prometheusSeries
+}
+
+type prometheusExporterProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *PrometheusExporterConfig
+
+	mutex    sync.Mutex
+	families map[string]*prometheusExportFamily
+
+	server   *http.Server
+	listener net.Listener
+}
+
+var _ processor.Processor = (*prometheusExporterProcessor)(nil)
+
+// NewPrometheusExporterProcessor creates a new prometheus exporter processor
+func NewPrometheusExporterProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*PrometheusExporterConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for prometheus exporter processor: %T", cfg.Params)
+	}
+
+	return &prometheusExporterProcessor{
+		cfg:      cfg,
+		params:   params,
+		families: make(map[string]*prometheusExportFamily),
+	}, nil
+}
+
+func (proc *prometheusExporterProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *prometheusExporterProcessor) Setup(emitter processor.Emitter) error {
+	listener, err := net.Listen("tcp", proc.params.Address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(proc.params.Path, proc.serveMetrics)
+	proc.listener = listener
+	proc.server = &http.Server{Handler: mux}
+
+	go func(server *http.Server) {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Processor \"%s\" failed to serve metrics: %v", proc.cfg.Name, err)
+		}
+	}(proc.server)
+
+	return nil
+}
+
+func (proc *prometheusExporterProcessor) Close() error {
+	if proc.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := proc.server.Shutdown(ctx)
+	proc.server = nil
+	proc.listener = nil
+	return err
+}
+
+// addr returns the address the processor is serving on
+func (proc *prometheusExporterProcessor) addr() net.Addr {
+	if proc.listener != nil {
+		return proc.listener.Addr()
+	}
+	return nil
+}
+
+// sanitizePrometheusName replaces the characters not allowed in metric or label names
+func sanitizePrometheusName(name string, allowColon bool) string {
+	var builder strings.Builder
+	for i, r := range name {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(i > 0 && r >= '0' && r <= '9') || (allowColon && r == ':')
+		if !valid && i == 0 && r >= '0' && r <= '9' {
+			builder.WriteByte('_')
+			builder.WriteRune(r)
+			continue
+		}
+		if valid {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteByte('_')
+		}
+	}
+	return builder.String()
+}
+
+func (proc *prometheusExporterProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	proc.mutex.Lock()
+	defer proc.mutex.Unlock()
+
+	now := time.Now()
+	mtype := prometheusTypeUntyped
+	labels := make([]metric.Tag, 0, len(mt.Tags))
+	for _, tag := range mt.Tags {
+		if tag.Key == prometheusTypeTag {
+			mtype = tag.Value
+			continue
+		}
+		key := sanitizePrometheusName(tag.Key, false)
+		if key == "" || strings.HasPrefix(key, "__") {
+			continue
+		}
+		labels = append(labels, metric.Tag{Key: key, Value: tag.Value})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Key < labels[j].Key
+	})
+	seriesKey := (&metric.Metric{Tags: labels}).SeriesKey()
+	name := sanitizePrometheusName(mt.Name, true)
+
+	if mtype == prometheusTypeHistogram || mtype == prometheusTypeSummary {
+		series := &prometheusSeries{labels: labels, buckets: make(map[float64]float64), time: mt.Time, updated: now}
+		for _, field := range mt.Fields {
+			value, ok := metric.ToFloat(field.Value)
+			if !ok {
+				continue
+			}
+			switch field.Key {
+			case "sum":
+				series.sum = value
+			case "count":
+				series.count = value
+			default:
+				bound, err := parsePrometheusValue(field.Key)
+				if err != nil {
+					continue
+				}
+				series.buckets[bound] = value
+			}
+		}
+		proc.store(name, mtype, seriesKey, series)
+		return nil, nil
+	}
+
+	for _, field := range mt.Fields {
+		value, ok := metric.ToFloat(field.Value)
+		if !ok {
+			continue
+		}
+
+		familyName := name
+		familyType := prometheusTypeUntyped
+		switch field.Key {
+		case prometheusTypeCounter, prometheusTypeGauge, "value":
+			switch {
+			case mtype == prometheusTypeCounter || mtype == prometheusTypeGauge:
+				familyType = mtype
+			case field.Key != "value":
+				familyType = field.Key
+			}
+		default:
+			familyName = name + "_" + sanitizePrometheusName(field.Key, true)
+		}
+
+		proc.store(familyName, familyType, seriesKey, &prometheusSeries{labels: labels, value: value, time: mt.Time, updated: now})
+	}
+
+	return nil, nil
+}
+
+// store keeps the series as the latest value in the family
+// The family is reset if its type changes
+func (proc *prometheusExporterProcessor) store(name string, mtype string, key string, series *prometheusSeries) {
+	family, ok := proc.families[name]
+	if ok && family.mtype != mtype {
+		logrus.Warnf("Processor \"%s\" changed the type of \"%s\" from %s to %s", proc.cfg.Name, name, family.mtype, mtype)
+		ok = false
+	}
+	if !ok {
+		family = &prometheusExportFamily{name: name, mtype: mtype, series: make(map[string]*prometheusSeries)}
+		proc.families[name] = family
+	}
+	family.series[key] = series
+}
+
+// expire removes the series not updated within the expiration interval
+func (proc *prometheusExporterProcessor) expire(now time.Time) {
+	if proc.params.ExpirationInterval <= 0 {
+		return
+	}
+
+	for name, family := range proc.families {
+		for key, series := range family.series {
+			if now.Sub(series.updated) > time.Duration(proc.params.ExpirationInterval) {
+				delete(family.series, key)
+			}
+		}
+		if len(family.series) == 0 {
+			delete(proc.families, name)
+		}
+	}
+}
+
+func (proc *prometheusExporterProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	proc.mutex.Lock()
+	defer proc.mutex.Unlock()
+	proc.expire(time.Now())
+	return nil, nil
+}
+
+func (proc *prometheusExporterProcessor) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", prometheusOpenMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", prometheusTextContentType)
+	}
+
+	proc.mutex.Lock()
+	defer proc.mutex.Unlock()
+	proc.expire(time.Now())
+	err := proc.write(w, openMetrics)
+	if err != nil {
+		logrus.Errorf("Processor \"%s\" failed to write metrics: %v", proc.cfg.Name, err)
+	}
+}
+
+// write writes all families in the text exposition format or the OpenMetrics format
+func (proc *prometheusExporterProcessor) write(w io.Writer, openMetrics bool) error {
+	var builder strings.Builder
+	for _, name := range sortedKeys(proc.families) {
+		family := proc.families[name]
+
+		mtype := family.mtype
+		familyName := family.name
+		if openMetrics {
+			if mtype == prometheusTypeUntyped {
+				mtype = "unknown"
+			}
+			if family.mtype == prometheusTypeCounter {
+				familyName = strings.TrimSuffix(familyName, "_total")
+			}
+		}
+		fmt.Fprintf(&builder, "# HELP %s %s\n", familyName, prometheusHelp)
+		fmt.Fprintf(&builder, "# TYPE %s %s\n", familyName, mtype)
+
+		for _, key := range sortedKeys(family.series) {
+			series := family.series[key]
+			switch family.mtype {
+			case prometheusTypeHistogram, prometheusTypeSummary:
+				proc.writeGroup(&builder, familyName, family.mtype, series, openMetrics)
+			case prometheusTypeCounter:
+				sampleName := familyName
+				if openMetrics {
+					sampleName += "_total"
+				}
+				proc.writeSample(&builder, sampleName, series.labels, nil, series.value, series.time, openMetrics)
+			default:
+				proc.writeSample(&builder, familyName, series.labels, nil, series.value, series.time, openMetrics)
+			}
+		}
+	}
+
+	if openMetrics {
+		builder.WriteString("# EOF\n")
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+func (proc *prometheusExporterProcessor) writeGroup(builder *strings.Builder, name string, mtype string, series *prometheusSeries, openMetrics bool) {
+	bounds := make([]float64, 0, len(series.buckets))
+	for bound := range series.buckets {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+
+	if mtype == prometheusTypeHistogram {
+		for _, bound := range bounds {
+			label := metric.Tag{Key: "le", Value: formatPrometheusValue(bound)}
+			proc.writeSample(builder, name+"_bucket", series.labels, &label, series.buckets[bound], series.time, openMetrics)
+		}
+		// the +Inf bucket is mandatory and equals to the count
+		if len(bounds) == 0 || !math.IsInf(bounds[len(bounds)-1], 1) {
+			label := metric.Tag{Key: "le", Value: "+Inf"}
+			proc.writeSample(builder, name+"_bucket", series.labels, &label, series.count, series.time, openMetrics)
+		}
+	} else {
+		for _, bound := range bounds {
+			label := metric.Tag{Key: "quantile", Value: formatPrometheusValue(bound)}
+			proc.writeSample(builder, name, series.labels, &label, series.buckets[bound], series.time, openMetrics)
+		}
+	}
+
+	proc.writeSample(builder, name+"_sum", series.labels, nil, series.sum, series.time, openMetrics)
+	proc.writeSample(builder, name+"_count", series.labels, nil, series.count, series.time, openMetrics)
+}
+
+func (proc *prometheusExporterProcessor) writeSample(builder *strings.Builder, name string, labels []metric.Tag, extra *metric.Tag, value float64, ts time.Time, openMetrics bool) {
+	builder.WriteString(name)
+
+	if len(labels) > 0 || extra != nil {
+		all := labels
+		if extra != nil {
+			all = append(append([]metric.Tag{}, labels...), *extra)
+		}
+		builder.WriteByte('{')
+		for i, label := range all {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			builder.WriteString(label.Key)
+			builder.WriteString("=\"")
+			builder.WriteString(escapePrometheusLabelValue(label.Value))
+			builder.WriteByte('"')
+		}
+		builder.WriteByte('}')
+	}
+
+	builder.WriteByte(' ')
+	builder.WriteString(formatPrometheusValue(value))
+
+	if proc.params.ExportTimestamp && !ts.IsZero() {
+		builder.WriteByte(' ')
+		if openMetrics {
+			builder.WriteString(strconv.FormatFloat(float64(ts.UnixNano())/1e9, 'f', 3, 64))
+		} else {
+			builder.WriteString(strconv.FormatInt(ts.UnixMilli(), 10))
+		}
+	}
+	builder.WriteByte('\n')
+}
+
+func escapePrometheusLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
@@ -0,0 +1,56 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+)
+
+type PrometheusExporterConfig struct {
+	// Address is the address to serve on, e.g. ":9273"
+	Address string `json:"address"`
+	// Path is the HTTP path of the metrics
+	Path string `json:"path"`
+	// ExpirationInterval removes series not updated for the interval, zero means never
+	ExpirationInterval config.Duration `json:"expirationInterval"`
+	// ExportTimestamp adds the metric time to the samples
+	ExportTimestamp bool `json:"exportTimestamp"`
+}
+
+var _ config.CustomConfig = (*PrometheusExporterConfig)(nil)
+
+func NewPrometheusExporterConfig() config.CustomConfig {
+	return &PrometheusExporterConfig{
+		Address:            ":9273",
+		Path:               "/metrics",
+		ExpirationInterval: config.Duration(60 * time.Second),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypePrometheusExporter, NewPrometheusExporterConfig)
+}
+
+func (cfg *PrometheusExporterConfig) Validate() error {
+	if strings.TrimSpace(cfg.Address) == "" {
+		return errors.New("address is required")
+	}
+
+	if !strings.HasPrefix(cfg.Path, "/") {
+		return errors.New("path must start with \"/\"")
+	}
+
+	if cfg.ExpirationInterval < 0 {
+		return errors.New("expirationInterval must not be negative")
+	}
+
+	return nil
+}
+
+func (cfg *PrometheusExporterConfig) UnmarshalJSON(data []byte) error {
+	type plain PrometheusExporterConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
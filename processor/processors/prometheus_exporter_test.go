@@ -0,0 +1,164 @@
+package processors
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func fetchPrometheusExporter(t *testing.T, proc *prometheusExporterProcessor, accept string) (string, string) {
+	req, err := http.NewRequest(http.MethodGet, "http://"+proc.addr().String()+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestPrometheusExporterRoundTrip(t *testing.T) {
+	proc := newTestProcessorFromConfig(t, `
+	{
+		"name": "exporter",
+		"type": "prometheus_exporter",
+		"params": {
+			"address": "127.0.0.1:0"
+		}
+	}`).(*prometheusExporterProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	// feed the metrics parsed from an exposition so that the typing is kept
+	families, err := parsePrometheus(strings.NewReader(prometheusTextExposition), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mt := range prometheusToMetrics(families, testTime) {
+		_, err = proc.OnReceive(mt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// metrics of other processors are exported field by field
+	_, err = proc.OnReceive(metric.Metric{
+		Name:   "cpu.usage-host",
+		Tags:   []metric.Tag{{Key: "host.name", Value: "web\"01"}, {Key: "__reserved", Value: "x"}},
+		Fields: []metric.Field{{Key: "value", Value: 12}, {Key: "idle", Value: 88.5}, {Key: "state", Value: "ok"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contentType, body := fetchPrometheusExporter(t, proc, "")
+	if contentType != prometheusTextContentType {
+		t.Errorf("invalid content type: %s", contentType)
+	}
+	for _, line := range []string{
+		"# TYPE http_requests_total counter",
+		"# HELP http_requests_total " + prometheusHelp,
+		`http_requests_total{code="400",method="post"} 3`,
+		"# TYPE http_request_duration_seconds histogram",
+		`http_request_duration_seconds_bucket{le="0.05"} 24054`,
+		`http_request_duration_seconds_bucket{le="+Inf"} 144320`,
+		"http_request_duration_seconds_count 144320",
+		"# TYPE rpc_duration_seconds summary",
+		`rpc_duration_seconds{quantile="0.99"} 76656`,
+		`cpu_usage_host{host_name="web\"01"} 12`,
+		"# TYPE cpu_usage_host_idle untyped",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("line not found in text exposition: %s", line)
+		}
+	}
+	if strings.Contains(body, "__reserved") || strings.Contains(body, "state") {
+		t.Error("reserved labels and string fields should not be exported")
+	}
+
+	reparsed, err := parsePrometheus(strings.NewReader(body), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := prometheusToMetrics(reparsed, testTime)
+	mt := findMetric(metrics, "http_request_duration_seconds", metric.Tag{Key: prometheusTypeTag, Value: "histogram"})
+	if mt == nil {
+		t.Fatal("histogram not found after round trip")
+	}
+	if value, _ := mt.GetField("0.1"); value != 33444.0 {
+		t.Errorf("invalid bucket after round trip: %v", value)
+	}
+
+	contentType, body = fetchPrometheusExporter(t, proc, "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+	if contentType != prometheusOpenMetricsContentType {
+		t.Errorf("invalid content type: %s", contentType)
+	}
+	for _, line := range []string{
+		"# TYPE http_requests counter",
+		`http_requests_total{code="200",method="post"} 1027`,
+		"# TYPE cpu_usage_host_idle unknown",
+		"# EOF",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("line not found in OpenMetrics exposition: %s", line)
+		}
+	}
+
+	reparsed, err = parsePrometheus(strings.NewReader(body), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics = prometheusToMetrics(reparsed, testTime)
+	mt = findMetric(metrics, "http_requests",
+		metric.Tag{Key: "code", Value: "200"}, metric.Tag{Key: "method", Value: "post"}, metric.Tag{Key: prometheusTypeTag, Value: "counter"})
+	if mt == nil {
+		t.Fatal("counter not found after OpenMetrics round trip")
+	}
+}
+
+func TestPrometheusExporterExpiration(t *testing.T) {
+	proc := newTestProcessorFromConfig(t, `
+	{
+		"name": "exporter",
+		"type": "prometheus_exporter",
+		"params": {
+			"address": "127.0.0.1:0",
+			"expirationInterval": "100ms"
+		}
+	}`).(*prometheusExporterProcessor)
+	err := proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	proc.OnReceive(metric.Metric{Name: "stale", Fields: []metric.Field{{Key: "gauge", Value: 1.0}}})
+	time.Sleep(200 * time.Millisecond)
+	proc.OnReceive(metric.Metric{Name: "fresh", Fields: []metric.Field{{Key: "gauge", Value: 1.0}}})
+
+	_, body := fetchPrometheusExporter(t, proc, "")
+	if strings.Contains(body, "stale") {
+		t.Error("stale series should be expired")
+	}
+	if !strings.Contains(body, "fresh 1\n") {
+		t.Error("fresh series should be exported")
+	}
+}
@@ -0,0 +1,357 @@
+package processors
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+const (
+	prometheusTypeCounter   = "counter"
+	prometheusTypeGauge     = "gauge"
+	prometheusTypeHistogram = "histogram"
+	prometheusTypeSummary   = "summary"
+	prometheusTypeUntyped   = "untyped"
+
+	// prometheusTypeTag keeps the type of the family in the converted metrics
+	prometheusTypeTag = "metric_type"
+)
+
+// prometheusSample is a single sample line of the exposition
+type prometheusSample struct {
+	name      string
+	labels    []metric.Tag
+	value     float64
+	timestamp time.Time
+}
+
+// prometheusFamily groups the samples of a metric family
+type prometheusFamily struct {
+	name    string
+	mtype   string
+	help    string
+	samples []prometheusSample
+}
+
+// prometheusSuffixes maps a type to the suffixes its sample names may have
+var prometheusSuffixes = map[string][]string{
+	prometheusTypeCounter:   {"_total", "_created"},
+	prometheusTypeHistogram: {"_bucket", "_sum", "_count", "_created", "_gsum", "_gcount"},
+	prometheusTypeSummary:   {"_sum", "_count", "_created"},
+	prometheusTypeGauge:     {"_info"},
+}
+
+// parsePrometheus parses the Prometheus text exposition format
+// or the OpenMetrics text format if openMetrics is true
+func parsePrometheus(reader io.Reader, openMetrics bool) ([]*prometheusFamily, error) {
+	families := []*prometheusFamily{}
+	byName := map[string]*prometheusFamily{}
+	getFamily := func(name string) *prometheusFamily {
+		family, ok := byName[name]
+		if !ok {
+			family = &prometheusFamily{name: name, mtype: prometheusTypeUntyped}
+			byName[name] = family
+			families = append(families, family)
+		}
+		return family
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			parts := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+			if len(parts) == 1 && parts[0] == "EOF" {
+				break
+			}
+			if len(parts) < 3 {
+				continue
+			}
+			switch parts[0] {
+			case "HELP":
+				getFamily(parts[1]).help = parts[2]
+			case "TYPE":
+				mtype := parts[2]
+				switch mtype {
+				case prometheusTypeCounter, prometheusTypeGauge, prometheusTypeHistogram, prometheusTypeSummary, prometheusTypeUntyped:
+				case "gaugehistogram":
+					mtype = prometheusTypeHistogram
+				case "info", "stateset":
+					mtype = prometheusTypeGauge
+				case "unknown":
+					mtype = prometheusTypeUntyped
+				default:
+					return nil, fmt.Errorf("line %d: invalid metric type: %s", lineNo, mtype)
+				}
+				getFamily(parts[1]).mtype = mtype
+			}
+			continue
+		}
+
+		sample, err := parsePrometheusSample(line, openMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		family, ok := byName[sample.name]
+		if !ok {
+			for _, candidate := range families {
+				for _, suffix := range prometheusSuffixes[candidate.mtype] {
+					if sample.name == candidate.name+suffix {
+						family = candidate
+						break
+					}
+				}
+				if family != nil {
+					break
+				}
+			}
+		}
+		if family == nil {
+			family = getFamily(sample.name)
+		}
+		family.samples = append(family.samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// drop families declared without samples
+	result := make([]*prometheusFamily, 0, len(families))
+	for _, family := range families {
+		if len(family.samples) > 0 {
+			result = append(result, family)
+		}
+	}
+	return result, nil
+}
+
+func parsePrometheusSample(line string, openMetrics bool) (prometheusSample, error) {
+	sample := prometheusSample{}
+
+	end := strings.IndexAny(line, "{ ")
+	if end <= 0 {
+		return sample, fmt.Errorf("invalid sample: %s", line)
+	}
+	sample.name = line[:end]
+	rest := line[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		labels, remaining, err := parsePrometheusLabels(rest[1:])
+		if err != nil {
+			return sample, err
+		}
+		sample.labels = labels
+		rest = remaining
+	}
+
+	// exemplars are not supported and dropped
+	if idx := strings.Index(rest, " # "); idx >= 0 {
+		rest = rest[:idx]
+	}
+
+	parts := strings.Fields(rest)
+	if len(parts) == 0 || len(parts) > 2 {
+		return sample, fmt.Errorf("invalid sample: %s", line)
+	}
+
+	value, err := parsePrometheusValue(parts[0])
+	if err != nil {
+		return sample, err
+	}
+	sample.value = value
+
+	if len(parts) == 2 {
+		if openMetrics {
+			seconds, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return sample, fmt.Errorf("invalid timestamp: %s", parts[1])
+			}
+			sec, frac := math.Modf(seconds)
+			sample.timestamp = time.Unix(int64(sec), int64(frac*1e9))
+		} else {
+			millis, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return sample, fmt.Errorf("invalid timestamp: %s", parts[1])
+			}
+			sample.timestamp = time.UnixMilli(millis)
+		}
+	}
+
+	return sample, nil
+}
+
+// parsePrometheusLabels parses the labels after "{" and returns the data after "}"
+func parsePrometheusLabels(data string) ([]metric.Tag, string, error) {
+	labels := []metric.Tag{}
+	for {
+		data = strings.TrimLeft(data, " ,")
+		if strings.HasPrefix(data, "}") {
+			return labels, data[1:], nil
+		}
+
+		eq := strings.Index(data, "=\"")
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("invalid labels: %s", data)
+		}
+		name := strings.TrimSpace(data[:eq])
+		data = data[eq+2:]
+
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(data); i++ {
+			if data[i] == '\\' && i+1 < len(data) {
+				switch data[i+1] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(data[i+1])
+				}
+				i++
+			} else if data[i] == '"' {
+				data = data[i+1:]
+				closed = true
+				break
+			} else {
+				value.WriteByte(data[i])
+			}
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("unterminated label value of %s", name)
+		}
+		labels = append(labels, metric.Tag{Key: name, Value: value.String()})
+	}
+}
+
+func parsePrometheusValue(str string) (float64, error) {
+	switch str {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %s", str)
+	}
+	return value, nil
+}
+
+// formatPrometheusValue formats a value as in the exposition formats
+func formatPrometheusValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// prometheusToMetrics converts the families to metrics
+// Counters, gauges and untyped families produce a metric per sample
+// with a "counter", "gauge" or "value" field
+// Histograms and summaries produce a metric per label set
+// with a field per bucket or quantile, a "sum" and a "count" field
+func prometheusToMetrics(families []*prometheusFamily, now time.Time) []metric.Metric {
+	out := []metric.Metric{}
+	for _, family := range families {
+		switch family.mtype {
+		case prometheusTypeHistogram, prometheusTypeSummary:
+			out = append(out, prometheusGroupToMetrics(family, now)...)
+		default:
+			field := "value"
+			if family.mtype == prometheusTypeCounter || family.mtype == prometheusTypeGauge {
+				field = family.mtype
+			}
+			for _, sample := range family.samples {
+				if strings.HasSuffix(sample.name, "_created") && sample.name != family.name {
+					continue
+				}
+				mt := metric.Metric{Name: family.name, Time: now}
+				if !sample.timestamp.IsZero() {
+					mt.Time = sample.timestamp
+				}
+				mt.Tags = append(mt.Tags, sample.labels...)
+				mt.AddTag(prometheusTypeTag, family.mtype)
+				mt.AddField(field, sample.value)
+				out = append(out, mt)
+			}
+		}
+	}
+	return out
+}
+
+func prometheusGroupToMetrics(family *prometheusFamily, now time.Time) []metric.Metric {
+	bucketLabel := "le"
+	if family.mtype == prometheusTypeSummary {
+		bucketLabel = "quantile"
+	}
+
+	groups := map[string]*metric.Metric{}
+	keys := []string{}
+	for _, sample := range family.samples {
+		var bound string
+		tags := make([]metric.Tag, 0, len(sample.labels)+1)
+		for _, label := range sample.labels {
+			if label.Key == bucketLabel {
+				bound = label.Value
+			} else {
+				tags = append(tags, label)
+			}
+		}
+		tags = append(tags, metric.Tag{Key: prometheusTypeTag, Value: family.mtype})
+
+		group := metric.Metric{Name: family.name, Tags: tags}
+		key := group.SeriesKey()
+		mt, ok := groups[key]
+		if !ok {
+			group.Time = now
+			mt = &group
+			groups[key] = mt
+			keys = append(keys, key)
+		}
+		if !sample.timestamp.IsZero() {
+			mt.Time = sample.timestamp
+		}
+
+		switch strings.TrimPrefix(sample.name, family.name) {
+		case "_sum", "_gsum":
+			mt.AddField("sum", sample.value)
+		case "_count", "_gcount":
+			mt.AddField("count", sample.value)
+		case "_bucket", "":
+			if bound != "" {
+				value, err := parsePrometheusValue(bound)
+				if err == nil {
+					bound = formatPrometheusValue(value)
+				}
+				mt.AddField(bound, sample.value)
+			}
+		}
+	}
+
+	out := make([]metric.Metric, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, *groups[key])
+	}
+	return out
+}
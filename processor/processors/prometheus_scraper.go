@@ -0,0 +1,241 @@
+package processors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypePrometheusScraper = "prometheus_scraper"
+
+	prometheusAcceptHeader = "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypePrometheusScraper, NewPrometheusScraperProcessor)
+}
+
+// prometheusTarget is a scrape target with the labels attached to its metrics
+type prometheusTarget struct {
+	url    string
+	labels map[string]string
+}
+
+// prometheusFileSDGroup is a target group in the Prometheus file based discovery format
+type prometheusFileSDGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+type prometheusScraperProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *PrometheusScraperConfig
+	client *http.Client
+	job    string
+}
+
+var _ processor.Processor = (*prometheusScraperProcessor)(nil)
+
+// NewPrometheusScraperProcessor creates a new prometheus scraper processor
+func NewPrometheusScraperProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*PrometheusScraperConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for prometheus scraper processor: %T", cfg.Params)
+	}
+
+	job := params.Job
+	if job == "" {
+		job = cfg.Name
+	}
+
+	return &prometheusScraperProcessor{
+		cfg:    cfg,
+		params: params,
+		job:    job,
+	}, nil
+}
+
+func (proc *prometheusScraperProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *prometheusScraperProcessor) Setup(emitter processor.Emitter) error {
+	proc.client = &http.Client{Timeout: time.Duration(proc.params.Timeout)}
+	return nil
+}
+
+func (proc *prometheusScraperProcessor) Close() error {
+	if proc.client != nil {
+		proc.client.CloseIdleConnections()
+		proc.client = nil
+	}
+	return nil
+}
+
+func (proc *prometheusScraperProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *prometheusScraperProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	targets := proc.targets()
+
+	results := make([][]metric.Metric, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target prometheusTarget) {
+			defer wg.Done()
+			results[i] = proc.scrape(target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	out := []metric.Metric{}
+	for _, result := range results {
+		out = append(out, result...)
+	}
+	return out, nil
+}
+
+// targets returns the static targets and the targets discovered from files
+func (proc *prometheusScraperProcessor) targets() []prometheusTarget {
+	targets := []prometheusTarget{}
+	for _, u := range proc.params.URLs {
+		targets = append(targets, prometheusTarget{url: u})
+	}
+
+	for _, file := range proc.params.FileSD {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			logrus.Errorf("Processor \"%s\" failed to read discovery file \"%s\": %v", proc.cfg.Name, file, err)
+			continue
+		}
+
+		var groups []prometheusFileSDGroup
+		err = json.Unmarshal(content, &groups)
+		if err != nil {
+			logrus.Errorf("Processor \"%s\" failed to parse discovery file \"%s\": %v", proc.cfg.Name, file, err)
+			continue
+		}
+
+		for _, group := range groups {
+			for _, host := range group.Targets {
+				u := url.URL{Scheme: proc.params.Scheme, Host: host, Path: proc.params.MetricsPath}
+				targets = append(targets, prometheusTarget{url: u.String(), labels: group.Labels})
+			}
+		}
+	}
+
+	return targets
+}
+
+// scrape scrapes a target and returns its metrics along with an "up" metric
+func (proc *prometheusScraperProcessor) scrape(target prometheusTarget) []metric.Metric {
+	start := time.Now()
+	instance := target.url
+	if u, err := url.Parse(target.url); err == nil {
+		instance = u.Host
+	}
+
+	metrics, err := proc.fetch(target.url, start)
+	up := 1.0
+	if err != nil {
+		logrus.Errorf("Processor \"%s\" failed to scrape \"%s\": %v", proc.cfg.Name, target.url, err)
+		up = 0
+		metrics = nil
+	}
+
+	upMetric := metric.Metric{
+		Name: "up",
+		Tags: []metric.Tag{{Key: prometheusTypeTag, Value: prometheusTypeGauge}},
+		Fields: []metric.Field{
+			{Key: "gauge", Value: up},
+		},
+		Time: start,
+	}
+	durationMetric := metric.Metric{
+		Name: "scrape_duration_seconds",
+		Tags: []metric.Tag{{Key: prometheusTypeTag, Value: prometheusTypeGauge}},
+		Fields: []metric.Field{
+			{Key: "gauge", Value: time.Since(start).Seconds()},
+		},
+		Time: start,
+	}
+	metrics = append(metrics, upMetric, durationMetric)
+
+	for i := range metrics {
+		proc.addTargetTags(&metrics[i], instance, target.labels)
+	}
+	return metrics
+}
+
+func (proc *prometheusScraperProcessor) fetch(u string, now time.Time) ([]metric.Metric, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(proc.params.Timeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", prometheusAcceptHeader)
+
+	resp, err := proc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	openMetrics := strings.HasPrefix(resp.Header.Get("Content-Type"), "application/openmetrics-text")
+	families, err := parsePrometheus(resp.Body, openMetrics)
+	if err != nil {
+		return nil, err
+	}
+	return prometheusToMetrics(families, now), nil
+}
+
+// addTargetTags adds the instance, job and discovery labels to the metric
+func (proc *prometheusScraperProcessor) addTargetTags(mt *metric.Metric, instance string, labels map[string]string) {
+	targetTags := map[string]string{"instance": instance, "job": proc.job}
+	for key, value := range labels {
+		targetTags[key] = value
+	}
+
+	for _, key := range sortedKeys(targetTags) {
+		conflict := -1
+		for i, tag := range mt.Tags {
+			if tag.Key == key {
+				conflict = i
+				break
+			}
+		}
+
+		if conflict >= 0 {
+			if proc.params.HonorLabels {
+				continue
+			}
+			mt.Tags[conflict].Key = "exported_" + key
+		}
+		mt.Tags = append(mt.Tags, metric.Tag{Key: key, Value: targetTags[key]})
+	}
+}
@@ -0,0 +1,74 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+)
+
+type PrometheusScraperConfig struct {
+	// URLs are the static targets to scrape
+	URLs []string `json:"urls"`
+	// FileSD are files in the Prometheus file based discovery format,
+	// which are read before every scrape
+	FileSD []string `json:"fileSd"`
+	// Scheme and MetricsPath build the URLs of the discovered targets
+	Scheme      string `json:"scheme"`
+	MetricsPath string `json:"metricsPath"`
+	// Job is the value of the job tag, the processor name by default
+	Job string `json:"job"`
+	// HonorLabels keeps the instance and job labels of the scraped metrics
+	// Otherwise they are renamed to exported_instance and exported_job
+	HonorLabels bool `json:"honorLabels"`
+	// Timeout of a single scrape
+	Timeout config.Duration `json:"timeout"`
+}
+
+var _ config.CustomConfig = (*PrometheusScraperConfig)(nil)
+
+func NewPrometheusScraperConfig() config.CustomConfig {
+	return &PrometheusScraperConfig{
+		Scheme:      "http",
+		MetricsPath: "/metrics",
+		Timeout:     config.Duration(10 * time.Second),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypePrometheusScraper, NewPrometheusScraperConfig)
+}
+
+func (cfg *PrometheusScraperConfig) Validate() error {
+	if len(cfg.URLs) == 0 && len(cfg.FileSD) == 0 {
+		return errors.New("either urls or fileSd is required")
+	}
+
+	for _, str := range cfg.URLs {
+		u, err := url.Parse(str)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid scrape url: %s", str)
+		}
+	}
+
+	if cfg.Scheme != "http" && cfg.Scheme != "https" {
+		return fmt.Errorf("invalid scheme: %s", cfg.Scheme)
+	}
+
+	if cfg.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+
+	return nil
+}
+
+func (cfg *PrometheusScraperConfig) UnmarshalJSON(data []byte) error {
+	type plain PrometheusScraperConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
@@ -0,0 +1,231 @@
+package processors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+var testTime = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+const prometheusTextExposition = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+# TYPE temperature gauge
+temperature{room="a \"b\"\\c"} -3.5
+# A histogram
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.05"} 24054
+http_request_duration_seconds_bucket{le="0.1"} 33444
+http_request_duration_seconds_bucket{le="+Inf"} 144320
+http_request_duration_seconds_sum 53423
+http_request_duration_seconds_count 144320
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 4773
+rpc_duration_seconds{quantile="0.99"} 76656
+rpc_duration_seconds_sum 1.7560473e+07
+rpc_duration_seconds_count 2693
+untyped_metric{instance="exporter:9100"} 42
+`
+
+const prometheusOpenMetricsExposition = `# TYPE acme_http_router_request_seconds summary
+# UNIT acme_http_router_request_seconds seconds
+acme_http_router_request_seconds_sum{path="/api/v1",method="GET"} 9036.32
+acme_http_router_request_seconds_count{path="/api/v1",method="GET"} 807283.0
+acme_http_router_request_seconds_created{path="/api/v1",method="GET"} 1605281325.0
+# TYPE foo counter
+foo_total 17.0 1520879607.789 # {id="counter-test"} 5
+foo_created 1520430000.123
+# EOF
+`
+
+func TestParsePrometheusText(t *testing.T) {
+	families, err := parsePrometheus(strings.NewReader(prometheusTextExposition), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 5 {
+		t.Fatalf("invalid number of families: %d", len(families))
+	}
+
+	metrics := prometheusToMetrics(families, testTime)
+	mt := findMetric(metrics, "http_requests_total",
+		metric.Tag{Key: "method", Value: "post"}, metric.Tag{Key: "code", Value: "400"}, metric.Tag{Key: prometheusTypeTag, Value: "counter"})
+	if mt == nil {
+		t.Fatal("counter not found")
+	}
+	if value, _ := mt.GetField("counter"); value != 3.0 {
+		t.Errorf("invalid counter value: %v", value)
+	}
+	if mt.Time.UnixMilli() != 1395066363000 {
+		t.Errorf("invalid timestamp: %v", mt.Time)
+	}
+
+	mt = findMetric(metrics, "temperature", metric.Tag{Key: "room", Value: `a "b"\c`}, metric.Tag{Key: prometheusTypeTag, Value: "gauge"})
+	if mt == nil {
+		t.Fatal("gauge with escaped label not found")
+	}
+
+	mt = findMetric(metrics, "http_request_duration_seconds", metric.Tag{Key: prometheusTypeTag, Value: "histogram"})
+	if mt == nil {
+		t.Fatal("histogram not found")
+	}
+	for key, value := range map[string]float64{"0.05": 24054, "0.1": 33444, "+Inf": 144320, "sum": 53423, "count": 144320} {
+		if actual, _ := mt.GetField(key); actual != value {
+			t.Errorf("invalid histogram field %s: %v", key, actual)
+		}
+	}
+
+	mt = findMetric(metrics, "rpc_duration_seconds", metric.Tag{Key: prometheusTypeTag, Value: "summary"})
+	if mt == nil {
+		t.Fatal("summary not found")
+	}
+	if value, _ := mt.GetField("0.99"); value != 76656.0 {
+		t.Errorf("invalid quantile: %v", value)
+	}
+
+	mt = findMetric(metrics, "untyped_metric", metric.Tag{Key: "instance", Value: "exporter:9100"}, metric.Tag{Key: prometheusTypeTag, Value: "untyped"})
+	if mt == nil {
+		t.Fatal("untyped metric not found")
+	}
+}
+
+func TestParsePrometheusOpenMetrics(t *testing.T) {
+	families, err := parsePrometheus(strings.NewReader(prometheusOpenMetricsExposition), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := prometheusToMetrics(families, testTime)
+	if len(metrics) != 2 {
+		t.Fatalf("invalid number of metrics: %d", len(metrics))
+	}
+
+	mt := findMetric(metrics, "acme_http_router_request_seconds",
+		metric.Tag{Key: "path", Value: "/api/v1"}, metric.Tag{Key: "method", Value: "GET"}, metric.Tag{Key: prometheusTypeTag, Value: "summary"})
+	if mt == nil {
+		t.Fatal("summary not found")
+	}
+	if value, _ := mt.GetField("count"); value != 807283.0 {
+		t.Errorf("invalid count: %v", value)
+	}
+
+	mt = findMetric(metrics, "foo", metric.Tag{Key: prometheusTypeTag, Value: "counter"})
+	if mt == nil {
+		t.Fatal("counter not found")
+	}
+	if value, _ := mt.GetField("counter"); value != 17.0 {
+		t.Errorf("invalid counter value: %v", value)
+	}
+	if mt.Time.UnixMilli() != 1520879607789 {
+		t.Errorf("invalid timestamp: %v", mt.Time)
+	}
+}
+
+func TestParsePrometheusInvalid(t *testing.T) {
+	for _, data := range []string{"metric{label=\"unterminated} 1", "metric not_a_number", "# TYPE metric invalid\nmetric 1"} {
+		_, err := parsePrometheus(strings.NewReader(data), false)
+		if err == nil {
+			t.Errorf("exposition should be invalid: %s", data)
+		}
+	}
+}
+
+func TestPrometheusScrape(t *testing.T) {
+	textServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, prometheusTextExposition)
+	}))
+	defer textServer.Close()
+
+	omServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/custom" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		fmt.Fprint(w, prometheusOpenMetricsExposition)
+	}))
+	defer omServer.Close()
+	omURL, _ := url.Parse(omServer.URL)
+
+	// the OpenMetrics server is discovered from a file
+	sdFile := filepath.Join(t.TempDir(), "targets.json")
+	sdContent := fmt.Sprintf(`[{"targets": ["%s"], "labels": {"env": "prod"}}]`, omURL.Host)
+	err := ioutil.WriteFile(sdFile, []byte(sdContent), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+	{
+		"name": "scraper",
+		"type": "prometheus_scraper",
+		"cronSpec": "@every 10s",
+		"params": {
+			"urls": ["%s/metrics", "http://127.0.0.1:1/metrics"],
+			"fileSd": ["%s"],
+			"metricsPath": "/custom",
+			"job": "node",
+			"timeout": "2s"
+		}
+	}`, textServer.URL, sdFile)).(*prometheusScraperProcessor)
+	err = proc.Setup(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Close()
+
+	out, err := proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	textHost := strings.TrimPrefix(textServer.URL, "http://")
+	mt := findMetric(out, "http_requests_total",
+		metric.Tag{Key: "method", Value: "post"}, metric.Tag{Key: "code", Value: "200"}, metric.Tag{Key: prometheusTypeTag, Value: "counter"},
+		metric.Tag{Key: "instance", Value: textHost}, metric.Tag{Key: "job", Value: "node"})
+	if mt == nil {
+		t.Error("scraped counter not found")
+	}
+
+	// conflicting labels are kept as exported labels
+	mt = findMetric(out, "untyped_metric",
+		metric.Tag{Key: "exported_instance", Value: "exporter:9100"}, metric.Tag{Key: prometheusTypeTag, Value: "untyped"},
+		metric.Tag{Key: "instance", Value: textHost}, metric.Tag{Key: "job", Value: "node"})
+	if mt == nil {
+		t.Error("metric with conflicting label not found")
+	}
+
+	mt = findMetric(out, "foo", metric.Tag{Key: prometheusTypeTag, Value: "counter"},
+		metric.Tag{Key: "instance", Value: omURL.Host}, metric.Tag{Key: "job", Value: "node"}, metric.Tag{Key: "env", Value: "prod"})
+	if mt == nil {
+		t.Error("discovered counter not found")
+	}
+
+	cntUp := 0
+	cntDown := 0
+	for _, mt := range out {
+		if mt.Name != "up" {
+			continue
+		}
+		value, _ := mt.GetField("gauge")
+		if value == 1.0 {
+			cntUp++
+		} else {
+			cntDown++
+		}
+	}
+	if cntUp != 2 || cntDown != 1 {
+		t.Errorf("invalid up metrics: %d up, %d down", cntUp, cntDown)
+	}
+}
@@ -0,0 +1,233 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeRate = "rate"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeRate, NewRateProcessor)
+}
+
+// rateSample is the previous sample of a field
+// Non-negative integers are kept exactly so that 64-bit counters wrap precisely
+type rateSample struct {
+	isUint  bool
+	uint    uint64
+	float   float64
+	time    time.Time
+	updated time.Time
+}
+
+func newRateSample(value interface{}, t time.Time, now time.Time) (*rateSample, bool) {
+	sample := &rateSample{time: t, updated: now}
+	switch v := value.(type) {
+	case uint64:
+		sample.isUint, sample.uint = true, v
+	case uint, uint8, uint16, uint32, int, int8, int16, int32, int64:
+		f, _ := metric.ToFloat(v)
+		if f >= 0 {
+			i := normalizeInt(v)
+			sample.isUint, sample.uint = true, uint64(i)
+		}
+	}
+
+	var ok bool
+	sample.float, ok = numericValue(value)
+	return sample, ok
+}
+
+// normalizeInt converts a signed or an unsigned integer up to 32 bits or a signed one of 64 bits to int64
+func normalizeInt(value interface{}) int64 {
+	switch v := value.(type) {
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	}
+	return value.(int64)
+}
+
+type rateProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *RateConfig
+	// samples maps the series keys and the field keys to the previous samples
+	samples map[string]map[string]*rateSample
+	// expired is the time of the last expiry, which also runs on receiving at most once per TTL
+	expired time.Time
+}
+
+var _ processor.Processor = (*rateProcessor)(nil)
+
+// NewRateProcessor creates a new rate processor
+func NewRateProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*RateConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for rate processor: %T", cfg.Params)
+	}
+
+	return &rateProcessor{
+		cfg:     cfg,
+		params:  params,
+		samples: make(map[string]map[string]*rateSample),
+	}, nil
+}
+
+func (proc *rateProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *rateProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *rateProcessor) Close() error {
+	return nil
+}
+
+func (proc *rateProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	mt = proc.process(mt, time.Now())
+	if len(mt.Fields) == 0 {
+		return []metric.Metric{}, nil
+	}
+	return []metric.Metric{mt}, nil
+}
+
+// process computes the fields of the metric from the previous samples of its series
+// Metrics without a time are sampled at the receive time
+func (proc *rateProcessor) process(mt metric.Metric, now time.Time) metric.Metric {
+	params := proc.params
+	if now.Sub(proc.expired) >= time.Duration(params.TTL) {
+		proc.expire(now)
+	}
+
+	key := mt.SeriesKey()
+	series, ok := proc.samples[key]
+	if !ok {
+		series = make(map[string]*rateSample)
+		proc.samples[key] = series
+	}
+
+	fields := make([]metric.Field, 0, len(mt.Fields)*2)
+	var computed []metric.Field
+	for _, field := range mt.Fields {
+		if len(params.fields) > 0 && !params.fields.match(field.Key) {
+			fields = append(fields, field)
+			continue
+		}
+		sample, ok := newRateSample(field.Value, metricTime(mt, now), now)
+		if !ok {
+			fields = append(fields, field)
+			continue
+		}
+		if !params.Replace {
+			fields = append(fields, field)
+		}
+
+		previous, ok := series[field.Key]
+		if ok && now.Sub(previous.updated) > time.Duration(params.TTL) {
+			ok = false
+		}
+		if ok && !sample.time.After(previous.time) {
+			continue
+		}
+		series[field.Key] = sample
+
+		var value float64
+		if ok {
+			value = proc.compute(previous, sample)
+		} else if params.FirstSample == rateFirstSampleDrop {
+			continue
+		}
+
+		if params.Replace {
+			fields = append(fields, metric.Field{Key: field.Key, Value: value})
+		} else {
+			computed = append(computed, metric.Field{Key: field.Key + params.suffix, Value: value})
+		}
+	}
+
+	mt.Fields = append(fields, computed...)
+	return mt
+}
+
+func (proc *rateProcessor) compute(previous, current *rateSample) float64 {
+	if proc.params.Mode == rateModeDerivative {
+		return (current.float - previous.float) / current.time.Sub(previous.time).Seconds()
+	}
+
+	delta := proc.counterDelta(previous, current)
+	if proc.params.Mode == rateModeDelta {
+		return delta
+	}
+	return delta / current.time.Sub(previous.time).Seconds()
+}
+
+// counterDelta returns the increase of a counter considering wraparounds and resets
+func (proc *rateProcessor) counterDelta(previous, current *rateSample) float64 {
+	if current.float >= previous.float {
+		if current.isUint && previous.isUint {
+			return float64(current.uint - previous.uint)
+		}
+		return current.float - previous.float
+	}
+
+	bits := proc.params.CounterBits
+	if bits > 0 && current.isUint && previous.isUint && (bits == 64 || previous.uint <= math.MaxUint32) {
+		// the difference modulo the counter range
+		wrapped := current.uint - previous.uint
+		if bits == 32 {
+			wrapped = uint64(uint32(wrapped))
+		}
+		if wrapped < 1<<(bits-1) {
+			return float64(wrapped)
+		}
+	}
+	return current.float
+}
+
+func (proc *rateProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	proc.expire(time.Now())
+	return nil, nil
+}
+
+// expire removes the samples not updated within the TTL
+func (proc *rateProcessor) expire(now time.Time) {
+	proc.expired = now
+	for key, series := range proc.samples {
+		for field, sample := range series {
+			if now.Sub(sample.updated) > time.Duration(proc.params.TTL) {
+				delete(series, field)
+			}
+		}
+		if len(series) == 0 {
+			delete(proc.samples, key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+)
+
+// Modes of the rate processor
+const (
+	rateModeRate       = "rate"
+	rateModeDelta      = "delta"
+	rateModeDerivative = "derivative"
+)
+
+// Behaviours of the rate processor on the first sample of a series
+const (
+	rateFirstSampleDrop = "drop"
+	rateFirstSampleZero = "zero"
+)
+
+// RateConfig computes the change of the numeric fields of every series since the previous sample
+// Rates and deltas treat the fields as counters: a decrease is a wraparound if counterBits is set
+// and the wrapped delta is less than half of the counter range, otherwise it is a reset
+// and the delta is the current value, as the counter restarted from zero
+// Derivatives are the per second changes of gauges, which may be negative
+// Samples not newer than the previous one are skipped
+type RateConfig struct {
+	// Mode is either "rate" for the per second change of counters, "delta" for the change of counters
+	// or "derivative" for the per second change of gauges
+	Mode string `json:"mode"`
+	// Fields are patterns of the field keys, all numeric fields if empty
+	Fields []string `json:"fields"`
+	// Suffix is appended to the keys of the computed fields, "_<mode>" if empty
+	Suffix string `json:"suffix"`
+	// Replace replaces the values of the fields instead of adding fields
+	Replace bool `json:"replace"`
+	// CounterBits is 32 or 64 to detect wraparounds of counters, or 0 to treat any decrease as a reset
+	CounterBits int `json:"counterBits"`
+	// FirstSample is either "drop" to compute nothing for the first sample of a series or "zero" to compute 0
+	// Metrics left without fields are dropped
+	FirstSample string `json:"firstSample"`
+	// TTL evicts the series without samples for the duration
+	TTL config.Duration `json:"ttl"`
+
+	fields patternList
+	suffix string
+}
+
+var _ config.CustomConfig = (*RateConfig)(nil)
+
+func NewRateConfig() config.CustomConfig {
+	return &RateConfig{
+		Mode:        rateModeRate,
+		FirstSample: rateFirstSampleDrop,
+		TTL:         config.Duration(10 * time.Minute),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeRate, NewRateConfig)
+}
+
+func (cfg *RateConfig) Validate() error {
+	switch cfg.Mode {
+	case rateModeRate, rateModeDelta, rateModeDerivative:
+	default:
+		return fmt.Errorf("invalid mode: %s", cfg.Mode)
+	}
+
+	if cfg.CounterBits != 0 && cfg.CounterBits != 32 && cfg.CounterBits != 64 {
+		return fmt.Errorf("invalid counter bits: %d", cfg.CounterBits)
+	}
+
+	if cfg.FirstSample != rateFirstSampleDrop && cfg.FirstSample != rateFirstSampleZero {
+		return fmt.Errorf("invalid first sample behaviour: %s", cfg.FirstSample)
+	}
+
+	if cfg.TTL <= 0 {
+		return fmt.Errorf("invalid ttl: %v", time.Duration(cfg.TTL))
+	}
+
+	cfg.suffix = cfg.Suffix
+	if cfg.suffix == "" {
+		cfg.suffix = "_" + cfg.Mode
+	}
+
+	var err error
+	cfg.fields, err = compilePatterns(cfg.Fields)
+	return err
+}
+
+func (cfg *RateConfig) UnmarshalJSON(data []byte) error {
+	type plain RateConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
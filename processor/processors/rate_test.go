@@ -0,0 +1,189 @@
+package processors
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func newRateMetric(seconds int, fields ...metric.Field) metric.Metric {
+	return metric.Metric{
+		Name:   "net",
+		Tags:   []metric.Tag{{Key: "interface", Value: "eth0"}},
+		Fields: fields,
+		Time:   time.Unix(int64(seconds), 0),
+	}
+}
+
+func TestRateCounters(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeRate, `{"fields": ["bytes*"]}`).(*rateProcessor)
+
+	out, err := proc.OnReceive(newRateMetric(0, metric.Field{Key: "bytes", Value: int64(100)}, metric.Field{Key: "errors", Value: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []metric.Field{{Key: "bytes", Value: int64(100)}, {Key: "errors", Value: 1}}
+	if len(out) != 1 || !reflect.DeepEqual(out[0].Fields, expected) {
+		t.Fatalf("the first sample should be passed without rates: %v", out)
+	}
+
+	now := time.Now()
+	cases := []struct {
+		seconds int
+		value   interface{}
+		rate    interface{}
+	}{
+		{10, int64(600), 50.0},
+		// resets restart from zero
+		{20, int64(300), 30.0},
+		// samples not newer than the previous one are skipped
+		{20, int64(500), nil},
+		{15, int64(500), nil},
+		{30, 400.5, 10.05},
+	}
+	for _, c := range cases {
+		mt := proc.process(newRateMetric(c.seconds, metric.Field{Key: "bytes", Value: c.value}), now)
+		rate, err := mt.GetField("bytes_rate")
+		if c.rate == nil {
+			if err == nil {
+				t.Errorf("sample at %d should be skipped but got %v", c.seconds, rate)
+			}
+			continue
+		}
+		if err != nil || math.Abs(rate.(float64)-c.rate.(float64)) > 1e-9 {
+			t.Errorf("expected rate %v at %d but got %v", c.rate, c.seconds, rate)
+		}
+	}
+}
+
+func TestRateWraparound(t *testing.T) {
+	cases := []struct {
+		bits     int
+		previous interface{}
+		current  interface{}
+		delta    float64
+	}{
+		{32, uint32(math.MaxUint32 - 9), uint32(5), 15},
+		{32, int64(math.MaxUint32), int64(0), 1},
+		// a decrease beyond half of the range is a reset
+		{32, int64(1000), int64(5), 5},
+		{64, uint64(math.MaxUint64 - 4), uint64(10), 15},
+		{64, uint64(1 << 63), uint64(1<<63 + 3), 3},
+		{0, uint32(math.MaxUint32 - 9), uint32(5), 5},
+	}
+	for _, c := range cases {
+		proc := newTestProcessor(t, ProcessorTypeRate, `{"mode": "delta", "counterBits": `+strconv.Itoa(c.bits)+`}`).(*rateProcessor)
+		now := time.Now()
+		proc.process(newRateMetric(0, metric.Field{Key: "packets", Value: c.previous}), now)
+		mt := proc.process(newRateMetric(1, metric.Field{Key: "packets", Value: c.current}), now)
+		delta, err := mt.GetField("packets_delta")
+		if err != nil || delta != c.delta {
+			t.Errorf("%d bits from %v to %v: expected delta %v but got %v", c.bits, c.previous, c.current, c.delta, delta)
+		}
+	}
+}
+
+func TestRateDerivativeReplaceAndZero(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeRate, `{"mode": "derivative", "replace": true, "firstSample": "zero"}`).(*rateProcessor)
+	now := time.Now()
+
+	mt := proc.process(newRateMetric(0, metric.Field{Key: "temperature", Value: 20.0}, metric.Field{Key: "unit", Value: "C"}), now)
+	expected := []metric.Field{{Key: "temperature", Value: 0.0}, {Key: "unit", Value: "C"}}
+	if !reflect.DeepEqual(mt.Fields, expected) {
+		t.Errorf("expected %v but got %v", expected, mt.Fields)
+	}
+
+	mt = proc.process(newRateMetric(4, metric.Field{Key: "temperature", Value: 18.0}), now)
+	if !reflect.DeepEqual(mt.Fields, []metric.Field{{Key: "temperature", Value: -0.5}}) {
+		t.Errorf("expected a negative derivative but got %v", mt.Fields)
+	}
+}
+
+func TestRateDropFirstAndTTL(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeRate, `{"replace": true, "ttl": "1m"}`).(*rateProcessor)
+	now := time.Now()
+
+	mt := proc.process(newRateMetric(0, metric.Field{Key: "requests", Value: 10}), now)
+	if len(mt.Fields) != 0 {
+		t.Errorf("the first sample should be dropped: %v", mt.Fields)
+	}
+	if out, _ := proc.OnReceive(newRateMetric(0, metric.Field{Key: "other", Value: 10})); out == nil || len(out) != 0 {
+		t.Errorf("metrics without fields should be dropped: %v", out)
+	}
+
+	// samples of idle series are not used
+	mt = proc.process(newRateMetric(120, metric.Field{Key: "requests", Value: 70}), now.Add(2*time.Minute))
+	if len(mt.Fields) != 0 {
+		t.Errorf("the sample after the TTL should be the first: %v", mt.Fields)
+	}
+	mt = proc.process(newRateMetric(130, metric.Field{Key: "requests", Value: 80}), now.Add(2*time.Minute))
+	if !reflect.DeepEqual(mt.Fields, []metric.Field{{Key: "requests", Value: 1.0}}) {
+		t.Errorf("expected rate 1 but got %v", mt.Fields)
+	}
+
+	proc.expire(now.Add(2 * time.Minute))
+	if len(proc.samples) != 1 {
+		t.Errorf("expected 1 series but got %d", len(proc.samples))
+	}
+	proc.expire(now.Add(4 * time.Minute))
+	if len(proc.samples) != 0 {
+		t.Errorf("idle series should be evicted: %v", proc.samples)
+	}
+}
+
+func TestRateExpireOnReceive(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeRate, `{"ttl": "1m"}`).(*rateProcessor)
+	now := time.Now()
+
+	proc.process(newRateMetric(0, metric.Field{Key: "requests", Value: 10}), now)
+	other := newRateMetric(30, metric.Field{Key: "requests", Value: 10})
+	other.Tags = []metric.Tag{{Key: "host", Value: "web02"}}
+	proc.process(other, now.Add(30*time.Second))
+	if len(proc.samples) != 2 {
+		t.Fatalf("expected 2 series but got %d", len(proc.samples))
+	}
+
+	// idle series are evicted without cron triggers
+	proc.process(other, now.Add(90*time.Second))
+	if _, ok := proc.samples[other.SeriesKey()]; !ok || len(proc.samples) != 1 {
+		t.Errorf("idle series should be evicted on receiving: %v", proc.samples)
+	}
+}
+
+func TestRateReceiveTime(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeRate, `{}`).(*rateProcessor)
+	now := time.Now()
+
+	mt := metric.Metric{Name: "net", Fields: []metric.Field{{Key: "bytes", Value: 100}}}
+	proc.process(mt, now)
+	mt.Fields = []metric.Field{{Key: "bytes", Value: 300}}
+	mt = proc.process(mt, now.Add(10*time.Second))
+	expected := []metric.Field{{Key: "bytes", Value: 300}, {Key: "bytes_rate", Value: 20.0}}
+	if !reflect.DeepEqual(mt.Fields, expected) {
+		t.Errorf("metrics without a time should be sampled at the receive time: expected %v but got %v", expected, mt.Fields)
+	}
+}
+
+func TestRateConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{"mode": "ratio"}`,
+		`{"counterBits": 16}`,
+		`{"firstSample": "keep"}`,
+		`{"ttl": "0s"}`,
+		`{"fields": ["/(/"]}`,
+	} {
+		cfg := NewRateConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package processors
+
+import (
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeRouter = "router"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeRouter, NewRouterProcessor)
+}
+
+type routerProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *RouterConfig
+}
+
+var _ processor.Processor = (*routerProcessor)(nil)
+var _ processor.Router = (*routerProcessor)(nil)
+
+// NewRouterProcessor creates a new router processor
+func NewRouterProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*RouterConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for router processor: %T", cfg.Params)
+	}
+
+	return &routerProcessor{
+		cfg:    cfg,
+		params: params,
+	}, nil
+}
+
+func (proc *routerProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *routerProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *routerProcessor) Close() error {
+	return nil
+}
+
+func (proc *routerProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *routerProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, nil
+}
+
+// Route returns the outputs of all the routes matched by the metric, or the default outputs
+func (proc *routerProcessor) Route(mt metric.Metric) ([]string, error) {
+	outputs := []string{}
+	seen := map[string]bool{}
+	for _, route := range proc.params.Routes {
+		if route.condition != nil {
+			match, err := route.condition.EvalBool(&mt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate condition \"%s\": %w", route.condition, err)
+			}
+			if !match {
+				continue
+			}
+		}
+
+		for _, output := range route.Outputs {
+			if !seen[output] {
+				seen[output] = true
+				outputs = append(outputs, output)
+			}
+		}
+	}
+
+	if len(outputs) == 0 {
+		outputs = append(outputs, proc.params.Default...)
+	}
+	return outputs, nil
+}
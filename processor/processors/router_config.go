@@ -0,0 +1,82 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/expr"
+)
+
+// RouterConfig sends metrics only to the output conveyors of the routes they match
+// Output conveyors are referred to by their names
+type RouterConfig struct {
+	// Routes are all evaluated, and a metric is sent to the outputs of every route it matches
+	Routes []RouterRoute `json:"routes"`
+	// Default lists the outputs of the metrics matching no route, which are dropped if it is empty
+	Default []string `json:"default"`
+}
+
+// RouterRoute selects the metrics sent to some outputs
+type RouterRoute struct {
+	// Condition selects the metrics, e.g. tags.level == "error", see the expr package
+	// A route without a condition matches all metrics
+	Condition string `json:"condition"`
+	// Outputs are the names of the output conveyors
+	Outputs []string `json:"outputs"`
+
+	condition *expr.Program
+}
+
+var _ config.CustomConfig = (*RouterConfig)(nil)
+
+func NewRouterConfig() config.CustomConfig {
+	return &RouterConfig{}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeRouter, NewRouterConfig)
+}
+
+func (cfg *RouterConfig) Validate() error {
+	if len(cfg.Routes) == 0 {
+		return fmt.Errorf("routes are required")
+	}
+
+	for i := range cfg.Routes {
+		route := &cfg.Routes[i]
+		if len(route.Outputs) == 0 {
+			return fmt.Errorf("route %d requires outputs", i)
+		}
+		if err := validateRouterOutputs(route.Outputs); err != nil {
+			return fmt.Errorf("invalid outputs of route %d: %w", i, err)
+		}
+		route.condition = nil
+		if route.Condition != "" {
+			var err error
+			route.condition, err = expr.CompileCondition(route.Condition)
+			if err != nil {
+				return fmt.Errorf("invalid condition \"%s\" of route %d: %w", route.Condition, i, err)
+			}
+		}
+	}
+	if err := validateRouterOutputs(cfg.Default); err != nil {
+		return fmt.Errorf("invalid default outputs: %w", err)
+	}
+	return nil
+}
+
+func validateRouterOutputs(outputs []string) error {
+	for _, output := range outputs {
+		if strings.TrimSpace(output) == "" {
+			return fmt.Errorf("output name is required")
+		}
+	}
+	return nil
+}
+
+func (cfg *RouterConfig) UnmarshalJSON(data []byte) error {
+	type plain RouterConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
@@ -0,0 +1,75 @@
+package processors
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestRouterRoute(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeRouter, `{
+		"routes": [
+			{"condition": "tags.level == \"error\"", "outputs": ["alerting", "storage"]},
+			{"condition": "name == \"cpu\"", "outputs": ["storage", "dashboard"]}
+		],
+		"default": ["archive"]
+	}`).(*routerProcessor)
+
+	cases := []struct {
+		mt      metric.Metric
+		outputs []string
+	}{
+		{metric.Metric{Name: "log", Tags: []metric.Tag{{Key: "level", Value: "error"}}}, []string{"alerting", "storage"}},
+		{metric.Metric{Name: "cpu", Tags: []metric.Tag{{Key: "level", Value: "error"}}}, []string{"alerting", "storage", "dashboard"}},
+		{metric.Metric{Name: "cpu"}, []string{"storage", "dashboard"}},
+		{metric.Metric{Name: "mem"}, []string{"archive"}},
+	}
+	for _, c := range cases {
+		out, err := proc.OnReceive(c.mt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 {
+			t.Fatalf("metric should pass: %v", out)
+		}
+		outputs, err := proc.Route(out[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(outputs, c.outputs) {
+			t.Errorf("%v: expected outputs %v but got %v", c.mt, c.outputs, outputs)
+		}
+	}
+
+	proc = newTestProcessor(t, ProcessorTypeRouter, `{"routes": [{"condition": "fields.usage > 90", "outputs": ["alerting"]}]}`).(*routerProcessor)
+	outputs, err := proc.Route(metric.Metric{Name: "cpu", Fields: []metric.Field{{Key: "usage", Value: 10}}})
+	if err != nil || outputs == nil || len(outputs) != 0 {
+		t.Errorf("unmatched metrics without default outputs should be sent nowhere: %v, %v", outputs, err)
+	}
+	_, err = proc.Route(metric.Metric{Name: "cpu", Fields: []metric.Field{{Key: "usage", Value: "high"}}})
+	if err == nil {
+		t.Error("comparing a string field with a number should fail")
+	}
+}
+
+func TestRouterConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{}`,
+		`{"routes": [{"condition": "name == \"cpu\""}]}`,
+		`{"routes": [{"condition": "name ==", "outputs": ["storage"]}]}`,
+		`{"routes": [{"condition": "name", "outputs": ["storage"]}]}`,
+		`{"routes": [{"outputs": [" "]}]}`,
+		`{"routes": [{"outputs": ["storage"]}], "default": [""]}`,
+	} {
+		cfg := NewRouterConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
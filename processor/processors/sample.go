@@ -0,0 +1,170 @@
+package processors
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeSample = "sample"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeSample, NewSampleProcessor)
+}
+
+// tokenBucket allows Rate metrics per second with bursts up to its capacity
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	// dropped is the number of metrics dropped since the last kept one
+	dropped int
+}
+
+type sampleProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *SampleConfig
+	burst  float64
+	rand   *rand.Rand
+	// buckets are keyed by series keys, or by the empty string for all metrics
+	buckets map[string]*tokenBucket
+	// expired is the time of the last expiry, which also runs on receiving at most once per the time to fill a bucket
+	expired time.Time
+}
+
+var _ processor.Processor = (*sampleProcessor)(nil)
+
+// NewSampleProcessor creates a new sample processor
+func NewSampleProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*SampleConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for sample processor: %T", cfg.Params)
+	}
+
+	burst := float64(params.Burst)
+	if burst == 0 {
+		burst = math.Ceil(params.Rate)
+	}
+	return &sampleProcessor{
+		cfg:     cfg,
+		params:  params,
+		burst:   burst,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		buckets: make(map[string]*tokenBucket),
+	}, nil
+}
+
+func (proc *sampleProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *sampleProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *sampleProcessor) Close() error {
+	return nil
+}
+
+func (proc *sampleProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.process(mt, time.Now()), nil
+}
+
+func (proc *sampleProcessor) process(mt metric.Metric, now time.Time) []metric.Metric {
+	var keep bool
+	var rate float64
+	switch proc.params.Mode {
+	case sampleModeHash:
+		keep, rate = sampleHash(mt.SeriesKey()) < proc.params.Percent/100, 100/proc.params.Percent
+	case sampleModeRandom:
+		keep, rate = proc.rand.Float64() < proc.params.Percent/100, 100/proc.params.Percent
+	default:
+		var dropped int
+		keep, dropped = proc.take(mt, now)
+		rate = float64(dropped + 1)
+	}
+	if !keep {
+		return []metric.Metric{}
+	}
+
+	if proc.params.SampleRateTag != "" {
+		tags := make([]metric.Tag, len(mt.Tags), len(mt.Tags)+1)
+		copy(tags, mt.Tags)
+		mt.Tags = setTag(tags, proc.params.SampleRateTag, strconv.FormatFloat(rate, 'g', -1, 64))
+	}
+	return []metric.Metric{mt}
+}
+
+// sampleHash maps a series key to [0, 1) consistently
+func sampleHash(key string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+// take tells whether the token bucket of the metric allows it,
+// and returns the number of metrics dropped since the last kept one if so
+func (proc *sampleProcessor) take(mt metric.Metric, now time.Time) (bool, int) {
+	key := ""
+	if proc.params.PerSeries {
+		key = mt.SeriesKey()
+	}
+	bucket, ok := proc.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: proc.burst, updated: now}
+		proc.buckets[key] = bucket
+	}
+	proc.refill(bucket, now)
+
+	keep, dropped := false, 0
+	if bucket.tokens < 1 {
+		bucket.dropped++
+	} else {
+		bucket.tokens--
+		keep, dropped = true, bucket.dropped
+		bucket.dropped = 0
+	}
+
+	// the bucket just taken from is not full, so it is kept by the expiry
+	if now.Sub(proc.expired).Seconds()*proc.params.Rate >= proc.burst {
+		proc.expire(now)
+	}
+	return keep, dropped
+}
+
+func (proc *sampleProcessor) refill(bucket *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens = math.Min(proc.burst, bucket.tokens+elapsed.Seconds()*proc.params.Rate)
+		bucket.updated = now
+	}
+}
+
+func (proc *sampleProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	proc.expire(time.Now())
+	return nil, nil
+}
+
+// expire removes the buckets which are full again, as if their series had not been received
+// The counts of metrics they dropped are discarded
+func (proc *sampleProcessor) expire(now time.Time) {
+	proc.expired = now
+	for key, bucket := range proc.buckets {
+		proc.refill(bucket, now)
+		if bucket.tokens >= proc.burst {
+			delete(proc.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+)
+
+const (
+	sampleModeHash      = "hash"
+	sampleModeRandom    = "random"
+	sampleModeRateLimit = "rate_limit"
+)
+
+// SampleConfig keeps a fraction of the metrics or limits their rate
+type SampleConfig struct {
+	// Mode is one of:
+	// "hash" keeps all the metrics of Percent of the series, chosen by the hash of their series keys
+	// "random" keeps Percent of the metrics at random
+	// "rate_limit" keeps the metrics allowed by a token bucket, per series if PerSeries is set or for all metrics
+	Mode string `json:"mode"`
+	// Percent of the series or metrics kept, in (0, 100]
+	Percent float64 `json:"percent"`
+	// Rate is the number of metrics per second allowed by the token bucket
+	Rate float64 `json:"rate"`
+	// Burst is the capacity of the token bucket, the rate rounded up if zero
+	Burst int `json:"burst"`
+	// PerSeries limits the rate of every series separately
+	PerSeries bool `json:"perSeries"`
+	// SampleRateTag is the tag of the kept metrics set to the number of metrics each of them stands for,
+	// 100 / Percent when sampling, or 1 plus the number of metrics dropped since the last kept one
+	// when limiting the rate, disabled if empty
+	SampleRateTag string `json:"sampleRateTag"`
+}
+
+var _ config.CustomConfig = (*SampleConfig)(nil)
+
+func NewSampleConfig() config.CustomConfig {
+	return &SampleConfig{
+		Mode:          sampleModeHash,
+		SampleRateTag: "sample_rate",
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeSample, NewSampleConfig)
+}
+
+func (cfg *SampleConfig) Validate() error {
+	switch cfg.Mode {
+	case sampleModeHash, sampleModeRandom:
+		if !(cfg.Percent > 0 && cfg.Percent <= 100) {
+			return fmt.Errorf("invalid percent: %v", cfg.Percent)
+		}
+	case sampleModeRateLimit:
+		if !(cfg.Rate > 0) {
+			return fmt.Errorf("invalid rate: %v", cfg.Rate)
+		}
+		if cfg.Burst < 0 {
+			return fmt.Errorf("invalid burst: %d", cfg.Burst)
+		}
+	default:
+		return fmt.Errorf("invalid mode: %s", cfg.Mode)
+	}
+	return nil
+}
+
+func (cfg *SampleConfig) UnmarshalJSON(data []byte) error {
+	type plain SampleConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
@@ -0,0 +1,128 @@
+package processors
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSampleHash(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeSample, `{"percent": 25}`).(*sampleProcessor)
+	now := time.Unix(1000, 0)
+	kept := 0
+	for i := 0; i < 2000; i++ {
+		mt := newTestMetric("cpu", i)
+		mt.Tags[0].Value = "web" + strconv.Itoa(i)
+		out := proc.process(mt, now)
+		if len(out) == 0 {
+			continue
+		}
+		kept++
+		if rate, _ := out[0].GetTag("sample_rate"); rate != "4" {
+			t.Fatalf("invalid sample rate: %s", rate)
+		}
+
+		// the other metrics of a kept series are kept too
+		mt.Fields[0].Value = -1
+		if len(proc.process(mt, now)) != 1 {
+			t.Fatal("series should be sampled consistently")
+		}
+	}
+	if kept < 400 || kept > 600 {
+		t.Errorf("expected about 500 series but %d were kept", kept)
+	}
+}
+
+func TestSampleRandom(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeSample, `{"mode": "random", "percent": 10, "sampleRateTag": ""}`).(*sampleProcessor)
+	proc.rand = rand.New(rand.NewSource(1))
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		out := proc.process(newTestMetric("cpu", i), time.Unix(1000, 0))
+		if len(out) == 1 {
+			kept++
+			if len(out[0].Tags) != 1 {
+				t.Fatalf("sample rate tag should be disabled: %v", out[0].Tags)
+			}
+		}
+	}
+	if kept < 900 || kept > 1100 {
+		t.Errorf("expected about 1000 metrics but %d were kept", kept)
+	}
+}
+
+func TestSampleRateLimit(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeSample, `{"mode": "rate_limit", "rate": 2, "sampleRateTag": "rate"}`).(*sampleProcessor)
+	start := time.Unix(1000, 0)
+	rates := func(now time.Time, n int, host string) []string {
+		kept := []string{}
+		for i := 0; i < n; i++ {
+			mt := newTestMetric("cpu", i)
+			mt.Tags[0].Value = host
+			for _, out := range proc.process(mt, now) {
+				rate, _ := out.GetTag("rate")
+				kept = append(kept, rate)
+			}
+		}
+		return kept
+	}
+
+	if kept := rates(start, 3, "web01"); len(kept) != 2 || kept[0] != "1" || kept[1] != "1" {
+		t.Errorf("the burst should be kept: %v", kept)
+	}
+	if kept := rates(start, 2, "web02"); len(kept) != 0 {
+		t.Errorf("the global rate should be limited: %v", kept)
+	}
+	if kept := rates(start.Add(500*time.Millisecond), 2, "web01"); len(kept) != 1 || kept[0] != "4" {
+		t.Errorf("the kept metric should stand for the dropped ones: %v", kept)
+	}
+
+	proc = newTestProcessor(t, ProcessorTypeSample, `{"mode": "rate_limit", "rate": 0.5, "burst": 1, "perSeries": true}`).(*sampleProcessor)
+	if kept := rates(start, 2, "web01"); len(kept) != 1 {
+		t.Errorf("the burst should be kept: %v", kept)
+	}
+	if kept := rates(start, 2, "web02"); len(kept) != 1 {
+		t.Errorf("series should be limited separately: %v", kept)
+	}
+	proc.expire(start.Add(time.Second))
+	if len(proc.buckets) != 2 {
+		t.Errorf("buckets should be kept until they are full: %d", len(proc.buckets))
+	}
+	proc.expire(start.Add(2 * time.Second))
+	if len(proc.buckets) != 0 {
+		t.Errorf("full buckets should be expired: %d", len(proc.buckets))
+	}
+
+	// full buckets are also expired on receiving without cron triggers
+	rates(start.Add(3*time.Second), 1, "web01")
+	rates(start.Add(4*time.Second), 1, "web02")
+	if len(proc.buckets) != 2 {
+		t.Errorf("buckets should be kept until they are full: %d", len(proc.buckets))
+	}
+	rates(start.Add(6*time.Second), 1, "web02")
+	if len(proc.buckets) != 1 {
+		t.Errorf("full buckets should be expired on receiving: %d", len(proc.buckets))
+	}
+}
+
+func TestSampleConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{}`,
+		`{"percent": 150}`,
+		`{"mode": "random", "percent": -1}`,
+		`{"mode": "rate_limit"}`,
+		`{"mode": "rate_limit", "rate": 10, "burst": -1}`,
+		`{"mode": "reservoir", "percent": 10}`,
+	} {
+		cfg := NewSampleConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
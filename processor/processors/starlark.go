@@ -0,0 +1,147 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+)
+
+const (
+	ProcessorTypeStarlark = "starlark"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeStarlark, NewStarlarkProcessor)
+}
+
+type starlarkProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *StarlarkConfig
+
+	// the thread is used by one call at a time as the runner calls the processor sequentially
+	thread *starlark.Thread
+	apply  starlark.Callable
+	onTick starlark.Callable
+	// the state is created once so that it is kept when the processor is set up again
+	state *starlark.Dict
+}
+
+var _ processor.Processor = (*starlarkProcessor)(nil)
+
+// NewStarlarkProcessor creates a new starlark processor
+func NewStarlarkProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*StarlarkConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for starlark processor: %T", cfg.Params)
+	}
+
+	return &starlarkProcessor{
+		cfg:    cfg,
+		params: params,
+		state:  starlark.NewDict(0),
+	}, nil
+}
+
+func (proc *starlarkProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *starlarkProcessor) Setup(emitter processor.Emitter) error {
+	name := proc.cfg.Name
+	proc.thread = &starlark.Thread{
+		Name: name,
+		Print: func(thread *starlark.Thread, msg string) {
+			logrus.Infof("Processor \"%s\": %s", name, msg)
+		},
+	}
+
+	predeclared := starlark.StringDict{}
+	for key, value := range starlarkPredeclared {
+		predeclared[key] = value
+	}
+	predeclared["state"] = proc.state
+
+	var globals starlark.StringDict
+	err := proc.withTimeout(func() error {
+		var err error
+		globals, err = proc.params.program.Init(proc.thread, predeclared)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load script: %w", err)
+	}
+
+	var ok bool
+	proc.apply, ok = globals["apply"].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("script must define apply(metric)")
+	}
+	if proc.params.hasTick {
+		proc.onTick, ok = globals["on_tick"].(starlark.Callable)
+		if !ok {
+			return fmt.Errorf("on_tick must be a function")
+		}
+	}
+	return nil
+}
+
+func (proc *starlarkProcessor) Close() error {
+	return nil
+}
+
+func (proc *starlarkProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.call(proc.apply, starlark.Tuple{newStarlarkMetric(mt)})
+}
+
+func (proc *starlarkProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	if proc.onTick == nil {
+		return nil, nil
+	}
+	return proc.call(proc.onTick, nil)
+}
+
+func (proc *starlarkProcessor) call(fn starlark.Callable, args starlark.Tuple) ([]metric.Metric, error) {
+	var result starlark.Value
+	err := proc.withTimeout(func() error {
+		var err error
+		result, err = starlark.Call(proc.thread, fn, args, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := fromStarlarkResult(result)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn.Name(), err)
+	}
+	return out, nil
+}
+
+// withTimeout runs the function and cancels the thread if it does not return within the timeout
+// Evaluation errors are reported with their backtraces
+func (proc *starlarkProcessor) withTimeout(fn func() error) error {
+	proc.thread.Uncancel()
+	timer := time.AfterFunc(time.Duration(proc.params.Timeout), func() {
+		proc.thread.Cancel(fmt.Sprintf("timeout of %v exceeded", time.Duration(proc.params.Timeout)))
+	})
+	defer timer.Stop()
+
+	err := fn()
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// StarlarkConfig runs a Starlark script, which defines apply(metric) called for every received metric
+// and optionally on_tick() called on cron triggers
+// Both return None to emit nothing, a metric or a list of metrics
+// The script may keep data across calls in the predeclared dict "state", which is also kept when the processor
+// is set up again while the top level statements of the script run again
+// and create metrics by Metric(name, tags={}, fields={}, time=<unix nanoseconds>)
+// The time of a metric is None if it has no time, and Metric(...) defaults it to now unless time=None is given
+type StarlarkConfig struct {
+	// Source is the inline script
+	Source string `json:"source"`
+	// Script is the path of the script file
+	Script string `json:"script"`
+	// Timeout limits the execution of the script on loading and on every call
+	Timeout config.Duration `json:"timeout"`
+
+	program *starlark.Program
+	hasTick bool
+}
+
+var _ config.CustomConfig = (*StarlarkConfig)(nil)
+
+func NewStarlarkConfig() config.CustomConfig {
+	return &StarlarkConfig{
+		Timeout: config.Duration(time.Second),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeStarlark, NewStarlarkConfig)
+}
+
+// starlarkFileOptions allows the statements commonly used by scripts processing metrics
+var starlarkFileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+func (cfg *StarlarkConfig) Validate() error {
+	if (cfg.Source == "") == (cfg.Script == "") {
+		return fmt.Errorf("either source or script is required")
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout: %v", cfg.Timeout)
+	}
+
+	filename, src := "<source>", []byte(cfg.Source)
+	if cfg.Script != "" {
+		var err error
+		filename = cfg.Script
+		src, err = os.ReadFile(cfg.Script)
+		if err != nil {
+			return err
+		}
+	}
+
+	file, program, err := starlark.SourceProgramOptions(starlarkFileOptions, filename, src, starlarkPredeclared.Has)
+	if err != nil {
+		return err
+	}
+	if program.NumLoads() > 0 {
+		return fmt.Errorf("%s: load statements are not supported", filename)
+	}
+
+	functions := map[string]*syntax.DefStmt{}
+	for _, stmt := range file.Stmts {
+		if def, ok := stmt.(*syntax.DefStmt); ok {
+			functions[def.Name.Name] = def
+		}
+	}
+	if def := functions["apply"]; def == nil || len(def.Params) != 1 {
+		return fmt.Errorf("%s: script must define apply(metric)", filename)
+	}
+	if def := functions["on_tick"]; def != nil && len(def.Params) != 0 {
+		return fmt.Errorf("%s: on_tick must have no parameters", filename)
+	}
+
+	cfg.program = program
+	cfg.hasTick = functions["on_tick"] != nil
+	return nil
+}
+
+func (cfg *StarlarkConfig) UnmarshalJSON(data []byte) error {
+	type plain StarlarkConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
@@ -0,0 +1,331 @@
+package processors
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+	"go.starlark.net/starlark"
+)
+
+// starlarkPredeclared lists the names predeclared for scripts, state is replaced for every processor
+var starlarkPredeclared = starlark.StringDict{
+	"Metric": starlark.NewBuiltin("Metric", starlarkNewMetric),
+	"state":  starlark.None,
+}
+
+// starlarkMetric wraps a metric for scripts
+// The tags and the fields are dicts which are checked when converted back to a metric
+// The time is None in scripts if the metric has no time
+type starlarkMetric struct {
+	name   string
+	tags   *starlark.Dict
+	fields *starlark.Dict
+	time   time.Time
+	frozen bool
+}
+
+var (
+	_ starlark.HasAttrs    = (*starlarkMetric)(nil)
+	_ starlark.HasSetField = (*starlarkMetric)(nil)
+)
+
+// starlarkNewMetric implements Metric(name, tags={}, fields={}, time=<now>)
+// An explicit time=None creates a metric without a time
+func starlarkNewMetric(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var tags, fields *starlark.Dict
+	var t starlark.Value
+	err := starlark.UnpackArgs(fn.Name(), args, kwargs, "name", &name, "tags?", &tags, "fields?", &fields, "time?", &t)
+	if err != nil {
+		return nil, err
+	}
+
+	mt := &starlarkMetric{
+		name:   name,
+		tags:   copyStarlarkDict(tags),
+		fields: copyStarlarkDict(fields),
+		time:   time.Now(),
+	}
+	if t != nil {
+		if mt.time, err = starlarkTime(t); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn.Name(), err)
+		}
+	}
+	return mt, nil
+}
+
+// starlarkTime converts unix nanoseconds or None to a time, None is the zero time
+func starlarkTime(value starlark.Value) (time.Time, error) {
+	if value == starlark.None {
+		return time.Time{}, nil
+	}
+	i, ok := value.(starlark.Int)
+	if !ok {
+		return time.Time{}, fmt.Errorf("time must be an int or None, got %s", value.Type())
+	}
+	t, ok := i.Int64()
+	if !ok {
+		return time.Time{}, fmt.Errorf("time out of range")
+	}
+	return time.Unix(0, t), nil
+}
+
+// timeValue returns the time in unix nanoseconds, or None if the metric has no time
+func (mt *starlarkMetric) timeValue() starlark.Value {
+	if mt.time.IsZero() {
+		return starlark.None
+	}
+	return starlark.MakeInt64(mt.time.UnixNano())
+}
+
+func copyStarlarkDict(dict *starlark.Dict) *starlark.Dict {
+	copied := starlark.NewDict(0)
+	if dict != nil {
+		for _, item := range dict.Items() {
+			copied.SetKey(item[0], item[1])
+		}
+	}
+	return copied
+}
+
+func newStarlarkMetric(mt metric.Metric) *starlarkMetric {
+	tags := starlark.NewDict(len(mt.Tags))
+	for _, tag := range mt.Tags {
+		tags.SetKey(starlark.String(tag.Key), starlark.String(tag.Value))
+	}
+
+	fields := starlark.NewDict(len(mt.Fields))
+	for _, field := range mt.Fields {
+		value, ok := toStarlarkValue(field.Value)
+		if !ok {
+			continue
+		}
+		fields.SetKey(starlark.String(field.Key), value)
+	}
+
+	return &starlarkMetric{
+		name:   mt.Name,
+		tags:   tags,
+		fields: fields,
+		time:   mt.Time,
+	}
+}
+
+func toStarlarkValue(value interface{}) (starlark.Value, bool) {
+	switch v := value.(type) {
+	case string:
+		return starlark.String(v), true
+	case bool:
+		return starlark.Bool(v), true
+	case float64:
+		return starlark.Float(v), true
+	case float32:
+		return starlark.Float(v), true
+	case int:
+		return starlark.MakeInt(v), true
+	case int8:
+		return starlark.MakeInt64(int64(v)), true
+	case int16:
+		return starlark.MakeInt64(int64(v)), true
+	case int32:
+		return starlark.MakeInt64(int64(v)), true
+	case int64:
+		return starlark.MakeInt64(v), true
+	case uint:
+		return starlark.MakeUint(v), true
+	case uint8:
+		return starlark.MakeUint64(uint64(v)), true
+	case uint16:
+		return starlark.MakeUint64(uint64(v)), true
+	case uint32:
+		return starlark.MakeUint64(uint64(v)), true
+	case uint64:
+		return starlark.MakeUint64(v), true
+	}
+	return nil, false
+}
+
+// toMetric converts the wrapper back to a metric, checking the tags and the fields
+func (mt *starlarkMetric) toMetric() (metric.Metric, error) {
+	result := metric.Metric{
+		Name:   mt.name,
+		Tags:   make([]metric.Tag, 0, mt.tags.Len()),
+		Fields: make([]metric.Field, 0, mt.fields.Len()),
+		Time:   mt.time,
+	}
+
+	for _, item := range mt.tags.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			return metric.Metric{}, fmt.Errorf("tag key %s must be a string", item[0])
+		}
+		value, ok := starlark.AsString(item[1])
+		if !ok {
+			return metric.Metric{}, fmt.Errorf("tag \"%s\" must be a string, got %s", key, item[1].Type())
+		}
+		result.Tags = append(result.Tags, metric.Tag{Key: key, Value: value})
+	}
+
+	for _, item := range mt.fields.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			return metric.Metric{}, fmt.Errorf("field key %s must be a string", item[0])
+		}
+		value, err := fromStarlarkValue(item[1])
+		if err != nil {
+			return metric.Metric{}, fmt.Errorf("field \"%s\": %w", key, err)
+		}
+		result.Fields = append(result.Fields, metric.Field{Key: key, Value: value})
+	}
+
+	return result, nil
+}
+
+func fromStarlarkValue(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.String:
+		return string(v), nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		if u, ok := v.Uint64(); ok {
+			return u, nil
+		}
+		return nil, fmt.Errorf("integer out of range")
+	}
+	return nil, fmt.Errorf("invalid field type %s", value.Type())
+}
+
+// fromStarlarkResult converts the result of apply or on_tick, which is None, a metric or a list of metrics
+func fromStarlarkResult(result starlark.Value) ([]metric.Metric, error) {
+	if result == starlark.None {
+		return []metric.Metric{}, nil
+	}
+	if mt, ok := result.(*starlarkMetric); ok {
+		converted, err := mt.toMetric()
+		if err != nil {
+			return nil, err
+		}
+		return []metric.Metric{converted}, nil
+	}
+
+	var values []starlark.Value
+	switch v := result.(type) {
+	case *starlark.List:
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, v.Index(i))
+		}
+	case starlark.Tuple:
+		values = v
+	default:
+		return nil, fmt.Errorf("script must return None, a metric or a list of metrics, got %s", result.Type())
+	}
+
+	metrics := make([]metric.Metric, 0, len(values))
+	for _, value := range values {
+		mt, ok := value.(*starlarkMetric)
+		if !ok {
+			return nil, fmt.Errorf("script must return a list of metrics, got an element of %s", value.Type())
+		}
+		converted, err := mt.toMetric()
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, converted)
+	}
+	return metrics, nil
+}
+
+func (mt *starlarkMetric) String() string {
+	return fmt.Sprintf("Metric(%s, tags=%s, fields=%s, time=%s)", starlark.String(mt.name), mt.tags, mt.fields, mt.timeValue())
+}
+
+func (mt *starlarkMetric) Type() string {
+	return "Metric"
+}
+
+func (mt *starlarkMetric) Freeze() {
+	if !mt.frozen {
+		mt.frozen = true
+		mt.tags.Freeze()
+		mt.fields.Freeze()
+	}
+}
+
+func (mt *starlarkMetric) Truth() starlark.Bool {
+	return true
+}
+
+func (mt *starlarkMetric) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: Metric")
+}
+
+func (mt *starlarkMetric) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "name":
+		return starlark.String(mt.name), nil
+	case "tags":
+		return mt.tags, nil
+	case "fields":
+		return mt.fields, nil
+	case "time":
+		return mt.timeValue(), nil
+	case "copy":
+		return starlark.NewBuiltin("copy", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			return &starlarkMetric{
+				name:   mt.name,
+				tags:   copyStarlarkDict(mt.tags),
+				fields: copyStarlarkDict(mt.fields),
+				time:   mt.time,
+			}, nil
+		}).BindReceiver(mt), nil
+	}
+	return nil, nil
+}
+
+func (mt *starlarkMetric) AttrNames() []string {
+	return []string{"copy", "fields", "name", "tags", "time"}
+}
+
+func (mt *starlarkMetric) SetField(name string, value starlark.Value) error {
+	if mt.frozen {
+		return fmt.Errorf("cannot modify frozen Metric")
+	}
+
+	switch name {
+	case "name":
+		str, ok := value.(starlark.String)
+		if !ok {
+			return fmt.Errorf("Metric.name must be a string, got %s", value.Type())
+		}
+		mt.name = string(str)
+	case "time":
+		t, err := starlarkTime(value)
+		if err != nil {
+			return fmt.Errorf("Metric.%w", err)
+		}
+		mt.time = t
+	case "tags", "fields":
+		dict, ok := value.(*starlark.Dict)
+		if !ok {
+			return fmt.Errorf("Metric.%s must be a dict, got %s", name, value.Type())
+		}
+		if name == "tags" {
+			mt.tags = copyStarlarkDict(dict)
+		} else {
+			mt.fields = copyStarlarkDict(dict)
+		}
+	default:
+		return starlark.NoSuchAttrError(fmt.Sprintf("Metric has no field .%s", name))
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package processors
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestStarlarkApply(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeStarlark, map[string]string{"source": `
+def apply(metric):
+    if metric.name == "drop":
+        return None
+    metric.name = metric.name + "_total"
+    metric.tags["region"] = "eu"
+    metric.fields["double"] = metric.fields["value"] * 2
+    metric.fields.pop("debug", None)
+    metric.time = metric.time + 1000
+    if metric.tags.get("split") == "yes":
+        copied = metric.copy()
+        copied.name = "copied"
+        return [metric, copied]
+    return metric
+`}).(*starlarkProcessor)
+
+	mt := newTestMetric("requests", int64(21))
+	mt.Fields = append(mt.Fields, metric.Field{Key: "debug", Value: true})
+	out, err := proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []metric.Metric{{
+		Name:   "requests_total",
+		Tags:   []metric.Tag{{Key: "host", Value: "web01"}, {Key: "region", Value: "eu"}},
+		Fields: []metric.Field{{Key: "value", Value: int64(21)}, {Key: "double", Value: int64(42)}},
+		Time:   time.Unix(0, mt.Time.UnixNano()+1000),
+	}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %v but got %v", expected, out)
+	}
+	if len(mt.Fields) != 2 || mt.Name != "requests" {
+		t.Error("the received metric should not be modified")
+	}
+
+	out, err = proc.OnReceive(newTestMetric("drop", 1.0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || len(out) != 0 {
+		t.Errorf("metric should be dropped: %v", out)
+	}
+
+	mt = newTestMetric("cpu", 1.5)
+	mt.Tags = append(mt.Tags, metric.Tag{Key: "split", Value: "yes"})
+	out, err = proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Name != "cpu_total" || out[1].Name != "copied" || len(out[1].Fields) != 2 {
+		t.Errorf("expected the metric and its copy but got %v", out)
+	}
+}
+
+func TestStarlarkStateAndTick(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeStarlark, map[string]string{"source": `
+state["count"] = 0
+
+def apply(metric):
+    state["count"] += 1
+    state["last"] = metric.fields["value"]
+    return None
+
+def on_tick():
+    m = Metric("summary", tags={"source": "script"}, fields={"count": state["count"], "last": state["last"]}, time=5)
+    state["count"] = 0
+    return [m]
+`}).(*starlarkProcessor)
+
+	for _, value := range []float64{1, 2, 3} {
+		if _, err := proc.OnReceive(newTestMetric("cpu", value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []metric.Metric{{
+		Name:   "summary",
+		Tags:   []metric.Tag{{Key: "source", Value: "script"}},
+		Fields: []metric.Field{{Key: "count", Value: int64(3)}, {Key: "last", Value: 3.0}},
+		Time:   time.Unix(0, 5),
+	}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %v but got %v", expected, out)
+	}
+
+	out, err = proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := out[0].GetField("count"); count != int64(0) {
+		t.Errorf("state should be kept across calls, got count %v", count)
+	}
+}
+
+func TestStarlarkStateKeptOnSetup(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeStarlark, map[string]string{"source": `
+def apply(metric):
+    state["count"] = state.get("count", 0) + 1
+    return Metric("count", fields={"count": state["count"]}, time=None)
+`})
+
+	for i := 0; i < 2; i++ {
+		if _, err := proc.OnReceive(newTestMetric("cpu", 1)); err != nil {
+			t.Fatal(err)
+		}
+		if err := proc.Setup(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := proc.OnReceive(newTestMetric("cpu", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := out[0].GetField("count"); count != int64(3) {
+		t.Errorf("state should be kept when the processor is set up again, got count %v", count)
+	}
+}
+
+func TestStarlarkUntimed(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeStarlark, map[string]string{"source": `
+def apply(metric):
+    if metric.time != None:
+        fail("metric should have no time")
+    return [metric, Metric("epoch", fields={"value": 1}, time=0), Metric("untimed", fields={"value": 1}, time=None)]
+`}).(*starlarkProcessor)
+
+	mt := newTestMetric("cpu", 1.0)
+	mt.Time = time.Time{}
+	out, err := proc.OnReceive(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("expected 3 metrics but got %v", out)
+	}
+	if !out[0].Time.IsZero() {
+		t.Errorf("a metric without a time should stay without a time, got %v", out[0].Time)
+	}
+	if !out[1].Time.Equal(time.Unix(0, 0)) {
+		t.Errorf("time=0 should be the unix epoch, got %v", out[1].Time)
+	}
+	if !out[2].Time.IsZero() {
+		t.Errorf("time=None should create a metric without a time, got %v", out[2].Time)
+	}
+}
+
+func TestStarlarkErrors(t *testing.T) {
+	cases := []struct {
+		source string
+		err    string
+	}{
+		{"def apply(metric):\n    while True:\n        pass\n", "timeout"},
+		{"def apply(metric):\n    return 1\n", "must return"},
+		{"def apply(metric):\n    metric.tags['n'] = 1\n    return metric\n", "tag \"n\" must be a string"},
+		{"def apply(metric):\n    metric.fields['l'] = [1]\n    return metric\n", "invalid field type list"},
+		{"def apply(metric):\n    metric.name = 1\n", "must be a string"},
+		{"def apply(metric):\n    metric.time = 'now'\n", "must be an int or None"},
+		{"def apply(metric):\n    return [metric, 'x']\n", "list of metrics"},
+		{"def apply(metric):\n    fail('broken')\n", "broken"},
+	}
+	for _, c := range cases {
+		proc := newTestProcessor(t, ProcessorTypeStarlark, map[string]interface{}{"source": c.source, "timeout": "100ms"}).(*starlarkProcessor)
+		_, err := proc.OnReceive(newTestMetric("cpu", 1.0))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected an error containing \"%s\" but got %v", c.err, err)
+		}
+	}
+
+	// the thread is usable again after a timeout
+	proc := newTestProcessor(t, ProcessorTypeStarlark, map[string]interface{}{
+		"source":  "def apply(metric):\n    if metric.name == 'loop':\n        while True:\n            pass\n    return metric\n",
+		"timeout": "50ms",
+	}).(*starlarkProcessor)
+	if _, err := proc.OnReceive(newTestMetric("loop", 1.0)); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if out, err := proc.OnReceive(newTestMetric("cpu", 1.0)); err != nil || len(out) != 1 {
+		t.Errorf("expected the metric after a timeout but got %v, %v", out, err)
+	}
+}
+
+func TestStarlarkScriptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.star")
+	err := os.WriteFile(path, []byte("def apply(metric):\n    metric.tags['script'] = 'file'\n    return metric\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proc := newTestProcessor(t, ProcessorTypeStarlark, map[string]string{"script": path}).(*starlarkProcessor)
+	out, err := proc.OnReceive(newTestMetric("cpu", 1.0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag, _ := out[0].GetTag("script"); tag != "file" {
+		t.Errorf("expected the tag set by the script file but got %v", out)
+	}
+}
+
+func TestStarlarkConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{}`,
+		`{"source": "def apply(metric): return metric", "script": "a.star"}`,
+		`{"script": "/nonexistent/script.star"}`,
+		`{"source": "def apply(metric):\n  return metric +"}`,
+		`{"source": "def apply(metric):\n  return undefined_name"}`,
+		`{"source": "def transform(metric):\n  return metric"}`,
+		`{"source": "def apply():\n  return None"}`,
+		`{"source": "def apply(metric):\n  return metric\ndef on_tick(x):\n  return None"}`,
+		`{"source": "load('lib.star', 'f')\ndef apply(metric):\n  return metric"}`,
+		`{"source": "def apply(metric):\n  return metric", "timeout": "0s"}`,
+	} {
+		cfg := NewStarlarkConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
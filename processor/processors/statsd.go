@@ -0,0 +1,672 @@
+package processors
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypeStatsd = "statsd"
+
+	statsdMaxPacketSize = 64 * 1024
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeStatsd, NewStatsdProcessor)
+}
+
+// statsdTemplate maps the dot separated parts of a bucket name
+// to the measurement, the field and the tags of a metric
+type statsdTemplate struct {
+	filter string
+	tokens []string
+}
+
+func parseStatsdTemplate(str string) (*statsdTemplate, error) {
+	parts := strings.Fields(str)
+	switch len(parts) {
+	case 1:
+		return &statsdTemplate{tokens: strings.Split(parts[0], ".")}, nil
+	case 2:
+		if _, err := path.Match(parts[0], ""); err != nil {
+			return nil, fmt.Errorf("invalid template filter \"%s\": %v", parts[0], err)
+		}
+		return &statsdTemplate{filter: parts[0], tokens: strings.Split(parts[1], ".")}, nil
+	default:
+		return nil, fmt.Errorf("invalid template: \"%s\"", str)
+	}
+}
+
+func (tmpl *statsdTemplate) match(bucket string) bool {
+	if tmpl.filter == "" {
+		return true
+	}
+	matched, _ := path.Match(tmpl.filter, bucket)
+	return matched
+}
+
+// apply returns the measurement, the field and the tags extracted from the bucket
+func (tmpl *statsdTemplate) apply(bucket string, separator string) (string, string, []metric.Tag) {
+	parts := strings.Split(bucket, ".")
+	var measurement, field []string
+	var tags []metric.Tag
+	for i, token := range tmpl.tokens {
+		if i >= len(parts) {
+			break
+		}
+
+		switch token {
+		case "":
+		case "measurement":
+			measurement = append(measurement, parts[i])
+		case "measurement*":
+			measurement = append(measurement, parts[i:]...)
+		case "field":
+			field = append(field, parts[i])
+		case "field*":
+			field = append(field, parts[i:]...)
+		default:
+			tags = append(tags, metric.Tag{Key: token, Value: parts[i]})
+		}
+
+		if strings.HasSuffix(token, "*") {
+			break
+		}
+	}
+
+	name := strings.Join(measurement, separator)
+	if name == "" {
+		name = strings.ReplaceAll(bucket, ".", separator)
+	}
+	fieldName := strings.Join(field, separator)
+	if fieldName == "" {
+		fieldName = "value"
+	}
+	return name, fieldName, tags
+}
+
+// statsdSample is a single parsed statsd line
+type statsdSample struct {
+	bucket     string
+	value      string
+	mtype      string
+	sampleRate float64
+	tags       []metric.Tag
+}
+
+// parseStatsdLine parses a line in the form of
+// <bucket>[,<tag>=<value>...]:<value>|<type>[|@<sample rate>][|#<tag>:<value>,...]
+func parseStatsdLine(line string, dataDogTags bool) (statsdSample, error) {
+	sample := statsdSample{sampleRate: 1}
+
+	idx := strings.Index(line, ":")
+	if idx <= 0 {
+		return sample, fmt.Errorf("invalid statsd line: %s", line)
+	}
+	sample.bucket = line[:idx]
+
+	sections := strings.Split(line[idx+1:], "|")
+	if len(sections) < 2 || sections[0] == "" {
+		return sample, fmt.Errorf("invalid statsd line: %s", line)
+	}
+	sample.value = sections[0]
+	sample.mtype = sections[1]
+	switch sample.mtype {
+	case "c", "g", "s", "ms", "h", "d":
+	default:
+		return sample, fmt.Errorf("invalid statsd metric type: %s", sample.mtype)
+	}
+
+	for _, section := range sections[2:] {
+		switch {
+		case strings.HasPrefix(section, "@"):
+			rate, err := strconv.ParseFloat(section[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return sample, fmt.Errorf("invalid statsd sample rate: %s", section)
+			}
+			sample.sampleRate = rate
+		case strings.HasPrefix(section, "#"):
+			if !dataDogTags {
+				continue
+			}
+			for _, pair := range strings.Split(section[1:], ",") {
+				if pair == "" {
+					continue
+				}
+				kv := strings.SplitN(pair, ":", 2)
+				if len(kv) == 1 {
+					sample.tags = append(sample.tags, metric.Tag{Key: kv[0], Value: "true"})
+				} else {
+					sample.tags = append(sample.tags, metric.Tag{Key: kv[0], Value: kv[1]})
+				}
+			}
+		}
+	}
+
+	// influx style tags in the bucket name, e.g. "cpu,host=web01"
+	if strings.Contains(sample.bucket, ",") {
+		parts := strings.Split(sample.bucket, ",")
+		sample.bucket = parts[0]
+		for _, pair := range parts[1:] {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return sample, fmt.Errorf("invalid statsd tag: %s", pair)
+			}
+			sample.tags = append(sample.tags, metric.Tag{Key: kv[0], Value: kv[1]})
+		}
+	}
+
+	return sample, nil
+}
+
+type statsdCounter struct {
+	name   string
+	tags   []metric.Tag
+	fields map[string]float64
+	// window holds the increments since the last flush for rate calculation
+	window map[string]float64
+}
+
+type statsdGauge struct {
+	name   string
+	tags   []metric.Tag
+	fields map[string]float64
+}
+
+type statsdSet struct {
+	name   string
+	tags   []metric.Tag
+	fields map[string]map[string]struct{}
+}
+
+type statsdTiming struct {
+	name   string
+	tags   []metric.Tag
+	fields map[string]*statsdStats
+}
+
+// statsdStats accumulates the values of a timing or a histogram
+type statsdStats struct {
+	values []float64
+	seen   int
+	count  float64
+	// window is the weighted count since the last flush for rate calculation
+	window float64
+	sum    float64
+	sumSq  float64
+	min    float64
+	max    float64
+}
+
+func (stats *statsdStats) add(value float64, sampleRate float64, limit int) {
+	if stats.seen == 0 || value < stats.min {
+		stats.min = value
+	}
+	if stats.seen == 0 || value > stats.max {
+		stats.max = value
+	}
+	stats.seen++
+	stats.count += 1 / sampleRate
+	stats.window += 1 / sampleRate
+	stats.sum += value
+	stats.sumSq += value * value
+
+	// reservoir sampling keeps the percentiles representative once the limit is reached
+	if len(stats.values) < limit {
+		stats.values = append(stats.values, value)
+	} else if idx := rand.Intn(stats.seen); idx < limit {
+		stats.values[idx] = value
+	}
+}
+
+func (stats *statsdStats) mean() float64 {
+	return stats.sum / float64(stats.seen)
+}
+
+func (stats *statsdStats) stddev() float64 {
+	mean := stats.mean()
+	variance := stats.sumSq/float64(stats.seen) - mean*mean
+	if variance < 0 {
+		return 0
+	}
+	return math.Sqrt(variance)
+}
+
+func (stats *statsdStats) percentile(pct float64) float64 {
+	sorted := make([]float64, len(stats.values))
+	copy(sorted, stats.values)
+	sort.Float64s(sorted)
+
+	idx := int(math.Ceil(pct/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+type statsdProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *StatsdConfig
+
+	mutex     sync.Mutex
+	counters  map[string]*statsdCounter
+	gauges    map[string]*statsdGauge
+	sets      map[string]*statsdSet
+	timings   map[string]*statsdTiming
+	lastFlush time.Time
+
+	packetConn net.PacketConn
+	listener   net.Listener
+	conns      map[net.Conn]struct{}
+	connsMutex sync.Mutex
+	// closed tells whether the connections were closed, connections accepted afterwards are closed at once
+	closed bool
+	wg     sync.WaitGroup
+}
+
+var _ processor.Processor = (*statsdProcessor)(nil)
+
+// NewStatsdProcessor creates a new statsd processor
+func NewStatsdProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*StatsdConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for statsd processor: %T", cfg.Params)
+	}
+
+	return &statsdProcessor{
+		cfg:      cfg,
+		params:   params,
+		counters: make(map[string]*statsdCounter),
+		gauges:   make(map[string]*statsdGauge),
+		sets:     make(map[string]*statsdSet),
+		timings:  make(map[string]*statsdTiming),
+	}, nil
+}
+
+func (proc *statsdProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *statsdProcessor) Setup(emitter processor.Emitter) error {
+	proc.mutex.Lock()
+	proc.lastFlush = time.Now()
+	proc.mutex.Unlock()
+
+	switch proc.params.Protocol {
+	case "udp":
+		conn, err := net.ListenPacket("udp", proc.params.Address)
+		if err != nil {
+			return err
+		}
+		proc.packetConn = conn
+		proc.wg.Add(1)
+		go proc.serveUDP()
+	case "tcp":
+		listener, err := net.Listen("tcp", proc.params.Address)
+		if err != nil {
+			return err
+		}
+		proc.listener = listener
+		proc.conns = make(map[net.Conn]struct{})
+		proc.closed = false
+		proc.wg.Add(1)
+		go proc.serveTCP()
+	}
+
+	return nil
+}
+
+func (proc *statsdProcessor) Close() error {
+	var err error
+	if proc.packetConn != nil {
+		err = proc.packetConn.Close()
+		proc.packetConn = nil
+	}
+
+	if proc.listener != nil {
+		err = proc.listener.Close()
+		proc.listener = nil
+
+		proc.connsMutex.Lock()
+		proc.closed = true
+		for conn := range proc.conns {
+			conn.Close()
+		}
+		proc.connsMutex.Unlock()
+	}
+
+	proc.wg.Wait()
+	return err
+}
+
+// addr returns the address the processor is listening on
+func (proc *statsdProcessor) addr() net.Addr {
+	if proc.packetConn != nil {
+		return proc.packetConn.LocalAddr()
+	}
+	if proc.listener != nil {
+		return proc.listener.Addr()
+	}
+	return nil
+}
+
+func (proc *statsdProcessor) serveUDP() {
+	defer proc.wg.Done()
+
+	conn := proc.packetConn
+	buf := make([]byte, statsdMaxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.Errorf("Processor \"%s\" failed to read statsd packet: %v", proc.cfg.Name, err)
+			}
+			return
+		}
+
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			proc.handleLine(line)
+		}
+	}
+}
+
+func (proc *statsdProcessor) serveTCP() {
+	defer proc.wg.Done()
+
+	listener := proc.listener
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.Errorf("Processor \"%s\" failed to accept statsd connection: %v", proc.cfg.Name, err)
+			}
+			return
+		}
+
+		proc.connsMutex.Lock()
+		if proc.closed {
+			proc.connsMutex.Unlock()
+			conn.Close()
+			return
+		}
+		if len(proc.conns) >= proc.params.MaxTCPConnections {
+			proc.connsMutex.Unlock()
+			logrus.Warnf("Processor \"%s\" reached the maximum number of statsd connections, refusing %s", proc.cfg.Name, conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		proc.conns[conn] = struct{}{}
+		proc.connsMutex.Unlock()
+
+		proc.wg.Add(1)
+		go proc.serveConn(conn)
+	}
+}
+
+func (proc *statsdProcessor) serveConn(conn net.Conn) {
+	defer proc.wg.Done()
+	defer func() {
+		proc.connsMutex.Lock()
+		delete(proc.conns, conn)
+		proc.connsMutex.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), statsdMaxPacketSize)
+	for scanner.Scan() {
+		proc.handleLine(scanner.Text())
+	}
+}
+
+func (proc *statsdProcessor) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	// DogStatsD events and service checks are not metrics
+	if strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		return
+	}
+
+	sample, err := parseStatsdLine(line, proc.params.DataDogTags)
+	if err != nil {
+		logrus.Warnf("Processor \"%s\" dropped statsd line: %v", proc.cfg.Name, err)
+		return
+	}
+
+	err = proc.aggregate(sample)
+	if err != nil {
+		logrus.Warnf("Processor \"%s\" dropped statsd line: %v", proc.cfg.Name, err)
+	}
+}
+
+func (proc *statsdProcessor) resolveName(bucket string) (string, string, []metric.Tag) {
+	for _, tmpl := range proc.params.templates {
+		if tmpl.filter != "" && tmpl.match(bucket) {
+			return tmpl.apply(bucket, proc.params.MetricSeparator)
+		}
+	}
+	for _, tmpl := range proc.params.templates {
+		if tmpl.filter == "" {
+			return tmpl.apply(bucket, proc.params.MetricSeparator)
+		}
+	}
+	return strings.ReplaceAll(bucket, ".", proc.params.MetricSeparator), "value", nil
+}
+
+func (proc *statsdProcessor) aggregate(sample statsdSample) error {
+	name, field, tags := proc.resolveName(sample.bucket)
+	tags = append(tags, sample.tags...)
+
+	var metricType string
+	switch sample.mtype {
+	case "c":
+		metricType = "counter"
+	case "g":
+		metricType = "gauge"
+	case "s":
+		metricType = "set"
+	case "ms":
+		metricType = "timing"
+	case "h":
+		metricType = "histogram"
+	case "d":
+		metricType = "distribution"
+	}
+	tags = append(tags, metric.Tag{Key: "metric_type", Value: metricType})
+
+	mt := metric.Metric{Name: name, Tags: tags}
+	key := mt.SeriesKey()
+
+	proc.mutex.Lock()
+	defer proc.mutex.Unlock()
+
+	switch sample.mtype {
+	case "c":
+		value, err := strconv.ParseFloat(sample.value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid counter value: %s", sample.value)
+		}
+		counter, ok := proc.counters[key]
+		if !ok {
+			counter = &statsdCounter{name: name, tags: tags, fields: make(map[string]float64), window: make(map[string]float64)}
+			proc.counters[key] = counter
+		}
+		counter.fields[field] += value / sample.sampleRate
+		counter.window[field] += value / sample.sampleRate
+	case "g":
+		value, err := strconv.ParseFloat(sample.value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid gauge value: %s", sample.value)
+		}
+		gauge, ok := proc.gauges[key]
+		if !ok {
+			gauge = &statsdGauge{name: name, tags: tags, fields: make(map[string]float64)}
+			proc.gauges[key] = gauge
+		}
+		if strings.HasPrefix(sample.value, "+") || strings.HasPrefix(sample.value, "-") {
+			gauge.fields[field] += value
+		} else {
+			gauge.fields[field] = value
+		}
+	case "s":
+		set, ok := proc.sets[key]
+		if !ok {
+			set = &statsdSet{name: name, tags: tags, fields: make(map[string]map[string]struct{})}
+			proc.sets[key] = set
+		}
+		members, ok := set.fields[field]
+		if !ok {
+			members = make(map[string]struct{})
+			set.fields[field] = members
+		}
+		members[sample.value] = struct{}{}
+	default:
+		value, err := strconv.ParseFloat(sample.value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %s", metricType, sample.value)
+		}
+		timing, ok := proc.timings[key]
+		if !ok {
+			timing = &statsdTiming{name: name, tags: tags, fields: make(map[string]*statsdStats)}
+			proc.timings[key] = timing
+		}
+		stats, ok := timing.fields[field]
+		if !ok {
+			stats = &statsdStats{}
+			timing.fields[field] = stats
+		}
+		stats.add(value, sample.sampleRate, proc.params.PercentileLimit)
+	}
+
+	return nil
+}
+
+// statsdFieldName prefixes a statistic with the field name unless the field is the default one
+func statsdFieldName(field string, stat string) string {
+	if field == "value" {
+		return stat
+	}
+	return field + "_" + stat
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (proc *statsdProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *statsdProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	proc.mutex.Lock()
+	defer proc.mutex.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(proc.lastFlush).Seconds()
+	proc.lastFlush = now
+
+	out := []metric.Metric{}
+	newMetric := func(name string, tags []metric.Tag) metric.Metric {
+		copied := make([]metric.Tag, len(tags))
+		copy(copied, tags)
+		return metric.Metric{Name: name, Tags: copied, Time: now}
+	}
+
+	for _, key := range sortedKeys(proc.counters) {
+		counter := proc.counters[key]
+		mt := newMetric(counter.name, counter.tags)
+		for _, field := range sortedKeys(counter.fields) {
+			value := counter.fields[field]
+			mt.AddField(field, int64(math.Round(value)))
+			if elapsed > 0 {
+				mt.AddField(statsdFieldName(field, "rate"), counter.window[field]/elapsed)
+			}
+		}
+		counter.window = make(map[string]float64)
+		out = append(out, mt)
+	}
+
+	for _, key := range sortedKeys(proc.gauges) {
+		gauge := proc.gauges[key]
+		mt := newMetric(gauge.name, gauge.tags)
+		for _, field := range sortedKeys(gauge.fields) {
+			mt.AddField(field, gauge.fields[field])
+		}
+		out = append(out, mt)
+	}
+
+	for _, key := range sortedKeys(proc.sets) {
+		set := proc.sets[key]
+		mt := newMetric(set.name, set.tags)
+		for _, field := range sortedKeys(set.fields) {
+			mt.AddField(field, int64(len(set.fields[field])))
+		}
+		out = append(out, mt)
+	}
+
+	for _, key := range sortedKeys(proc.timings) {
+		timing := proc.timings[key]
+		mt := newMetric(timing.name, timing.tags)
+		for _, field := range sortedKeys(timing.fields) {
+			stats := timing.fields[field]
+			mt.AddField(statsdFieldName(field, "count"), int64(math.Round(stats.count)))
+			mt.AddField(statsdFieldName(field, "lower"), stats.min)
+			mt.AddField(statsdFieldName(field, "upper"), stats.max)
+			mt.AddField(statsdFieldName(field, "mean"), stats.mean())
+			mt.AddField(statsdFieldName(field, "stddev"), stats.stddev())
+			mt.AddField(statsdFieldName(field, "sum"), stats.sum)
+			if elapsed > 0 {
+				mt.AddField(statsdFieldName(field, "rate"), stats.window/elapsed)
+			}
+			stats.window = 0
+			for _, pct := range proc.params.Percentiles {
+				stat := "p" + strings.ReplaceAll(strconv.FormatFloat(pct, 'f', -1, 64), ".", "_")
+				mt.AddField(statsdFieldName(field, stat), stats.percentile(pct))
+			}
+		}
+		out = append(out, mt)
+	}
+
+	if proc.params.DeleteCounters {
+		proc.counters = make(map[string]*statsdCounter)
+	}
+	if proc.params.DeleteGauges {
+		proc.gauges = make(map[string]*statsdGauge)
+	}
+	if proc.params.DeleteSets {
+		proc.sets = make(map[string]*statsdSet)
+	}
+	if proc.params.DeleteTimings {
+		proc.timings = make(map[string]*statsdTiming)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/expinc/melegraf/config"
+)
+
+type StatsdConfig struct {
+	// Protocol is either "udp" or "tcp"
+	Protocol string `json:"protocol"`
+	// Address is the address to listen on, e.g. ":8125"
+	Address string `json:"address"`
+	// MaxTCPConnections limits the number of concurrent TCP connections
+	MaxTCPConnections int `json:"maxTcpConnections"`
+	// Percentiles to calculate for timings and histograms
+	Percentiles []float64 `json:"percentiles"`
+	// PercentileLimit is the maximum number of values kept per timing for percentile calculation
+	PercentileLimit int `json:"percentileLimit"`
+	// Templates map bucket names to metric names, fields and tags,
+	// e.g. "cpu.* measurement.field.host"
+	Templates []string `json:"templates"`
+	// MetricSeparator joins the parts of a bucket name to form a metric name
+	MetricSeparator string `json:"metricSeparator"`
+	// DataDogTags enables parsing of DogStatsD tags, e.g. "|#host:web01"
+	DataDogTags bool `json:"dataDogTags"`
+	// DeleteCounters, DeleteGauges, DeleteSets and DeleteTimings
+	// reset the corresponding aggregations after each flush
+	DeleteCounters bool `json:"deleteCounters"`
+	DeleteGauges   bool `json:"deleteGauges"`
+	DeleteSets     bool `json:"deleteSets"`
+	DeleteTimings  bool `json:"deleteTimings"`
+
+	templates []*statsdTemplate
+}
+
+var _ config.CustomConfig = (*StatsdConfig)(nil)
+
+func NewStatsdConfig() config.CustomConfig {
+	return &StatsdConfig{
+		Protocol:          "udp",
+		Address:           ":8125",
+		MaxTCPConnections: 250,
+		Percentiles:       []float64{90},
+		PercentileLimit:   1000,
+		MetricSeparator:   "_",
+		DataDogTags:       true,
+		DeleteCounters:    true,
+		DeleteGauges:      true,
+		DeleteSets:        true,
+		DeleteTimings:     true,
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeStatsd, NewStatsdConfig)
+}
+
+func (cfg *StatsdConfig) Validate() error {
+	if cfg.Protocol != "udp" && cfg.Protocol != "tcp" {
+		return fmt.Errorf("invalid statsd protocol: %s", cfg.Protocol)
+	}
+
+	if strings.TrimSpace(cfg.Address) == "" {
+		return errors.New("address is required")
+	}
+
+	if cfg.Protocol == "tcp" && cfg.MaxTCPConnections <= 0 {
+		return errors.New("maxTcpConnections must be a positive number")
+	}
+
+	for _, pct := range cfg.Percentiles {
+		if pct <= 0 || pct > 100 {
+			return fmt.Errorf("invalid percentile: %v", pct)
+		}
+	}
+
+	if cfg.PercentileLimit <= 0 {
+		return errors.New("percentileLimit must be a positive number")
+	}
+
+	templates := make([]*statsdTemplate, 0, len(cfg.Templates))
+	for _, str := range cfg.Templates {
+		tmpl, err := parseStatsdTemplate(str)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, tmpl)
+	}
+	cfg.templates = templates
+
+	return nil
+}
+
+func (cfg *StatsdConfig) UnmarshalJSON(data []byte) error {
+	type plain StatsdConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
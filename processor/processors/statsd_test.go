@@ -0,0 +1,212 @@
+package processors
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// findMetric returns the metric with the given name and exactly the given tags
+func findMetric(metrics []metric.Metric, name string, tags ...metric.Tag) *metric.Metric {
+	for i := range metrics {
+		if metrics[i].Name != name || len(metrics[i].Tags) != len(tags) {
+			continue
+		}
+		matched := true
+		for _, tag := range tags {
+			value, err := metrics[i].GetTag(tag.Key)
+			if err != nil || value != tag.Value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return &metrics[i]
+		}
+	}
+	return nil
+}
+
+func TestStatsdAggregation(t *testing.T) {
+	proc := newTestProcessorFromConfig(t, `
+	{
+		"name": "statsd",
+		"type": "statsd",
+		"cronSpec": "@every 10s",
+		"params": {
+			"address": "127.0.0.1:0",
+			"percentiles": [50, 99.9],
+			"templates": ["servers.* measurement.host.field"]
+		}
+	}`).(*statsdProcessor)
+
+	lines := []string{
+		"requests:1|c",
+		"requests:2|c|@0.5",
+		"requests:1|c|#region:eu,canary",
+		"temperature:20|g",
+		"temperature:+5|g",
+		"temperature:-3|g",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+		"latency:10|ms",
+		"latency:20|ms",
+		"latency:30|ms",
+		"latency:40|ms",
+		"servers.web01.load:1.5|g",
+		"_e{5,4}:title|text",
+		"broken line",
+	}
+	for _, line := range lines {
+		proc.handleLine(line)
+	}
+
+	out, err := proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt := findMetric(out, "requests", metric.Tag{Key: "metric_type", Value: "counter"})
+	if mt == nil {
+		t.Fatal("counter metric not found")
+	}
+	if value, _ := mt.GetField("value"); value != int64(5) {
+		t.Errorf("invalid counter value: %v", value)
+	}
+	if _, err := mt.GetField("rate"); err != nil {
+		t.Error(err)
+	}
+
+	mt = findMetric(out, "requests", metric.Tag{Key: "metric_type", Value: "counter"}, metric.Tag{Key: "region", Value: "eu"}, metric.Tag{Key: "canary", Value: "true"})
+	if mt == nil {
+		t.Fatal("tagged counter metric not found")
+	}
+
+	mt = findMetric(out, "temperature", metric.Tag{Key: "metric_type", Value: "gauge"})
+	if mt == nil {
+		t.Fatal("gauge metric not found")
+	}
+	if value, _ := mt.GetField("value"); value != 22.0 {
+		t.Errorf("invalid gauge value: %v", value)
+	}
+
+	mt = findMetric(out, "users", metric.Tag{Key: "metric_type", Value: "set"})
+	if mt == nil {
+		t.Fatal("set metric not found")
+	}
+	if value, _ := mt.GetField("value"); value != int64(2) {
+		t.Errorf("invalid set cardinality: %v", value)
+	}
+
+	mt = findMetric(out, "latency", metric.Tag{Key: "metric_type", Value: "timing"})
+	if mt == nil {
+		t.Fatal("timing metric not found")
+	}
+	expected := map[string]interface{}{
+		"count":  int64(4),
+		"lower":  10.0,
+		"upper":  40.0,
+		"mean":   25.0,
+		"sum":    100.0,
+		"p50":    20.0,
+		"p99_9":  40.0,
+		"stddev": 11.180339887498949,
+	}
+	for key, value := range expected {
+		actual, err := mt.GetField(key)
+		if err != nil {
+			t.Error(err)
+		} else if actual != value {
+			t.Errorf("invalid timing field %s: %v", key, actual)
+		}
+	}
+
+	mt = findMetric(out, "servers", metric.Tag{Key: "metric_type", Value: "gauge"}, metric.Tag{Key: "host", Value: "web01"})
+	if mt == nil {
+		t.Fatal("templated metric not found")
+	}
+	if value, _ := mt.GetField("load"); value != 1.5 {
+		t.Errorf("invalid templated field: %v", value)
+	}
+
+	// aggregations are reset after flush
+	out, err = proc.OnCronTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("aggregations should be reset after flush, got %d metrics", len(out))
+	}
+}
+
+func TestStatsdListen(t *testing.T) {
+	for _, protocol := range []string{"udp", "tcp"} {
+		proc := newTestProcessorFromConfig(t, `
+		{
+			"name": "statsd",
+			"type": "statsd",
+			"cronSpec": "@every 10s",
+			"params": {
+				"protocol": "`+protocol+`",
+				"address": "127.0.0.1:0"
+			}
+		}`).(*statsdProcessor)
+		err := proc.Setup(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial(protocol, proc.addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = conn.Write([]byte("hits:1|c\nhits:2|c\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+
+		// wait for the listener to aggregate the lines
+		var value interface{}
+		deadline := time.Now().Add(5 * time.Second)
+		total := int64(0)
+		for time.Now().Before(deadline) && total < 3 {
+			time.Sleep(50 * time.Millisecond)
+			out, _ := proc.OnCronTrigger()
+			if mt := findMetric(out, "hits", metric.Tag{Key: "metric_type", Value: "counter"}); mt != nil {
+				value, _ = mt.GetField("value")
+				total += value.(int64)
+			}
+		}
+		if total != 3 {
+			t.Errorf("invalid counter value received over %s: %d", protocol, total)
+		}
+
+		err = proc.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestStatsdConfigInvalid(t *testing.T) {
+	cfgs := []string{
+		`{"protocol": "sctp"}`,
+		`{"percentiles": [0]}`,
+		`{"templates": ["a b c"]}`,
+	}
+	for _, cfgStr := range cfgs {
+		cfg := NewStatsdConfig()
+		err := json.Unmarshal([]byte(cfgStr), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", cfgStr)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package processors
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ProcessorTypeSyslog = "syslog"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeSyslog, NewSyslogProcessor)
+}
+
+type syslogProcessor struct {
+	cfg     *config.ProcessorConfig
+	params  *SyslogConfig
+	emitter processor.Emitter
+
+	packetConn net.PacketConn
+	listener   net.Listener
+	conns      map[net.Conn]struct{}
+	connsMutex sync.Mutex
+	// closed tells whether the connections were closed, connections accepted afterwards are closed at once
+	closed bool
+	wg     sync.WaitGroup
+}
+
+var _ processor.Processor = (*syslogProcessor)(nil)
+
+// NewSyslogProcessor creates a new syslog processor
+func NewSyslogProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*SyslogConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for syslog processor: %T", cfg.Params)
+	}
+
+	return &syslogProcessor{
+		cfg:    cfg,
+		params: params,
+	}, nil
+}
+
+func (proc *syslogProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *syslogProcessor) Setup(emitter processor.Emitter) error {
+	proc.emitter = emitter
+
+	// remove the socket left by a previous run
+	if proc.params.Protocol == "unix" || proc.params.Protocol == "unixgram" {
+		err := os.Remove(proc.params.Address)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if proc.params.isStream() {
+		listener, err := net.Listen(proc.params.Protocol, proc.params.Address)
+		if err != nil {
+			return err
+		}
+		proc.listener = listener
+		proc.conns = make(map[net.Conn]struct{})
+		proc.closed = false
+		proc.wg.Add(1)
+		go proc.serveStream()
+	} else {
+		conn, err := net.ListenPacket(proc.params.Protocol, proc.params.Address)
+		if err != nil {
+			return err
+		}
+		proc.packetConn = conn
+		proc.wg.Add(1)
+		go proc.servePacket()
+	}
+
+	return nil
+}
+
+func (proc *syslogProcessor) Close() error {
+	var err error
+	if proc.packetConn != nil {
+		err = proc.packetConn.Close()
+		proc.packetConn = nil
+		if proc.params.Protocol == "unixgram" {
+			os.Remove(proc.params.Address)
+		}
+	}
+
+	if proc.listener != nil {
+		err = proc.listener.Close()
+		proc.listener = nil
+
+		proc.connsMutex.Lock()
+		proc.closed = true
+		for conn := range proc.conns {
+			conn.Close()
+		}
+		proc.connsMutex.Unlock()
+	}
+
+	proc.wg.Wait()
+	return err
+}
+
+// addr returns the address the processor is listening on
+func (proc *syslogProcessor) addr() net.Addr {
+	if proc.packetConn != nil {
+		return proc.packetConn.LocalAddr()
+	}
+	if proc.listener != nil {
+		return proc.listener.Addr()
+	}
+	return nil
+}
+
+func (proc *syslogProcessor) servePacket() {
+	defer proc.wg.Done()
+
+	conn := proc.packetConn
+	buf := make([]byte, proc.params.MaxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.Errorf("Processor \"%s\" failed to read syslog message: %v", proc.cfg.Name, err)
+			}
+			return
+		}
+
+		proc.handleMessage(string(buf[:n]), addr)
+	}
+}
+
+func (proc *syslogProcessor) serveStream() {
+	defer proc.wg.Done()
+
+	listener := proc.listener
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.Errorf("Processor \"%s\" failed to accept syslog connection: %v", proc.cfg.Name, err)
+			}
+			return
+		}
+
+		proc.connsMutex.Lock()
+		if proc.closed {
+			proc.connsMutex.Unlock()
+			conn.Close()
+			return
+		}
+		if len(proc.conns) >= proc.params.MaxConnections {
+			proc.connsMutex.Unlock()
+			logrus.Warnf("Processor \"%s\" reached the maximum number of syslog connections, refusing %s", proc.cfg.Name, conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		proc.conns[conn] = struct{}{}
+		proc.connsMutex.Unlock()
+
+		proc.wg.Add(1)
+		go proc.serveConn(conn)
+	}
+}
+
+func (proc *syslogProcessor) serveConn(conn net.Conn) {
+	defer proc.wg.Done()
+	defer func() {
+		proc.connsMutex.Lock()
+		delete(proc.conns, conn)
+		proc.connsMutex.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		if proc.params.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(proc.params.ReadTimeout)))
+		}
+
+		var data string
+		var err error
+		if proc.params.Framing == "octet-counting" {
+			data, err = proc.readOctetCounted(reader)
+		} else {
+			data, err = proc.readNonTransparent(reader)
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				logrus.Errorf("Processor \"%s\" failed to read syslog message from %s: %v", proc.cfg.Name, conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		proc.handleMessage(data, conn.RemoteAddr())
+	}
+}
+
+// readOctetCounted reads a message framed as "<length> <message>" defined in RFC6587
+func (proc *syslogProcessor) readOctetCounted(reader *bufio.Reader) (string, error) {
+	// the length has at most as many digits as the maximum size
+	lengthStr, err := readDelimited(reader, ' ', len(strconv.Itoa(proc.params.MaxMessageSize))+1)
+	if err == errDelimiterNotFound {
+		return "", fmt.Errorf("syslog message length exceeds the maximum %d", proc.params.MaxMessageSize)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	length, err := strconv.Atoi(lengthStr[:len(lengthStr)-1])
+	if err != nil || length <= 0 {
+		return "", fmt.Errorf("invalid syslog message length: %q", lengthStr)
+	}
+	if length > proc.params.MaxMessageSize {
+		return "", fmt.Errorf("syslog message length %d exceeds the maximum %d", length, proc.params.MaxMessageSize)
+	}
+
+	buf := make([]byte, length)
+	_, err = io.ReadFull(reader, buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// readNonTransparent reads a message terminated by the trailer defined in RFC6587
+func (proc *syslogProcessor) readNonTransparent(reader *bufio.Reader) (string, error) {
+	trailer := byte('\n')
+	if proc.params.Trailer == "NUL" {
+		trailer = 0
+	}
+
+	for {
+		// the limit allows for the trailer preceded by a carriage return
+		data, err := readDelimited(reader, trailer, proc.params.MaxMessageSize+2)
+		if err == errDelimiterNotFound {
+			return "", fmt.Errorf("syslog message exceeds the maximum length %d", proc.params.MaxMessageSize)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		data = data[:len(data)-1]
+		if len(data) > 0 && data[len(data)-1] == '\r' {
+			data = data[:len(data)-1]
+		}
+		if len(data) > proc.params.MaxMessageSize {
+			return "", fmt.Errorf("syslog message exceeds the maximum length %d", proc.params.MaxMessageSize)
+		}
+		if data != "" {
+			return data, nil
+		}
+	}
+}
+
+// errDelimiterNotFound is returned by readDelimited if the delimiter is not within the limit
+var errDelimiterNotFound = errors.New("delimiter not found within the limit")
+
+// readDelimited reads until the delimiter like ReadString, but fails as soon as more than limit bytes are read
+func readDelimited(reader *bufio.Reader, delim byte, limit int) (string, error) {
+	var data []byte
+	for {
+		chunk, err := reader.ReadSlice(delim)
+		if len(data)+len(chunk) > limit {
+			return "", errDelimiterNotFound
+		}
+		data = append(data, chunk...)
+		if err != bufio.ErrBufferFull {
+			return string(data), err
+		}
+	}
+}
+
+func (proc *syslogProcessor) handleMessage(data string, source net.Addr) {
+	now := time.Now()
+	msg, err := parseSyslogMessage(data, now)
+	if err != nil {
+		logrus.Warnf("Processor \"%s\" dropped syslog message: %v", proc.cfg.Name, err)
+		return
+	}
+
+	mt := proc.toMetric(msg, source, now)
+	err = proc.emitter.Emit(mt)
+	if err != nil {
+		logrus.Errorf("Processor \"%s\" failed to emit syslog message: %v", proc.cfg.Name, err)
+	}
+}
+
+func (proc *syslogProcessor) toMetric(msg *syslogMessage, source net.Addr, now time.Time) metric.Metric {
+	mt := metric.Metric{Name: "syslog", Time: now}
+	if proc.params.TimeSource == "message" && !msg.timestamp.IsZero() {
+		mt.Time = msg.timestamp
+	}
+
+	mt.AddTag("facility", syslogFacilities[msg.facility])
+	mt.AddTag("severity", syslogSeverities[msg.severity])
+	if msg.hostname != "" {
+		mt.AddTag("hostname", msg.hostname)
+	}
+	if msg.appname != "" {
+		mt.AddTag("appname", msg.appname)
+	}
+	if source != nil {
+		if host, _, err := net.SplitHostPort(source.String()); err == nil {
+			mt.AddTag("source", host)
+		}
+	}
+
+	mt.AddField("message", msg.message)
+	mt.AddField("facility_code", int64(msg.facility))
+	mt.AddField("severity_code", int64(msg.severity))
+	if msg.version > 0 {
+		mt.AddField("version", int64(msg.version))
+	}
+	if msg.procid != "" {
+		mt.AddField("procid", msg.procid)
+	}
+	if msg.msgid != "" {
+		mt.AddField("msgid", msg.msgid)
+	}
+	for _, id := range sortedKeys(msg.structuredData) {
+		params := msg.structuredData[id]
+		for _, name := range sortedKeys(params) {
+			mt.AddField(id+"_"+name, params[name])
+		}
+	}
+
+	return mt
+}
+
+func (proc *syslogProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *syslogProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, nil
+}
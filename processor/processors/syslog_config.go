@@ -0,0 +1,95 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/expinc/melegraf/config"
+)
+
+type SyslogConfig struct {
+	// Protocol is one of "udp", "tcp", "unix" and "unixgram"
+	Protocol string `json:"protocol"`
+	// Address is the address to listen on, e.g. ":6514" or "/var/run/melegraf-syslog.sock"
+	Address string `json:"address"`
+	// Framing of stream protocols, either "octet-counting" or "non-transparent"
+	Framing string `json:"framing"`
+	// Trailer ends a message with non-transparent framing, either "LF" or "NUL"
+	Trailer string `json:"trailer"`
+	// MaxConnections limits the number of concurrent stream connections
+	MaxConnections int `json:"maxConnections"`
+	// ReadTimeout closes idle stream connections, zero means no timeout
+	ReadTimeout config.Duration `json:"readTimeout"`
+	// MaxMessageSize limits the size of a single message
+	MaxMessageSize int `json:"maxMessageSize"`
+	// TimeSource is either "message" to use the timestamp in the message
+	// or "receive" to use the time the message is received
+	TimeSource string `json:"timeSource"`
+}
+
+var _ config.CustomConfig = (*SyslogConfig)(nil)
+
+func NewSyslogConfig() config.CustomConfig {
+	return &SyslogConfig{
+		Protocol:       "udp",
+		Address:        ":6514",
+		Framing:        "octet-counting",
+		Trailer:        "LF",
+		MaxConnections: 250,
+		MaxMessageSize: 64 * 1024,
+		TimeSource:     "message",
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeSyslog, NewSyslogConfig)
+}
+
+func (cfg *SyslogConfig) isStream() bool {
+	return cfg.Protocol == "tcp" || cfg.Protocol == "unix"
+}
+
+func (cfg *SyslogConfig) Validate() error {
+	switch cfg.Protocol {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return fmt.Errorf("invalid syslog protocol: %s", cfg.Protocol)
+	}
+
+	if strings.TrimSpace(cfg.Address) == "" {
+		return errors.New("address is required")
+	}
+
+	if cfg.Framing != "octet-counting" && cfg.Framing != "non-transparent" {
+		return fmt.Errorf("invalid syslog framing: %s", cfg.Framing)
+	}
+
+	if cfg.Trailer != "LF" && cfg.Trailer != "NUL" {
+		return fmt.Errorf("invalid syslog trailer: %s", cfg.Trailer)
+	}
+
+	if cfg.isStream() && cfg.MaxConnections <= 0 {
+		return errors.New("maxConnections must be a positive number")
+	}
+
+	if cfg.ReadTimeout < 0 {
+		return errors.New("readTimeout must not be negative")
+	}
+
+	if cfg.MaxMessageSize <= 0 {
+		return errors.New("maxMessageSize must be a positive number")
+	}
+
+	if cfg.TimeSource != "message" && cfg.TimeSource != "receive" {
+		return fmt.Errorf("invalid syslog time source: %s", cfg.TimeSource)
+	}
+
+	return nil
+}
+
+func (cfg *SyslogConfig) UnmarshalJSON(data []byte) error {
+	type plain SyslogConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
@@ -0,0 +1,218 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	syslogFacilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+	syslogSeverities = []string{
+		"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+	}
+)
+
+// syslogMessage is a parsed RFC5424 or RFC3164 message
+type syslogMessage struct {
+	facility  int
+	severity  int
+	version   int
+	timestamp time.Time
+	hostname  string
+	appname   string
+	procid    string
+	msgid     string
+	// structuredData maps an SD-ID to its parameters
+	structuredData map[string]map[string]string
+	message        string
+}
+
+// parseSyslogMessage detects the format of the message and parses it
+// RFC5424 messages have a version right after the priority, e.g. "<34>1 ..."
+func parseSyslogMessage(data string, now time.Time) (*syslogMessage, error) {
+	msg := &syslogMessage{}
+	rest, err := msg.parsePriority(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && (rest[1] == ' ' || (rest[1] >= '0' && rest[1] <= '9')) {
+		err = msg.parseRFC5424(rest)
+	} else {
+		err = msg.parseRFC3164(rest, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (msg *syslogMessage) parsePriority(data string) (string, error) {
+	if !strings.HasPrefix(data, "<") {
+		return "", errors.New("syslog message must start with a priority")
+	}
+	end := strings.IndexByte(data, '>')
+	if end < 2 || end > 4 {
+		return "", errors.New("invalid syslog priority")
+	}
+	pri, err := strconv.Atoi(data[1:end])
+	if err != nil || pri > 191 {
+		return "", fmt.Errorf("invalid syslog priority: %s", data[1:end])
+	}
+
+	msg.facility = pri / 8
+	msg.severity = pri % 8
+	return data[end+1:], nil
+}
+
+// nextSyslogField splits the next space separated field from the data
+func nextSyslogField(data string) (string, string) {
+	idx := strings.IndexByte(data, ' ')
+	if idx < 0 {
+		return data, ""
+	}
+	return data[:idx], data[idx+1:]
+}
+
+// syslogNil converts the NILVALUE of RFC5424 to an empty string
+func syslogNil(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+func (msg *syslogMessage) parseRFC5424(data string) error {
+	var field string
+	var err error
+
+	field, data = nextSyslogField(data)
+	msg.version, err = strconv.Atoi(field)
+	if err != nil {
+		return fmt.Errorf("invalid syslog version: %s", field)
+	}
+
+	field, data = nextSyslogField(data)
+	if field != "-" {
+		msg.timestamp, err = time.Parse(time.RFC3339Nano, field)
+		if err != nil {
+			return fmt.Errorf("invalid syslog timestamp: %s", field)
+		}
+	}
+
+	field, data = nextSyslogField(data)
+	msg.hostname = syslogNil(field)
+	field, data = nextSyslogField(data)
+	msg.appname = syslogNil(field)
+	field, data = nextSyslogField(data)
+	msg.procid = syslogNil(field)
+	field, data = nextSyslogField(data)
+	msg.msgid = syslogNil(field)
+
+	if strings.HasPrefix(data, "-") {
+		data = strings.TrimPrefix(data[1:], " ")
+	} else if strings.HasPrefix(data, "[") {
+		data, err = msg.parseStructuredData(data)
+		if err != nil {
+			return err
+		}
+	} else {
+		return errors.New("missing syslog structured data")
+	}
+
+	msg.message = strings.TrimPrefix(data, "\ufeff")
+	return nil
+}
+
+// parseStructuredData parses the SD-ELEMENTs and returns the remaining data
+func (msg *syslogMessage) parseStructuredData(data string) (string, error) {
+	msg.structuredData = make(map[string]map[string]string)
+	for strings.HasPrefix(data, "[") {
+		end := strings.IndexAny(data, " ]")
+		if end < 0 {
+			return "", errors.New("unterminated syslog structured data")
+		}
+		id := data[1:end]
+		params := make(map[string]string)
+		msg.structuredData[id] = params
+		data = data[end:]
+
+		for strings.HasPrefix(data, " ") {
+			data = strings.TrimLeft(data, " ")
+			eq := strings.Index(data, "=\"")
+			if eq <= 0 {
+				return "", fmt.Errorf("invalid syslog structured data param in \"%s\"", id)
+			}
+			name := data[:eq]
+			data = data[eq+2:]
+
+			// the value ends at the first unescaped quote
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(data); i++ {
+				if data[i] == '\\' && i+1 < len(data) && strings.IndexByte("\"\\]", data[i+1]) >= 0 {
+					value.WriteByte(data[i+1])
+					i++
+				} else if data[i] == '"' {
+					data = data[i+1:]
+					closed = true
+					break
+				} else {
+					value.WriteByte(data[i])
+				}
+			}
+			if !closed {
+				return "", fmt.Errorf("unterminated syslog structured data value in \"%s\"", id)
+			}
+			params[name] = value.String()
+		}
+
+		if !strings.HasPrefix(data, "]") {
+			return "", fmt.Errorf("unterminated syslog structured data element \"%s\"", id)
+		}
+		data = data[1:]
+	}
+
+	return strings.TrimPrefix(data, " "), nil
+}
+
+func (msg *syslogMessage) parseRFC3164(data string, now time.Time) error {
+	// the timestamp has no year and may pad the day with a space, e.g. "Oct  9 22:14:15"
+	if len(data) >= 16 && data[15] == ' ' {
+		ts, err := time.ParseInLocation(time.Stamp, data[:15], now.Location())
+		if err == nil {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			// messages from the end of last year may arrive right after new year
+			if ts.After(now.AddDate(0, 1, 0)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			msg.timestamp = ts
+			data = data[16:]
+
+			msg.hostname, data = nextSyslogField(data)
+		}
+	}
+
+	// TAG[PID]: MSG
+	colon := strings.Index(data, ": ")
+	if colon > 0 && !strings.ContainsAny(data[:colon], " ") {
+		tag := data[:colon]
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			msg.procid = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		msg.appname = tag
+		data = data[colon+2:]
+	}
+
+	msg.message = data
+	return nil
+}
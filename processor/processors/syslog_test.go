@@ -0,0 +1,243 @@
+package processors
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// collectingEmitter keeps the emitted metrics for inspection
+type collectingEmitter struct {
+	sync.Mutex
+	metrics []metric.Metric
+}
+
+func (emitter *collectingEmitter) Emit(metrics ...metric.Metric) error {
+	emitter.Lock()
+	defer emitter.Unlock()
+	emitter.metrics = append(emitter.metrics, metrics...)
+	return nil
+}
+
+func (emitter *collectingEmitter) Stopping() <-chan struct{} {
+	return nil
+}
+
+// waitFor waits until at least count metrics are emitted and returns them
+func (emitter *collectingEmitter) waitFor(count int, timeout time.Duration) []metric.Metric {
+	deadline := time.Now().Add(timeout)
+	for {
+		emitter.Lock()
+		metrics := append([]metric.Metric(nil), emitter.metrics...)
+		emitter.Unlock()
+		if len(metrics) >= count || time.Now().After(deadline) {
+			return metrics
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParseSyslogRFC5424(t *testing.T) {
+	data := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Appl\"ication"][examplePriority@32473 class="high"] An application event`
+	msg, err := parseSyslogMessage(data, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.facility != 20 || msg.severity != 5 || msg.version != 1 {
+		t.Errorf("invalid priority or version: %d %d %d", msg.facility, msg.severity, msg.version)
+	}
+	if !msg.timestamp.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)) {
+		t.Errorf("invalid timestamp: %v", msg.timestamp)
+	}
+	if msg.hostname != "mymachine.example.com" || msg.appname != "evntslog" || msg.procid != "" || msg.msgid != "ID47" {
+		t.Errorf("invalid header: %+v", msg)
+	}
+	if msg.structuredData["exampleSDID@32473"]["eventSource"] != `Appl"ication` {
+		t.Errorf("invalid structured data: %v", msg.structuredData)
+	}
+	if msg.structuredData["examplePriority@32473"]["class"] != "high" {
+		t.Errorf("invalid structured data: %v", msg.structuredData)
+	}
+	if msg.message != "An application event" {
+		t.Errorf("invalid message: %s", msg.message)
+	}
+}
+
+func TestParseSyslogRFC3164(t *testing.T) {
+	now := time.Date(2023, 10, 12, 0, 0, 0, 0, time.UTC)
+	msg, err := parseSyslogMessage("<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.facility != 4 || msg.severity != 2 {
+		t.Errorf("invalid priority: %d %d", msg.facility, msg.severity)
+	}
+	if !msg.timestamp.Equal(time.Date(2023, 10, 11, 22, 14, 15, 0, time.UTC)) {
+		t.Errorf("invalid timestamp: %v", msg.timestamp)
+	}
+	if msg.hostname != "mymachine" || msg.appname != "su" || msg.procid != "123" {
+		t.Errorf("invalid header: %+v", msg)
+	}
+	if msg.message != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Errorf("invalid message: %s", msg.message)
+	}
+
+	// a message from last December received in January belongs to last year
+	now = time.Date(2024, 1, 1, 0, 0, 5, 0, time.UTC)
+	msg, err = parseSyslogMessage("<13>Dec 31 23:59:59 host app: bye", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.timestamp.Year() != 2023 {
+		t.Errorf("invalid year: %d", msg.timestamp.Year())
+	}
+}
+
+func TestParseSyslogInvalid(t *testing.T) {
+	for _, data := range []string{"no priority", "<999>1 - - - - - -", "<34>1 invalid-time host app - - -", "<34>1 - host app - - [unterminated"} {
+		_, err := parseSyslogMessage(data, time.Now())
+		if err == nil {
+			t.Errorf("message should be invalid: %s", data)
+		}
+	}
+}
+
+// endlessReader returns the byte forever
+type endlessReader byte
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestSyslogMaxMessageSize(t *testing.T) {
+	proc := &syslogProcessor{params: &SyslogConfig{MaxMessageSize: 8, Framing: "non-transparent"}}
+
+	reader := bufio.NewReaderSize(strings.NewReader("\n12345678\r\n"), 16)
+	if data, err := proc.readNonTransparent(reader); err != nil || data != "12345678" {
+		t.Errorf("expected a message of the maximum size but got %q, %v", data, err)
+	}
+
+	reader = bufio.NewReaderSize(strings.NewReader("123456789\n"), 16)
+	if _, err := proc.readNonTransparent(reader); err == nil {
+		t.Error("expected the message to exceed the maximum size")
+	}
+
+	// an endless line fails once the maximum size is exceeded
+	reader = bufio.NewReaderSize(endlessReader('x'), 16)
+	if _, err := proc.readNonTransparent(reader); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("expected the message to exceed the maximum size but got %v", err)
+	}
+	reader = bufio.NewReaderSize(endlessReader('9'), 16)
+	if _, err := proc.readOctetCounted(reader); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("expected the length to exceed the maximum size but got %v", err)
+	}
+
+	reader = bufio.NewReaderSize(strings.NewReader("8 12345678"), 16)
+	if data, err := proc.readOctetCounted(reader); err != nil || data != "12345678" {
+		t.Errorf("expected an octet counted message but got %q, %v", data, err)
+	}
+}
+
+func TestSyslogListen(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "syslog.sock")
+	cases := []struct {
+		protocol string
+		address  string
+		framing  string
+	}{
+		{"udp", "127.0.0.1:0", "octet-counting"},
+		{"tcp", "127.0.0.1:0", "octet-counting"},
+		{"tcp", "127.0.0.1:0", "non-transparent"},
+		{"unix", socket, "non-transparent"},
+	}
+
+	messages := []string{
+		"<165>1 2003-10-11T22:14:15.003Z web01 nginx 42 - - request served",
+		"<34>Oct 11 22:14:15 web02 su: auth failure",
+	}
+
+	for _, c := range cases {
+		proc := newTestProcessorFromConfig(t, fmt.Sprintf(`
+		{
+			"name": "syslog",
+			"type": "syslog",
+			"params": {
+				"protocol": "%s",
+				"address": "%s",
+				"framing": "%s"
+			}
+		}`, c.protocol, c.address, c.framing)).(*syslogProcessor)
+		emitter := &collectingEmitter{}
+		err := proc.Setup(emitter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial(c.protocol, proc.addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, msg := range messages {
+			var data string
+			switch {
+			case c.protocol == "udp":
+				data = msg
+			case c.framing == "octet-counting":
+				data = fmt.Sprintf("%d %s", len(msg), msg)
+			default:
+				data = msg + "\n"
+			}
+			_, err = conn.Write([]byte(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		conn.Close()
+
+		metrics := emitter.waitFor(len(messages), 5*time.Second)
+		if len(metrics) != len(messages) {
+			t.Fatalf("invalid number of metrics over %s with %s framing: %d", c.protocol, c.framing, len(metrics))
+		}
+
+		mt := metrics[0]
+		for key, value := range map[string]string{"facility": "local4", "severity": "notice", "hostname": "web01", "appname": "nginx"} {
+			if actual, _ := mt.GetTag(key); actual != value {
+				t.Errorf("invalid tag %s: %s", key, actual)
+			}
+		}
+		if value, _ := mt.GetField("message"); value != "request served" {
+			t.Errorf("invalid message: %v", value)
+		}
+		if value, _ := mt.GetField("procid"); value != "42" {
+			t.Errorf("invalid procid: %v", value)
+		}
+		if !mt.Time.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)) {
+			t.Errorf("invalid time: %v", mt.Time)
+		}
+
+		mt = metrics[1]
+		if value, _ := mt.GetTag("severity"); value != "crit" {
+			t.Errorf("invalid severity: %s", value)
+		}
+		if value, _ := mt.GetField("message"); value != "auth failure" {
+			t.Errorf("invalid message: %v", value)
+		}
+
+		err = proc.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
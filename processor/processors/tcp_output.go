@@ -0,0 +1,133 @@
+package processors
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+	"github.com/sirupsen/logrus"
+)
+
+// tcpSender writes batches of lines to a TCP server
+// The connection is dialed lazily and dropped on any failure, so that the next
+// attempt reconnects and the batcher resends the batch still in its buffer
+// It is not safe for concurrent use
+type tcpSender struct {
+	name    string
+	address string
+	timeout time.Duration
+	conn    net.Conn
+	// closed is closed when the server closes conn
+	closed chan struct{}
+}
+
+func newTCPSender(name string, address string, timeout time.Duration) *tcpSender {
+	return &tcpSender{
+		name:    name,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (s *tcpSender) write(data []byte) error {
+	if s.conn != nil && !s.alive() {
+		s.close()
+	}
+
+	if s.conn == nil {
+		conn, err := net.DialTimeout("tcp", s.address, s.timeout)
+		if err != nil {
+			return &sendError{err: err, retriable: true}
+		}
+		s.conn = conn
+		s.closed = make(chan struct{})
+		go s.watch(conn, s.closed)
+	}
+
+	err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	if err == nil {
+		_, err = s.conn.Write(data)
+	}
+	if err != nil {
+		s.close()
+		return &sendError{err: err, retriable: true}
+	}
+	return nil
+}
+
+// watch reads the connection until the server closes it
+// Writes to a connection closed by the peer may succeed once and lose the data,
+// so the loss is detected by the reads before the next write
+func (s *tcpSender) watch(conn net.Conn, closed chan struct{}) {
+	defer close(closed)
+	buf := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			logrus.Warnf("Processor \"%s\" received from %s: %s", s.name, s.address, bytes.TrimSpace(buf[:n]))
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logrus.Warnf("Processor \"%s\" lost connection to %s: %v", s.name, s.address, err)
+			}
+			return
+		}
+	}
+}
+
+// alive tells whether the connection has not been closed by the server
+func (s *tcpSender) alive() bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *tcpSender) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// formatTCPOutputValue formats a numeric or boolean field value for plaintext protocols
+// Integers are kept exact and booleans are written as 1 or 0
+func formatTCPOutputValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case int:
+		return strconv.FormatInt(int64(v), 10), true
+	case int8:
+		return strconv.FormatInt(int64(v), 10), true
+	case int16:
+		return strconv.FormatInt(int64(v), 10), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	}
+
+	f, ok := metric.ToFloat(value)
+	if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
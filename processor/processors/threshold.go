@@ -0,0 +1,236 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeThreshold = "threshold"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeThreshold, NewThresholdProcessor)
+}
+
+// thresholdState is the state of a series, ordered by severity
+type thresholdState int
+
+const (
+	thresholdOK thresholdState = iota
+	thresholdWarn
+	thresholdCrit
+)
+
+func (state thresholdState) String() string {
+	switch state {
+	case thresholdWarn:
+		return "WARN"
+	case thresholdCrit:
+		return "CRIT"
+	default:
+		return "OK"
+	}
+}
+
+// thresholdSeries is the state of a series for a rule
+type thresholdSeries struct {
+	state thresholdState
+	// pending is the metric time since which the thresholds of a higher state are breached, zero if they are not
+	pending time.Time
+	// notified is the metric time of the last alert
+	notified time.Time
+	updated  time.Time
+}
+
+type thresholdProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *ThresholdConfig
+	series []map[string]*thresholdSeries
+	// expired is the time of the last expiry, which also runs on receiving at most once per TTL
+	expired time.Time
+}
+
+var _ processor.Processor = (*thresholdProcessor)(nil)
+
+// NewThresholdProcessor creates a new threshold processor
+func NewThresholdProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*ThresholdConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for threshold processor: %T", cfg.Params)
+	}
+
+	series := make([]map[string]*thresholdSeries, len(params.Rules))
+	for i := range series {
+		series[i] = make(map[string]*thresholdSeries)
+	}
+	return &thresholdProcessor{
+		cfg:    cfg,
+		params: params,
+		series: series,
+	}, nil
+}
+
+func (proc *thresholdProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *thresholdProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *thresholdProcessor) Close() error {
+	return nil
+}
+
+func (proc *thresholdProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.process(mt, time.Now())
+}
+
+func (proc *thresholdProcessor) process(mt metric.Metric, now time.Time) ([]metric.Metric, error) {
+	if now.Sub(proc.expired) >= time.Duration(proc.params.TTL) {
+		proc.expire(now)
+	}
+
+	out := []metric.Metric{}
+	if !proc.params.DropOriginal {
+		out = append(out, mt)
+	}
+
+	var key string
+	for i, rule := range proc.params.Rules {
+		if rule.condition != nil {
+			apply, err := rule.condition.EvalBool(&mt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate condition \"%s\" of rule \"%s\": %w", rule.condition, rule.Name, err)
+			}
+			if !apply {
+				continue
+			}
+		}
+
+		field, err := mt.GetField(rule.Field)
+		if err != nil {
+			continue
+		}
+		value, ok := numericValue(field)
+		if !ok || math.IsNaN(value) {
+			continue
+		}
+
+		if key == "" {
+			key = mt.SeriesKey()
+		}
+		series, ok := proc.series[i][key]
+		if !ok {
+			series = &thresholdSeries{}
+			proc.series[i][key] = series
+		}
+		series.updated = now
+
+		previous, notify := proc.evaluate(rule, series, value, metricTime(mt, now))
+		if notify {
+			out = append(out, proc.alert(mt, rule, previous, series.state, value))
+		}
+	}
+	return out, nil
+}
+
+// evaluate updates the state of a series with a value at the metric time
+// It returns the previous state and whether an alert is to be emitted
+func (proc *thresholdProcessor) evaluate(rule ThresholdRule, series *thresholdSeries, value float64, t time.Time) (thresholdState, bool) {
+	target := thresholdOK
+	if rule.Warn != nil && breached(rule.Warn, value, series.state >= thresholdWarn, rule.Hysteresis) {
+		target = thresholdWarn
+	}
+	if rule.Crit != nil && breached(rule.Crit, value, series.state >= thresholdCrit, rule.Hysteresis) {
+		target = thresholdCrit
+	}
+
+	previous := series.state
+	if target > series.state && rule.For > 0 {
+		if series.pending.IsZero() {
+			series.pending = t
+		}
+		if t.Sub(series.pending) < time.Duration(rule.For) {
+			return previous, proc.renotify(series, t)
+		}
+	}
+	if target <= series.state {
+		series.pending = time.Time{}
+	}
+
+	if target == series.state {
+		return previous, proc.renotify(series, t)
+	}
+	series.state = target
+	series.pending = time.Time{}
+	series.notified = t
+	return previous, true
+}
+
+// renotify tells whether the alert of a series in WARN or CRIT state is to be emitted again
+func (proc *thresholdProcessor) renotify(series *thresholdSeries, t time.Time) bool {
+	if series.state == thresholdOK || proc.params.Renotify == 0 || t.Sub(series.notified) < time.Duration(proc.params.Renotify) {
+		return false
+	}
+	series.notified = t
+	return true
+}
+
+// breached tells whether a value breaches the thresholds of a level
+// The thresholds of an active level are relaxed by the hysteresis
+func breached(level *ThresholdLevel, value float64, active bool, hysteresis float64) bool {
+	if !active {
+		hysteresis = 0
+	}
+	if level.Above != nil && value > *level.Above-hysteresis {
+		return true
+	}
+	return level.Below != nil && value < *level.Below+hysteresis
+}
+
+func (proc *thresholdProcessor) alert(mt metric.Metric, rule ThresholdRule, previous, state thresholdState, value float64) metric.Metric {
+	tags := make([]metric.Tag, len(mt.Tags), len(mt.Tags)+2)
+	copy(tags, mt.Tags)
+	tags = setTag(tags, "alertname", rule.Name)
+	tags = setTag(tags, "metric", mt.Name)
+
+	return metric.Metric{
+		Name: proc.params.MetricName,
+		Tags: tags,
+		Fields: []metric.Field{
+			{Key: "state", Value: state.String()},
+			{Key: "previous_state", Value: previous.String()},
+			{Key: "value", Value: value},
+		},
+		Time: mt.Time,
+	}
+}
+
+func (proc *thresholdProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	proc.expire(time.Now())
+	return nil, nil
+}
+
+// expire removes the series not received within the TTL
+func (proc *thresholdProcessor) expire(now time.Time) {
+	proc.expired = now
+	for _, series := range proc.series {
+		for key, s := range series {
+			if now.Sub(s.updated) > time.Duration(proc.params.TTL) {
+				delete(series, key)
+			}
+		}
+	}
+}
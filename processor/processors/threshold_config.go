@@ -0,0 +1,141 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/expr"
+)
+
+// ThresholdConfig evaluates threshold rules per series and emits alert metrics when their states change
+// The states are OK, WARN and CRIT, and every series of a rule starts as OK
+// Alert metrics are named by MetricName and carry the tags of the series, the tag "alertname" of the rule,
+// the tag "metric" of the original name, and the fields "state", "previous_state" and "value"
+// Durations are measured in metric time, which is the receive time of the metrics without a time
+type ThresholdConfig struct {
+	Rules []ThresholdRule `json:"rules"`
+	// MetricName is the name of the alert metrics
+	MetricName string `json:"metricName"`
+	// Renotify emits the alert metric of a series in WARN or CRIT state again
+	// once its metric time is the interval after the last alert, disabled if zero
+	Renotify config.Duration `json:"renotify"`
+	// TTL removes the state of a series not received for the duration, it starts as OK again
+	TTL config.Duration `json:"ttl"`
+	// DropOriginal drops the received metrics instead of passing them
+	DropOriginal bool `json:"dropOriginal"`
+}
+
+// ThresholdRule compares a field with the thresholds of the WARN and CRIT levels
+type ThresholdRule struct {
+	// Name identifies the rule in the alert metrics
+	Name string `json:"name"`
+	// Field is the key of the numeric field compared
+	Field string `json:"field"`
+	// Condition applies the rule only to the metrics for which it is true, optional
+	Condition string `json:"condition"`
+	// Warn and Crit are the thresholds of the levels, at least one is required
+	Warn *ThresholdLevel `json:"warn"`
+	Crit *ThresholdLevel `json:"crit"`
+	// For is how long, in metric time, the thresholds must be breached before the state is raised
+	// States are lowered immediately
+	For config.Duration `json:"for"`
+	// Hysteresis is the margin by which the values must get back within a threshold to leave its level
+	Hysteresis float64 `json:"hysteresis"`
+
+	condition *expr.Program
+}
+
+// ThresholdLevel is breached by values above Above or below Below
+// A value outside a range is set by both
+type ThresholdLevel struct {
+	Above *float64 `json:"above"`
+	Below *float64 `json:"below"`
+}
+
+var _ config.CustomConfig = (*ThresholdConfig)(nil)
+
+func NewThresholdConfig() config.CustomConfig {
+	return &ThresholdConfig{
+		MetricName: "alert",
+		TTL:        config.Duration(10 * time.Minute),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeThreshold, NewThresholdConfig)
+}
+
+func (cfg *ThresholdConfig) Validate() error {
+	if len(cfg.Rules) == 0 {
+		return fmt.Errorf("rules are required")
+	}
+	if strings.TrimSpace(cfg.MetricName) == "" {
+		return fmt.Errorf("metric name is required")
+	}
+	if cfg.Renotify < 0 {
+		return fmt.Errorf("invalid renotify interval: %v", time.Duration(cfg.Renotify))
+	}
+	if cfg.TTL <= 0 {
+		return fmt.Errorf("invalid ttl: %v", time.Duration(cfg.TTL))
+	}
+
+	names := map[string]bool{}
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if strings.TrimSpace(rule.Name) == "" {
+			return fmt.Errorf("rule %d requires a name", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if rule.Field == "" {
+			return fmt.Errorf("rule \"%s\" requires a field", rule.Name)
+		}
+		rule.condition = nil
+		if rule.Condition != "" {
+			var err error
+			rule.condition, err = expr.CompileCondition(rule.Condition)
+			if err != nil {
+				return fmt.Errorf("invalid condition \"%s\" of rule \"%s\": %w", rule.Condition, rule.Name, err)
+			}
+		}
+		if rule.Warn == nil && rule.Crit == nil {
+			return fmt.Errorf("rule \"%s\" requires warn or crit thresholds", rule.Name)
+		}
+		for _, level := range []*ThresholdLevel{rule.Warn, rule.Crit} {
+			if level == nil {
+				continue
+			}
+			if err := level.validate(); err != nil {
+				return fmt.Errorf("invalid thresholds of rule \"%s\": %w", rule.Name, err)
+			}
+		}
+		if rule.For < 0 {
+			return fmt.Errorf("invalid for duration of rule \"%s\": %v", rule.Name, time.Duration(rule.For))
+		}
+		if !(rule.Hysteresis >= 0) {
+			return fmt.Errorf("invalid hysteresis of rule \"%s\": %v", rule.Name, rule.Hysteresis)
+		}
+	}
+	return nil
+}
+
+func (level *ThresholdLevel) validate() error {
+	if level.Above == nil && level.Below == nil {
+		return fmt.Errorf("above or below is required")
+	}
+	if level.Above != nil && level.Below != nil && !(*level.Below < *level.Above) {
+		return fmt.Errorf("below %v must be less than above %v", *level.Below, *level.Above)
+	}
+	return nil
+}
+
+func (cfg *ThresholdConfig) UnmarshalJSON(data []byte) error {
+	type plain ThresholdConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
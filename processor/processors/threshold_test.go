@@ -0,0 +1,168 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// thresholdAlerts feeds values at consecutive seconds and returns the state transitions of the alerts
+func thresholdAlerts(t *testing.T, proc *thresholdProcessor, start time.Time, values ...float64) []string {
+	transitions := []string{}
+	for i, value := range values {
+		mt := newTestMetric("cpu", value)
+		mt.Time = start.Add(time.Duration(i) * time.Second)
+		out, err := proc.process(mt, time.Unix(0, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, alert := range out {
+			if alert.Name != "alert" {
+				continue
+			}
+			previous, _ := alert.GetField("previous_state")
+			state, _ := alert.GetField("state")
+			transitions = append(transitions, fmt.Sprintf("%d:%s>%s", i, previous, state))
+		}
+	}
+	return transitions
+}
+
+func TestThresholdHysteresis(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeThreshold, `{
+		"rules": [{"name": "high_cpu", "field": "value", "warn": {"above": 80}, "crit": {"above": 90}, "hysteresis": 5}]
+	}`).(*thresholdProcessor)
+	transitions := thresholdAlerts(t, proc, time.Unix(1000, 0), 50, 85, 88, 95, 87, 84, 76, 74, 74)
+	expected := []string{"1:OK>WARN", "3:WARN>CRIT", "5:CRIT>WARN", "7:WARN>OK"}
+	if fmt.Sprint(transitions) != fmt.Sprint(expected) {
+		t.Errorf("expected %v but got %v", expected, transitions)
+	}
+
+	mt := newTestMetric("cpu", 95.0)
+	mt.Tags = append(mt.Tags, metric.Tag{Key: "cpu", Value: "cpu0"})
+	out, err := proc.process(mt, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Name != "cpu" {
+		t.Fatalf("expected the original metric and an alert but got %v", out)
+	}
+	alert := findMetric(out, "alert",
+		metric.Tag{Key: "host", Value: "web01"}, metric.Tag{Key: "cpu", Value: "cpu0"},
+		metric.Tag{Key: "alertname", Value: "high_cpu"}, metric.Tag{Key: "metric", Value: "cpu"})
+	if alert == nil {
+		t.Fatalf("series should be tracked separately: %v", out)
+	}
+	if value, _ := alert.GetField("value"); value != 95.0 {
+		t.Errorf("invalid alert value: %v", value)
+	}
+}
+
+func TestThresholdForAndRenotify(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeThreshold, `{
+		"rules": [{"name": "range", "field": "value", "crit": {"below": 10, "above": 20}, "for": "3s"}],
+		"renotify": "4s",
+		"dropOriginal": true
+	}`).(*thresholdProcessor)
+	transitions := thresholdAlerts(t, proc, time.Unix(1000, 0), 25, 25, 15, 5, 5, 5, 5, 5, 5, 5, 5, 15)
+	expected := []string{"6:OK>CRIT", "10:CRIT>CRIT", "11:CRIT>OK"}
+	if fmt.Sprint(transitions) != fmt.Sprint(expected) {
+		t.Errorf("expected %v but got %v", expected, transitions)
+	}
+}
+
+func TestThresholdReceiveTime(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeThreshold, `{
+		"rules": [{"name": "high", "field": "value", "crit": {"above": 1}, "for": "2s"}],
+		"renotify": "3s",
+		"dropOriginal": true
+	}`).(*thresholdProcessor)
+	transitions := []string{}
+	for i := 0; i < 6; i++ {
+		mt := metric.Metric{Name: "cpu", Fields: []metric.Field{{Key: "value", Value: 2}}}
+		out, err := proc.process(mt, time.Unix(int64(1000+i), 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, alert := range out {
+			state, _ := alert.GetField("state")
+			transitions = append(transitions, fmt.Sprintf("%d:%s", i, state))
+		}
+	}
+	if fmt.Sprint(transitions) != "[2:CRIT 5:CRIT]" {
+		t.Errorf("metrics without a time should be timed by the receive time: %v", transitions)
+	}
+}
+
+func TestThresholdConditionAndExpire(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeThreshold, `{
+		"rules": [{"name": "web", "field": "value", "condition": "tags.host =~ \"^web\"", "warn": {"above": 1}}],
+		"dropOriginal": true
+	}`).(*thresholdProcessor)
+	mt := newTestMetric("cpu", 2)
+	mt.Tags = []metric.Tag{{Key: "host", Value: "db01"}}
+	out, err := proc.process(mt, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("rule should not apply: %v", out)
+	}
+
+	if transitions := thresholdAlerts(t, proc, time.Unix(1000, 0), 2, 2); len(transitions) != 1 {
+		t.Errorf("expected a single alert but got %v", transitions)
+	}
+	proc.expire(time.Unix(0, 0).Add(10*time.Minute + time.Second))
+	if transitions := thresholdAlerts(t, proc, time.Unix(2000, 0), 2); len(transitions) != 1 {
+		t.Errorf("expired series should start as OK again: %v", transitions)
+	}
+}
+
+func TestThresholdExpireOnReceive(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeThreshold, `{
+		"rules": [{"name": "cpu", "field": "value", "warn": {"above": 1}}],
+		"ttl": "1m"
+	}`).(*thresholdProcessor)
+	now := time.Now()
+	mt := newTestMetric("cpu", 2)
+	if _, err := proc.process(mt, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// idle series are evicted without cron triggers
+	mt.Tags = []metric.Tag{{Key: "host", Value: "web02"}}
+	if _, err := proc.process(mt, now.Add(90*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(proc.series[0]) != 1 {
+		t.Errorf("idle series should be evicted on receiving: %v", proc.series[0])
+	}
+}
+
+func TestThresholdConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{}`,
+		`{"rules": [{"field": "value", "warn": {"above": 1}}]}`,
+		`{"rules": [{"name": "a", "warn": {"above": 1}}]}`,
+		`{"rules": [{"name": "a", "field": "value"}]}`,
+		`{"rules": [{"name": "a", "field": "value", "warn": {}}]}`,
+		`{"rules": [{"name": "a", "field": "value", "warn": {"above": 1, "below": 2}}]}`,
+		`{"rules": [{"name": "a", "field": "value", "warn": {"above": 1}, "hysteresis": -1}]}`,
+		`{"rules": [{"name": "a", "field": "value", "warn": {"above": 1}, "condition": "name"}]}`,
+		`{"rules": [{"name": "a", "field": "value", "warn": {"above": 1}}, {"name": "a", "field": "value", "crit": {"above": 1}}]}`,
+		`{"rules": [{"name": "a", "field": "value", "warn": {"above": 1}}], "metricName": ""}`,
+		`{"rules": [{"name": "a", "field": "value", "warn": {"above": 1}}], "ttl": "0s"}`,
+	} {
+		cfg := NewThresholdConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package processors
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeTopK = "topk"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeTopK, NewTopKProcessor)
+}
+
+// topkGroup accumulates the field values of a group
+type topkGroup struct {
+	key      string
+	tags     []metric.Tag
+	sum      float64
+	count    int64
+	max      float64
+	last     float64
+	lastTime time.Time
+}
+
+func (group *topkGroup) add(value float64, t time.Time) {
+	if group.count == 0 || value > group.max {
+		group.max = value
+	}
+	if group.count == 0 || !t.Before(group.lastTime) {
+		group.last, group.lastTime = value, t
+	}
+	group.sum += value
+	group.count++
+}
+
+func (group *topkGroup) merge(other *topkGroup) {
+	if other.count == 0 {
+		return
+	}
+	if group.count == 0 || other.max > group.max {
+		group.max = other.max
+	}
+	if group.count == 0 || !other.lastTime.Before(group.lastTime) {
+		group.last, group.lastTime = other.last, other.lastTime
+	}
+	group.sum += other.sum
+	group.count += other.count
+}
+
+func (group *topkGroup) value(aggregation string) float64 {
+	switch aggregation {
+	case topkAggregationSum:
+		return group.sum
+	case topkAggregationMax:
+		return group.max
+	case topkAggregationLast:
+		return group.last
+	}
+	return group.sum / float64(group.count)
+}
+
+type topkProcessor struct {
+	cfg    *config.ProcessorConfig
+	params *TopKConfig
+	// groups maps the metric names to the group keys to the groups
+	groups map[string]map[string]*topkGroup
+}
+
+var _ processor.Processor = (*topkProcessor)(nil)
+
+// NewTopKProcessor creates a new topk processor
+func NewTopKProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*TopKConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for topk processor: %T", cfg.Params)
+	}
+
+	return &topkProcessor{
+		cfg:    cfg,
+		params: params,
+		groups: make(map[string]map[string]*topkGroup),
+	}, nil
+}
+
+func (proc *topkProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *topkProcessor) Setup(emitter processor.Emitter) error {
+	return nil
+}
+
+func (proc *topkProcessor) Close() error {
+	return nil
+}
+
+func (proc *topkProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	proc.add(mt)
+	if proc.params.DropOriginal {
+		return []metric.Metric{}, nil
+	}
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *topkProcessor) add(mt metric.Metric) {
+	field, err := mt.GetField(proc.params.Field)
+	if err != nil {
+		return
+	}
+	value, ok := numericValue(field)
+	if !ok || math.IsNaN(value) {
+		return
+	}
+
+	tags := mt.Tags
+	if len(proc.params.GroupBy) > 0 {
+		tags = make([]metric.Tag, 0, len(proc.params.GroupBy))
+		for _, key := range proc.params.GroupBy {
+			if tag, err := mt.GetTag(key); err == nil {
+				tags = append(tags, metric.Tag{Key: key, Value: tag})
+			}
+		}
+	}
+	key := (&metric.Metric{Tags: tags}).SeriesKey()
+
+	groups, ok := proc.groups[mt.Name]
+	if !ok {
+		groups = make(map[string]*topkGroup)
+		proc.groups[mt.Name] = groups
+	}
+	group, ok := groups[key]
+	if !ok {
+		copied := make([]metric.Tag, len(tags))
+		copy(copied, tags)
+		group = &topkGroup{key: key, tags: copied}
+		groups[key] = group
+	}
+	group.add(value, mt.Time)
+}
+
+func (proc *topkProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return proc.flush(time.Now()), nil
+}
+
+// flush emits the ranked groups of every name ordered by name and rank, then resets the groups
+// Groups of equal values are ranked by their tags
+func (proc *topkProcessor) flush(now time.Time) []metric.Metric {
+	params := proc.params
+	var out []metric.Metric
+	for _, name := range sortedKeys(proc.groups) {
+		groups := make([]*topkGroup, 0, len(proc.groups[name]))
+		for _, group := range proc.groups[name] {
+			groups = append(groups, group)
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			a, b := groups[i].value(params.Aggregation), groups[j].value(params.Aggregation)
+			if a != b {
+				return (a > b) != params.Bottom
+			}
+			return groups[i].key < groups[j].key
+		})
+
+		for i, group := range groups {
+			if i == params.K {
+				break
+			}
+			out = append(out, proc.groupMetric(name, group, group.tags, strconv.Itoa(i+1), now))
+		}
+
+		if params.Other && len(groups) > params.K {
+			other := &topkGroup{}
+			for _, group := range groups[params.K:] {
+				other.merge(group)
+			}
+			tags := make([]metric.Tag, len(params.GroupBy))
+			for i, key := range params.GroupBy {
+				tags[i] = metric.Tag{Key: key, Value: topkOther}
+			}
+			if len(tags) == 0 {
+				tags = append(tags, metric.Tag{Key: topkOtherTag, Value: topkOther})
+			}
+			out = append(out, proc.groupMetric(name, other, tags, topkOther, now))
+		}
+	}
+
+	proc.groups = make(map[string]map[string]*topkGroup)
+	return out
+}
+
+func (proc *topkProcessor) groupMetric(name string, group *topkGroup, tags []metric.Tag, rank string, now time.Time) metric.Metric {
+	mt := metric.Metric{
+		Name:   name,
+		Tags:   make([]metric.Tag, len(tags), len(tags)+1),
+		Fields: []metric.Field{{Key: proc.params.Field, Value: group.value(proc.params.Aggregation)}},
+		Time:   now,
+	}
+	copy(mt.Tags, tags)
+	if proc.params.RankTag != "" {
+		mt.Tags = setTag(mt.Tags, proc.params.RankTag, rank)
+	}
+	return mt
+}
@@ -0,0 +1,80 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/expinc/melegraf/config"
+)
+
+// Aggregations of the topk processor
+const (
+	topkAggregationSum  = "sum"
+	topkAggregationMean = "mean"
+	topkAggregationMax  = "max"
+	topkAggregationLast = "last"
+)
+
+// topkOther is the value of the group and the rank tags of the other bucket
+const topkOther = "other"
+
+// topkOtherTag tags the other bucket without groupBy, so that it is not taken for an untagged series
+const topkOtherTag = "topk"
+
+// TopKConfig ranks groups of metrics of the same name on a field between cron triggers
+// On every trigger a metric is emitted for each of the top or bottom K groups of every name,
+// with the group tags and the aggregated field, then the groups are reset
+type TopKConfig struct {
+	// Field is the key of the ranked field, metrics without it are not ranked
+	Field string `json:"field"`
+	// GroupBy are the tag keys grouping the metrics, every series is a group if empty
+	GroupBy []string `json:"groupBy"`
+	// Aggregation of the field values of a group, one of sum, mean, max and last
+	Aggregation string `json:"aggregation"`
+	// K is the number of emitted groups of every name
+	K int `json:"k"`
+	// Bottom emits the groups of the lowest values instead of the highest
+	Bottom bool `json:"bottom"`
+	// RankTag is the key of a tag added with the rank from 1, no tag is added if empty
+	RankTag string `json:"rankTag"`
+	// Other emits the aggregation of the other groups with the value "other" for the group and the rank tags,
+	// and with the tag topk=other if GroupBy is empty
+	Other bool `json:"other"`
+	// DropOriginal drops the received metrics instead of passing them through
+	DropOriginal bool `json:"dropOriginal"`
+}
+
+var _ config.CustomConfig = (*TopKConfig)(nil)
+
+func NewTopKConfig() config.CustomConfig {
+	return &TopKConfig{
+		Aggregation: topkAggregationMean,
+		K:           10,
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeTopK, NewTopKConfig)
+}
+
+func (cfg *TopKConfig) Validate() error {
+	if cfg.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+
+	switch cfg.Aggregation {
+	case topkAggregationSum, topkAggregationMean, topkAggregationMax, topkAggregationLast:
+	default:
+		return fmt.Errorf("invalid aggregation: %s", cfg.Aggregation)
+	}
+
+	if cfg.K <= 0 {
+		return fmt.Errorf("invalid k: %d", cfg.K)
+	}
+	return nil
+}
+
+func (cfg *TopKConfig) UnmarshalJSON(data []byte) error {
+	type plain TopKConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
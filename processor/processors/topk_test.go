@@ -0,0 +1,126 @@
+package processors
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func newTopKMetric(process, pid string, seconds int, cpu float64) metric.Metric {
+	return metric.Metric{
+		Name:   "procstat",
+		Tags:   []metric.Tag{{Key: "process", Value: process}, {Key: "pid", Value: pid}},
+		Fields: []metric.Field{{Key: "cpu", Value: cpu}},
+		Time:   time.Unix(int64(seconds), 0),
+	}
+}
+
+func feedTopK(t *testing.T, proc *topkProcessor) {
+	for _, mt := range []metric.Metric{
+		newTopKMetric("nginx", "1", 0, 10),
+		newTopKMetric("nginx", "2", 1, 30),
+		newTopKMetric("java", "3", 0, 50),
+		newTopKMetric("java", "3", 2, 10),
+		newTopKMetric("sshd", "4", 0, 1),
+		newTopKMetric("cron", "5", 0, 2),
+		{Name: "procstat", Tags: []metric.Tag{{Key: "process", Value: "init"}}, Fields: []metric.Field{{Key: "memory", Value: 100.0}}},
+	} {
+		out, err := proc.OnReceive(mt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 {
+			t.Fatal("metrics should be passed through")
+		}
+	}
+}
+
+func TestTopKGroups(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeTopK, `{
+		"field": "cpu",
+		"groupBy": ["process"],
+		"aggregation": "sum",
+		"k": 2,
+		"rankTag": "rank",
+		"other": true
+	}`).(*topkProcessor)
+	feedTopK(t, proc)
+
+	now := time.Unix(100, 0)
+	out := proc.flush(now)
+	expected := []metric.Metric{
+		{Name: "procstat", Tags: []metric.Tag{{Key: "process", Value: "java"}, {Key: "rank", Value: "1"}}, Fields: []metric.Field{{Key: "cpu", Value: 60.0}}, Time: now},
+		{Name: "procstat", Tags: []metric.Tag{{Key: "process", Value: "nginx"}, {Key: "rank", Value: "2"}}, Fields: []metric.Field{{Key: "cpu", Value: 40.0}}, Time: now},
+		{Name: "procstat", Tags: []metric.Tag{{Key: "process", Value: "other"}, {Key: "rank", Value: "other"}}, Fields: []metric.Field{{Key: "cpu", Value: 3.0}}, Time: now},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %v but got %v", expected, out)
+	}
+
+	if out := proc.flush(now); len(out) != 0 {
+		t.Errorf("groups should be reset after flush: %v", out)
+	}
+}
+
+func TestTopKAggregations(t *testing.T) {
+	cases := []struct {
+		params string
+		values map[string]float64
+	}{
+		{`{"field": "cpu", "groupBy": ["process"], "k": 2}`, map[string]float64{"java": 30, "nginx": 20}},
+		{`{"field": "cpu", "groupBy": ["process"], "aggregation": "max", "k": 1}`, map[string]float64{"java": 50}},
+		{`{"field": "cpu", "groupBy": ["process"], "aggregation": "last", "k": 1}`, map[string]float64{"nginx": 30}},
+		{`{"field": "cpu", "groupBy": ["process"], "k": 2, "bottom": true}`, map[string]float64{"sshd": 1, "cron": 2}},
+	}
+	for _, c := range cases {
+		proc := newTestProcessor(t, ProcessorTypeTopK, c.params).(*topkProcessor)
+		feedTopK(t, proc)
+		out := proc.flush(time.Unix(0, 0))
+		if len(out) != len(c.values) {
+			t.Errorf("%s: expected %v but got %v", c.params, c.values, out)
+			continue
+		}
+		for _, mt := range out {
+			process, _ := mt.GetTag("process")
+			if value, ok := c.values[process]; !ok || mt.Fields[0].Value != value {
+				t.Errorf("%s: unexpected %v", c.params, mt)
+			}
+		}
+	}
+
+	// every series is a group without groupBy
+	proc := newTestProcessor(t, ProcessorTypeTopK, `{"field": "cpu", "k": 1, "dropOriginal": true, "other": true}`).(*topkProcessor)
+	out, err := proc.OnReceive(newTopKMetric("nginx", "1", 0, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || len(out) != 0 {
+		t.Fatalf("metrics should be dropped: %v", out)
+	}
+	proc.OnReceive(newTopKMetric("nginx", "2", 0, 20))
+	out = proc.flush(time.Unix(0, 0))
+	other := []metric.Tag{{Key: topkOtherTag, Value: topkOther}}
+	if len(out) != 2 || len(out[0].Tags) != 2 || !reflect.DeepEqual(out[1].Tags, other) || out[1].Fields[0].Value != 10.0 {
+		t.Errorf("expected the top series and the other bucket but got %v", out)
+	}
+}
+
+func TestTopKConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{}`,
+		`{"field": "cpu", "aggregation": "median"}`,
+		`{"field": "cpu", "k": 0}`,
+	} {
+		cfg := NewTopKConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
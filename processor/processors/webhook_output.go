@@ -0,0 +1,323 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/metric"
+	"github.com/expinc/melegraf/processor"
+)
+
+const (
+	ProcessorTypeWebhookOutput = "webhook_output"
+)
+
+func init() {
+	processor.RegisterProcessorConstructor(ProcessorTypeWebhookOutput, NewWebhookOutputProcessor)
+}
+
+// webhookDefaultText is the message text of a metric with a preset and no template
+const webhookDefaultText = `{{.Name}}{{range $k, $v := tags .}} {{$k}}={{$v}}{{end}}{{range $k, $v := fields .}} {{$k}}={{$v}}{{end}}`
+
+var webhookTemplateFuncs = template.FuncMap{
+	"tag": func(mt metric.Metric, key string) string {
+		value, _ := mt.GetTag(key)
+		return value
+	},
+	"field": func(mt metric.Metric, key string) interface{} {
+		value, _ := mt.GetField(key)
+		return value
+	},
+	"tags": func(mt metric.Metric) map[string]string {
+		tags := make(map[string]string, len(mt.Tags))
+		for _, tag := range mt.Tags {
+			tags[tag.Key] = tag.Value
+		}
+		return tags
+	},
+	"fields": func(mt metric.Metric) map[string]interface{} {
+		fields := make(map[string]interface{}, len(mt.Fields))
+		for _, field := range mt.Fields {
+			fields[field.Key] = field.Value
+		}
+		return fields
+	},
+	"formatTime": func(t time.Time, layout string) string {
+		return t.Format(layout)
+	},
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// webhookSeries is the last notification of a series
+type webhookSeries struct {
+	fields   []metric.Field
+	notified time.Time
+}
+
+type webhookOutputProcessor struct {
+	cfg     *config.ProcessorConfig
+	params  *WebhookOutputConfig
+	client  *http.Client
+	batcher *batcher
+	// series are the last sent notifications of the series
+	series map[string]*webhookSeries
+	// queued are the last notifications of the series waiting in the buffer of the batcher
+	queued map[string]*webhookSeries
+	// expired is the time of the last expiry, which also runs on receiving at most once per dedup duration or rate limit
+	expired time.Time
+}
+
+var _ processor.BatchProcessor = (*webhookOutputProcessor)(nil)
+
+// NewWebhookOutputProcessor creates a new webhook output processor
+func NewWebhookOutputProcessor(cfg *config.ProcessorConfig) (processor.Processor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	params, ok := cfg.Params.(*WebhookOutputConfig)
+	if !ok {
+		return nil, fmt.Errorf("invalid params type for webhook output processor: %T", cfg.Params)
+	}
+
+	proc := &webhookOutputProcessor{
+		cfg:    cfg,
+		params: params,
+		series: make(map[string]*webhookSeries),
+		queued: make(map[string]*webhookSeries),
+	}
+	size := 1
+	if params.Batch {
+		size, _ = processor.BatchLimits(cfg)
+	}
+	proc.batcher = newBatcher(cfg.Name, &params.BatchingConfig, size, proc.deliver)
+	return proc, nil
+}
+
+func (proc *webhookOutputProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *webhookOutputProcessor) Setup(emitter processor.Emitter) error {
+	proc.batcher.setup(emitter)
+	proc.client = &http.Client{Timeout: time.Duration(proc.params.Timeout)}
+	return nil
+}
+
+func (proc *webhookOutputProcessor) Close() error {
+	err := proc.batcher.flush()
+	proc.client.CloseIdleConnections()
+	return err
+}
+
+func (proc *webhookOutputProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return proc.OnReceiveBatch([]metric.Metric{mt})
+}
+
+func (proc *webhookOutputProcessor) OnReceiveBatch(metrics []metric.Metric) ([]metric.Metric, error) {
+	now := time.Now()
+	notifications := make([]metric.Metric, 0, len(metrics))
+	for _, mt := range metrics {
+		if proc.notify(mt, now) {
+			proc.queue(mt, now)
+			notifications = append(notifications, mt)
+		}
+	}
+	return nil, proc.batcher.add(notifications)
+}
+
+// notify tells whether a notification of the metric is to be sent
+// Series are suppressed within the rate limit of their last notifications, sent or queued,
+// and unchanged series are also suppressed within the dedup duration
+func (proc *webhookOutputProcessor) notify(mt metric.Metric, now time.Time) bool {
+	dedup, rateLimit := time.Duration(proc.params.Dedup), time.Duration(proc.params.RateLimit)
+	if dedup == 0 && rateLimit == 0 {
+		return true
+	}
+
+	if now.Sub(proc.expired) >= proc.ttl() {
+		proc.expire(now)
+	}
+
+	key := mt.SeriesKey()
+	fields := proc.dedupFields(mt)
+	for _, series := range []*webhookSeries{proc.series[key], proc.queued[key]} {
+		if series == nil {
+			continue
+		}
+		elapsed := now.Sub(series.notified)
+		if elapsed < rateLimit {
+			return false
+		}
+		if elapsed < dedup && reflect.DeepEqual(series.fields, fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// queue records the notification of the metric added to the buffer of the batcher
+func (proc *webhookOutputProcessor) queue(mt metric.Metric, now time.Time) {
+	if proc.params.Dedup == 0 && proc.params.RateLimit == 0 {
+		return
+	}
+	proc.queued[mt.SeriesKey()] = &webhookSeries{fields: proc.dedupFields(mt), notified: now}
+}
+
+// record records the sent notifications of the metrics
+func (proc *webhookOutputProcessor) record(batch []metric.Metric, now time.Time) {
+	if proc.params.Dedup == 0 && proc.params.RateLimit == 0 {
+		return
+	}
+	for _, mt := range batch {
+		proc.series[mt.SeriesKey()] = &webhookSeries{fields: proc.dedupFields(mt), notified: now}
+	}
+	proc.unqueue(batch)
+}
+
+// unqueue removes the queued notifications of the metrics which left the buffer,
+// unless later notifications of their series are queued
+func (proc *webhookOutputProcessor) unqueue(batch []metric.Metric) {
+	for _, mt := range batch {
+		key := mt.SeriesKey()
+		if series, ok := proc.queued[key]; ok && reflect.DeepEqual(series.fields, proc.dedupFields(mt)) {
+			delete(proc.queued, key)
+		}
+	}
+}
+
+// dedupFields returns a copy of the fields compared for deduplication
+func (proc *webhookOutputProcessor) dedupFields(mt metric.Metric) []metric.Field {
+	if len(proc.params.DedupFields) == 0 {
+		fields := make([]metric.Field, len(mt.Fields))
+		copy(fields, mt.Fields)
+		return fields
+	}
+
+	fields := make([]metric.Field, 0, len(proc.params.DedupFields))
+	for _, key := range proc.params.DedupFields {
+		if value, err := mt.GetField(key); err == nil {
+			fields = append(fields, metric.Field{Key: key, Value: value})
+		}
+	}
+	return fields
+}
+
+func (proc *webhookOutputProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	proc.expire(time.Now())
+	return nil, proc.batcher.flush()
+}
+
+// ttl returns the duration within which the last notifications of the series affect the next ones
+func (proc *webhookOutputProcessor) ttl() time.Duration {
+	ttl := time.Duration(proc.params.Dedup)
+	if time.Duration(proc.params.RateLimit) > ttl {
+		ttl = time.Duration(proc.params.RateLimit)
+	}
+	return ttl
+}
+
+// expire removes the series whose last notifications affect neither deduplication nor rate limiting
+func (proc *webhookOutputProcessor) expire(now time.Time) {
+	proc.expired = now
+	ttl := proc.ttl()
+	for _, notifications := range []map[string]*webhookSeries{proc.series, proc.queued} {
+		for key, series := range notifications {
+			if now.Sub(series.notified) >= ttl {
+				delete(notifications, key)
+			}
+		}
+	}
+}
+
+// render executes the template and wraps the text in the message of the preset
+func (proc *webhookOutputProcessor) render(batch []metric.Metric) ([]byte, error) {
+	var data interface{} = batch[0]
+	if proc.params.Batch {
+		data = batch
+	}
+
+	var buf bytes.Buffer
+	err := proc.params.template.Execute(&buf, data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch proc.params.Preset {
+	case webhookPresetSlack:
+		return json.Marshal(map[string]string{"text": buf.String()})
+	case webhookPresetTeams:
+		text := buf.String()
+		summary := strings.SplitN(text, "\n", 2)[0]
+		return json.Marshal(map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  summary,
+			"text":     text,
+		})
+	}
+	return buf.Bytes(), nil
+}
+
+// deliver sends the notifications and records them once sent
+// The notifications dropped on a non-retriable error are not recorded
+func (proc *webhookOutputProcessor) deliver(batch []metric.Metric) error {
+	err := proc.send(batch)
+	if err == nil {
+		proc.record(batch, time.Now())
+		return nil
+	}
+
+	var sendErr *sendError
+	if errors.As(err, &sendErr) && !sendErr.retriable {
+		proc.unqueue(batch)
+	}
+	return err
+}
+
+func (proc *webhookOutputProcessor) send(batch []metric.Metric) error {
+	data, err := proc.render(batch)
+	if err != nil {
+		return &sendError{err: fmt.Errorf("failed to render template: %w", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(proc.params.Timeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, proc.params.Method, proc.params.URL, bytes.NewReader(data))
+	if err != nil {
+		return &sendError{err: err}
+	}
+	if proc.params.Preset != "" {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", proc.params.ContentType)
+	}
+	for key, value := range proc.params.Headers {
+		if http.CanonicalHeaderKey(key) == "Host" {
+			req.Host = value
+		} else {
+			req.Header.Set(key, value)
+		}
+	}
+
+	resp, err := proc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkHTTPResponse(resp)
+}
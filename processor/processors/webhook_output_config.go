@@ -0,0 +1,115 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+)
+
+const (
+	webhookPresetSlack = "slack"
+	webhookPresetTeams = "teams"
+)
+
+// WebhookOutputConfig sends notifications rendered from metrics by templates to a webhook
+type WebhookOutputConfig struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	// Preset sends the rendered text in the JSON message of a chat service, either "slack" or "teams", optional
+	Preset string `json:"preset"`
+	// Template is a Go text/template rendering the request body, or the message text with a preset
+	// It is executed with a metric, or with the slice of metrics of a batch if Batch is set
+	// Besides the builtins, it may call tag, field, tags and fields of a metric,
+	// formatTime of a time with a layout, and json which encodes a value as JSON
+	// e.g. {"text": {{json (printf "%s is %v on %s" .Name (field . "state") (tag . "host"))}}}
+	// It is required without a preset, and a preset lists the name, tags and fields by default
+	Template string `json:"template"`
+	// ContentType of the requests without a preset
+	ContentType string `json:"contentType"`
+	// Batch sends a request per batch received from the runner instead of per metric
+	Batch bool `json:"batch"`
+	// Dedup suppresses the notifications of a series whose DedupFields are unchanged within the duration, disabled if zero
+	Dedup config.Duration `json:"dedup"`
+	// DedupFields are the keys of the fields compared for deduplication, all fields if empty
+	DedupFields []string `json:"dedupFields"`
+	// RateLimit is the minimum interval between the notifications of a series, even if its DedupFields changed,
+	// disabled if zero
+	// Notifications waiting to be sent count for both Dedup and RateLimit,
+	// while notifications dropped on non-retriable errors do not
+	RateLimit config.Duration `json:"rateLimit"`
+	// BatchingConfig contains timeout, bufferLimit, overflowPolicy and retry options
+	BatchingConfig
+
+	template *template.Template
+}
+
+var _ config.CustomConfig = (*WebhookOutputConfig)(nil)
+
+func NewWebhookOutputConfig() config.CustomConfig {
+	return &WebhookOutputConfig{
+		Method:         http.MethodPost,
+		ContentType:    "application/json",
+		BatchingConfig: defaultBatchingConfig(),
+	}
+}
+
+func init() {
+	config.RegisterCustomConfigConstructor(ProcessorTypeWebhookOutput, NewWebhookOutputConfig)
+}
+
+func (cfg *WebhookOutputConfig) Validate() error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url: %s", cfg.URL)
+	}
+
+	switch cfg.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("invalid method: %s", cfg.Method)
+	}
+
+	text := cfg.Template
+	switch cfg.Preset {
+	case "":
+		if text == "" {
+			return fmt.Errorf("template is required without a preset")
+		}
+	case webhookPresetSlack, webhookPresetTeams:
+		if text == "" {
+			text = webhookDefaultText
+			if cfg.Batch {
+				text = "{{range .}}" + webhookDefaultText + "\n{{end}}"
+			}
+		}
+	default:
+		return fmt.Errorf("invalid preset: %s", cfg.Preset)
+	}
+	cfg.template, err = template.New("webhook").Funcs(webhookTemplateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+
+	if cfg.Dedup < 0 {
+		return fmt.Errorf("invalid dedup duration: %v", time.Duration(cfg.Dedup))
+	}
+	if cfg.RateLimit < 0 {
+		return fmt.Errorf("invalid rate limit: %v", time.Duration(cfg.RateLimit))
+	}
+
+	return cfg.BatchingConfig.Validate()
+}
+
+func (cfg *WebhookOutputConfig) UnmarshalJSON(data []byte) error {
+	type plain WebhookOutputConfig
+	return json.Unmarshal(data, (*plain)(cfg))
+}
@@ -0,0 +1,225 @@
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func newAlertMetric(state string, value float64) metric.Metric {
+	return metric.Metric{
+		Name:   "alert",
+		Tags:   []metric.Tag{{Key: "host", Value: "web01"}, {Key: "alertname", Value: "high_cpu"}},
+		Fields: []metric.Field{{Key: "state", Value: state}, {Key: "value", Value: value}},
+		Time:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestWebhookOutputTemplate(t *testing.T) {
+	collector := &fakeCollector{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	proc := newTestProcessor(t, ProcessorTypeWebhookOutput, fmt.Sprintf(`{
+		"url": "%s/hook",
+		"headers": {"Authorization": "Bearer secret"},
+		"template": "{\"summary\": {{json (printf \"%%s on %%s\" (tag . \"alertname\") (tag . \"host\"))}}, \"state\": {{json (field . \"state\")}}, \"at\": \"{{formatTime .Time \"15:04:05\"}}\"}",
+		"retryInitialInterval": "1ms",
+		"retryMaxInterval": "1ms"
+	}`, server.URL)).(*webhookOutputProcessor)
+	defer proc.Close()
+
+	_, err := proc.OnReceive(newAlertMetric("CRIT", 95))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collector.bodies) != 2 {
+		t.Fatalf("the notification should be sent after a retry: %v", collector.bodies)
+	}
+	expected := `{"summary": "high_cpu on web01", "state": "CRIT", "at": "03:04:05"}`
+	if collector.bodies[1] != expected {
+		t.Errorf("expected body %s but got %s", expected, collector.bodies[1])
+	}
+	if collector.headers[1].Get("Authorization") != "Bearer secret" || collector.headers[1].Get("Content-Type") != "application/json" {
+		t.Errorf("invalid headers: %v", collector.headers[1])
+	}
+}
+
+func TestWebhookOutputPresets(t *testing.T) {
+	collector := &fakeCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	proc := newTestProcessor(t, ProcessorTypeWebhookOutput, fmt.Sprintf(`{"url": "%s", "preset": "slack", "batch": true}`, server.URL)).(*webhookOutputProcessor)
+	_, err := proc.OnReceiveBatch([]metric.Metric{newAlertMetric("WARN", 85), newAlertMetric("OK", 85)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.Close()
+
+	proc = newTestProcessor(t, ProcessorTypeWebhookOutput, fmt.Sprintf(`{
+		"url": "%s",
+		"preset": "teams",
+		"template": "{{tag . \"alertname\"}} is {{field . \"state\"}}\nvalue {{field . \"value\"}}"
+	}`, server.URL)).(*webhookOutputProcessor)
+	_, err = proc.OnReceive(newAlertMetric("CRIT", 95.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.Close()
+
+	expected := []string{
+		`{"text":"alert alertname=high_cpu host=web01 state=WARN value=85\nalert alertname=high_cpu host=web01 state=OK value=85\n"}`,
+		`{"@context":"https://schema.org/extensions","@type":"MessageCard","summary":"high_cpu is CRIT","text":"high_cpu is CRIT\nvalue 95.5"}`,
+	}
+	if fmt.Sprint(collector.bodies) != fmt.Sprint(expected) {
+		t.Errorf("expected bodies %v but got %v", expected, collector.bodies)
+	}
+}
+
+func TestWebhookOutputDedupAndRateLimit(t *testing.T) {
+	proc := newTestProcessor(t, ProcessorTypeWebhookOutput, `{
+		"url": "http://localhost/hook",
+		"template": "{{.Name}}",
+		"dedup": "10m",
+		"dedupFields": ["state"],
+		"rateLimit": "1m"
+	}`).(*webhookOutputProcessor)
+	start := time.Unix(1000, 0)
+	cases := []struct {
+		state  string
+		offset time.Duration
+		notify bool
+	}{
+		{"CRIT", 0, true},
+		// changes are limited too
+		{"OK", 30 * time.Second, false},
+		{"OK", time.Minute, true},
+		{"OK", 5 * time.Minute, false},
+		{"CRIT", 6 * time.Minute, true},
+		{"CRIT", 17 * time.Minute, true},
+		{"CRIT", 17*time.Minute + 30*time.Second, false},
+		{"OK", 17*time.Minute + 45*time.Second, false},
+	}
+	for i, c := range cases {
+		mt := newAlertMetric(c.state, float64(i))
+		notify := proc.notify(mt, start.Add(c.offset))
+		if notify != c.notify {
+			t.Errorf("case %d: expected notify %v but got %v", i, c.notify, notify)
+		}
+		if notify {
+			proc.record([]metric.Metric{mt}, start.Add(c.offset))
+		}
+	}
+
+	other := newAlertMetric("CRIT", 0)
+	other.Tags[0].Value = "web02"
+	if !proc.notify(other, start.Add(17*time.Minute)) {
+		t.Error("series should be limited separately")
+	}
+
+	proc.expire(start.Add(27 * time.Minute))
+	if len(proc.series) != 0 {
+		t.Errorf("series should be expired: %v", proc.series)
+	}
+
+	// idle series are also expired on receiving without cron triggers
+	proc.record([]metric.Metric{other}, start.Add(30*time.Minute))
+	if !proc.notify(newAlertMetric("CRIT", 0), start.Add(40*time.Minute)) || len(proc.series) != 0 {
+		t.Errorf("series should be expired on receiving: %v", proc.series)
+	}
+}
+
+func TestWebhookOutputFailedNotRecorded(t *testing.T) {
+	collector := &fakeCollector{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	proc := newTestProcessor(t, ProcessorTypeWebhookOutput, fmt.Sprintf(`{
+		"url": "%s/hook",
+		"preset": "slack",
+		"dedup": "10m"
+	}`, server.URL)).(*webhookOutputProcessor)
+	defer proc.Close()
+
+	if _, err := proc.OnReceive(newAlertMetric("CRIT", 95)); err == nil {
+		t.Error("the notification should fail")
+	}
+	if _, err := proc.OnReceive(newAlertMetric("CRIT", 95)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proc.OnReceive(newAlertMetric("CRIT", 95)); err != nil {
+		t.Fatal(err)
+	}
+	if len(collector.bodies) != 2 {
+		t.Errorf("a failed notification should not be deduplicated, got %d requests", len(collector.bodies))
+	}
+}
+
+func TestWebhookOutputDedupBatch(t *testing.T) {
+	collector := &fakeCollector{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	proc := newTestProcessor(t, ProcessorTypeWebhookOutput, fmt.Sprintf(`{
+		"url": "%s/hook",
+		"template": "{{len .}}",
+		"batch": true,
+		"dedup": "10m",
+		"dedupFields": ["state"],
+		"maxRetries": 0
+	}`, server.URL)).(*webhookOutputProcessor)
+
+	// the notification of the batch fails and stays buffered
+	batch := []metric.Metric{newAlertMetric("CRIT", 95), newAlertMetric("CRIT", 96), newAlertMetric("CRIT", 97)}
+	if _, err := proc.OnReceiveBatch(batch); err == nil {
+		t.Error("the notification should fail")
+	}
+	if fmt.Sprint(collector.bodies) != "[1]" {
+		t.Errorf("notifications of the same batch should be deduplicated, got %v", collector.bodies)
+	}
+	if _, err := proc.OnReceive(newAlertMetric("CRIT", 98)); err != nil {
+		t.Fatal(err)
+	}
+	if len(proc.batcher.buffer) != 1 {
+		t.Errorf("buffered notifications should be deduplicated, got %d", len(proc.batcher.buffer))
+	}
+
+	if _, err := proc.OnCronTrigger(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proc.OnReceive(newAlertMetric("CRIT", 99)); err != nil {
+		t.Fatal(err)
+	}
+	if len(collector.bodies) != 2 || len(proc.batcher.buffer) != 0 || len(proc.queued) != 0 {
+		t.Errorf("sent notifications should be deduplicated, got %d requests and %d queued", len(collector.bodies), len(proc.queued))
+	}
+}
+
+func TestWebhookOutputConfigInvalid(t *testing.T) {
+	for _, params := range []string{
+		`{"template": "{{.Name}}"}`,
+		`{"url": "ftp://localhost", "template": "{{.Name}}"}`,
+		`{"url": "http://localhost", "method": "GET", "template": "{{.Name}}"}`,
+		`{"url": "http://localhost"}`,
+		`{"url": "http://localhost", "preset": "discord"}`,
+		`{"url": "http://localhost", "template": "{{.Name"}`,
+		`{"url": "http://localhost", "template": "{{unknown .}}"}`,
+		`{"url": "http://localhost", "preset": "slack", "dedup": "-1s"}`,
+		`{"url": "http://localhost", "preset": "slack", "bufferLimit": 0}`,
+	} {
+		cfg := NewWebhookOutputConfig()
+		err := json.Unmarshal([]byte(params), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Validate() == nil {
+			t.Errorf("config should be invalid: %s", params)
+		}
+	}
+}
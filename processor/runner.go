@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/expinc/melegraf/config"
 	"github.com/expinc/melegraf/conveyor"
@@ -64,6 +65,10 @@ type processorRunner struct {
 	outputs   []*conveyor.Conveyor
 	isStarted bool
 	stopChan  chan struct{}
+	doneChan  chan struct{}
+	emitter   *runnerEmitter
+	// unknownRoutes are the names of the unknown output conveyors warned about once
+	unknownRoutes sync.Map
 }
 
 var _ ProcessorRunner = (*processorRunner)(nil)
@@ -79,8 +84,13 @@ func (runner *processorRunner) IsStarted() bool {
 }
 
 func (runner *processorRunner) send(metrics []metric.Metric) {
+	routes := runner.route(metrics)
 	for _, output := range runner.outputs {
-		for _, mt := range metrics {
+		for i, mt := range metrics {
+			if routes != nil && !routes[i].includes(output.Name()) {
+				continue
+			}
+
 			// Make a deep copy of the metric
 			// This is necessary because the metric may be modified by the following processors
 			mtCopy := mt.Copy()
@@ -93,14 +103,87 @@ func (runner *processorRunner) send(metrics []metric.Metric) {
 	}
 }
 
+// outputRoute is the output conveyors a metric is sent to, nil for all of them
+type outputRoute []string
+
+func (route outputRoute) includes(name string) bool {
+	if route == nil {
+		return true
+	}
+	for _, output := range route {
+		if output == name {
+			return true
+		}
+	}
+	return false
+}
+
+// route returns the output conveyors of each metric if the processor is a Router, or nil otherwise
+func (runner *processorRunner) route(metrics []metric.Metric) []outputRoute {
+	router, ok := runner.proc.(Router)
+	if !ok {
+		return nil
+	}
+
+	routes := make([]outputRoute, len(metrics))
+	for i, mt := range metrics {
+		outputs, err := router.Route(mt)
+		if err != nil {
+			logrus.Errorf("Processor \"%s\" failed to route metric \"%s\": %v", runner.Name(), mt.Name, err)
+			outputs = []string{}
+		}
+		runner.warnUnknownRoutes(outputs)
+		routes[i] = outputs
+	}
+	return routes
+}
+
+// warnUnknownRoutes warns once about each routed name which is not an output conveyor of the processor
+func (runner *processorRunner) warnUnknownRoutes(outputs []string) {
+	for _, name := range outputs {
+		known := false
+		for _, output := range runner.outputs {
+			if output.Name() == name {
+				known = true
+				break
+			}
+		}
+		if known {
+			continue
+		}
+		if _, warned := runner.unknownRoutes.LoadOrStore(name, true); !warned {
+			logrus.Warnf("Processor \"%s\" routes metrics to \"%s\" which is not an output conveyor of it", runner.Name(), name)
+		}
+	}
+}
+
+// drainInput appends the metrics already queued in an input conveyor to a batch without blocking
+func drainInput(input *conveyor.Conveyor, batch []metric.Metric, maxSize int) []metric.Metric {
+	ch := input.GetChannel()
+	for len(batch) < maxSize {
+		select {
+		case mt, ok := <-ch:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, mt)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
 func (runner *processorRunner) startInternal() error {
 	if runner.isStarted {
 		logrus.Infof("Processor \"%s\" already started. Do nothing", runner.Name())
 		return nil
 	}
 
-	err := runner.proc.Setup()
+	emitter := newRunnerEmitter(runner)
+	err := runner.proc.Setup(emitter)
 	if err != nil {
+		emitter.close()
 		return err
 	}
 
@@ -111,14 +194,44 @@ func (runner *processorRunner) startInternal() error {
 		crn.AddFunc(runner.proc.Config().CronSpec, func() { cronChan <- struct{}{} })
 	}
 
+	batchProc, isBatch := runner.proc.(BatchProcessor)
+	batchMaxSize, batchMaxWait := BatchLimits(runner.proc.Config())
+
+	runner.emitter = emitter
 	runner.stopChan = make(chan struct{})
+	runner.doneChan = make(chan struct{})
 	go func() {
-		// select from stopChan, cronChan and inputs
-		cases := make([]reflect.SelectCase, len(runner.inputs)+2)
+		defer close(runner.doneChan)
+
+		// select from stopChan, cronChan, the batch timer and inputs
+		// The batch timer is ignored while no batch is pending
+		cases := make([]reflect.SelectCase, len(runner.inputs)+3)
 		cases[0] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(runner.stopChan)}
 		cases[1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cronChan)}
+		cases[2] = reflect.SelectCase{Dir: reflect.SelectRecv}
 		for i, input := range runner.inputs {
-			cases[i+2] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(input.GetChannel())}
+			cases[i+3] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(input.GetChannel())}
+		}
+
+		var batch []metric.Metric
+		var batchTimer *time.Timer
+		flushBatch := func() {
+			if batchTimer != nil {
+				batchTimer.Stop()
+				batchTimer = nil
+				cases[2].Chan = reflect.Value{}
+			}
+			if len(batch) == 0 {
+				return
+			}
+
+			out, err2 := batchProc.OnReceiveBatch(batch)
+			if err2 != nil {
+				logrus.Errorf("Processor \"%s\" failed to process a batch of %d metrics: %v", runner.Name(), len(batch), err2)
+			} else {
+				runner.send(out)
+			}
+			batch = nil
 		}
 
 		if crn != nil {
@@ -134,31 +247,43 @@ func (runner *processorRunner) startInternal() error {
 				logrus.Infof("Processor \"%s\" is being stopped", runner.Name())
 				stopped = true
 
-				close(runner.stopChan)
-				runner.stopChan = nil
-
 				if crn != nil {
 					crn.Stop()
 				}
 
+				flushBatch()
 				err2 := runner.proc.Close()
 				if err2 != nil {
 					logrus.Errorf("Processor \"%s\" failed to close: %v", runner.Name(), err2)
 				}
-
-				runner.isStarted = false
+				emitter.close()
 			case 1:
+				flushBatch()
 				out, err2 := runner.proc.OnCronTrigger()
 				if err2 != nil {
 					logrus.Errorf("Processor \"%s\" failed to process cron trigger: %v", runner.Name(), err2)
 				} else {
 					runner.send(out)
 				}
+			case 2:
+				flushBatch()
 			default:
 				mt := value.Interface().(metric.Metric)
+				if isBatch {
+					batch = append(batch, mt)
+					batch = drainInput(runner.inputs[chosen-3], batch, batchMaxSize)
+					if len(batch) >= batchMaxSize {
+						flushBatch()
+					} else if batchTimer == nil {
+						batchTimer = time.NewTimer(batchMaxWait)
+						cases[2].Chan = reflect.ValueOf(batchTimer.C)
+					}
+					break
+				}
+
 				out, err2 := runner.proc.OnReceive(mt)
 				if err2 != nil {
-					logrus.Errorf("Processor \"%s\" failed to process metric from conveyor \"%s\": %v", runner.Name(), runner.inputs[chosen-2].Name(), err2)
+					logrus.Errorf("Processor \"%s\" failed to process metric from conveyor \"%s\": %v", runner.Name(), runner.inputs[chosen-3].Name(), err2)
 				} else {
 					runner.send(out)
 				}
@@ -185,7 +310,16 @@ func (runner *processorRunner) stopInternal() error {
 		logrus.Infof("Processor \"%s\" already stopped. Do nothing", runner.Name())
 		return nil
 	}
+	runner.emitter.stop()
 	runner.stopChan <- struct{}{}
+
+	// Wait until the processor is closed so that it can be safely reconfigured
+	<-runner.doneChan
+	close(runner.stopChan)
+	runner.stopChan = nil
+	runner.doneChan = nil
+	runner.emitter = nil
+	runner.isStarted = false
 	return nil
 }
 
@@ -0,0 +1,305 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/config"
+	"github.com/expinc/melegraf/conveyor"
+	"github.com/expinc/melegraf/metric"
+)
+
+type emittingProcessor struct {
+	cfg     *config.ProcessorConfig
+	emitter Emitter
+}
+
+func (proc *emittingProcessor) Config() *config.ProcessorConfig {
+	return proc.cfg
+}
+
+func (proc *emittingProcessor) Setup(emitter Emitter) error {
+	proc.emitter = emitter
+	return nil
+}
+
+func (proc *emittingProcessor) Close() error {
+	return nil
+}
+
+func (proc *emittingProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return nil, nil
+}
+
+func (proc *emittingProcessor) OnCronTrigger() ([]metric.Metric, error) {
+	return nil, nil
+}
+
+func TestProcessorRunnerEmit(t *testing.T) {
+	proc := &emittingProcessor{cfg: &config.ProcessorConfig{Name: "emitting_processor"}}
+	runner := &processorRunner{proc: proc}
+	output := conveyor.NewConveyor("output", 100)
+	err := runner.AddOutput(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = runner.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-proc.emitter.Stopping():
+		t.Error("stopping should not be signaled while the processor runs")
+	default:
+	}
+
+	// emit concurrently from several goroutines
+	goroutines := 4
+	perGoroutine := 10
+	errChan := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			for j := 0; j < perGoroutine; j++ {
+				err := proc.emitter.Emit(metric.Metric{Name: "emitted"})
+				if err != nil {
+					errChan <- err
+					return
+				}
+			}
+			errChan <- nil
+		}()
+	}
+	for i := 0; i < goroutines; i++ {
+		if err := <-errChan; err != nil {
+			t.Error(err)
+		}
+	}
+
+	err = runner.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-proc.emitter.Stopping():
+	default:
+		t.Error("stopping should be signaled once the processor is stopped")
+	}
+
+	// emits after the processor is closed must be rejected
+	err = proc.emitter.Emit(metric.Metric{Name: "late"})
+	if err != ErrEmitterClosed {
+		t.Errorf("emit after close should fail with ErrEmitterClosed, got %v", err)
+	}
+
+	cnt := 0
+	noMoreMetrics := false
+	for !noMoreMetrics {
+		select {
+		case mt := <-output.GetChannel():
+			if mt.Name != "emitted" {
+				t.Errorf("unexpected metric: %s", mt.Name)
+			}
+			cnt++
+		default:
+			noMoreMetrics = true
+		}
+	}
+	if cnt != goroutines*perGoroutine {
+		t.Errorf("invalid number of emitted metrics: %d", cnt)
+	}
+}
+
+type routingProcessor struct {
+	emittingProcessor
+}
+
+func (proc *routingProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return []metric.Metric{mt}, nil
+}
+
+func (proc *routingProcessor) Route(mt metric.Metric) ([]string, error) {
+	switch mt.Name {
+	case "error":
+		return []string{"alerting", "storage"}, nil
+	case "unrouted":
+		return []string{}, nil
+	case "unknown":
+		return []string{"missing"}, nil
+	case "failed":
+		return nil, errors.New("failed to route")
+	}
+	return nil, nil
+}
+
+func TestProcessorRunnerRoute(t *testing.T) {
+	proc := &routingProcessor{emittingProcessor{cfg: &config.ProcessorConfig{Name: "routing_processor"}}}
+	runner := &processorRunner{proc: proc}
+	alerting := conveyor.NewConveyor("alerting", 100)
+	storage := conveyor.NewConveyor("storage", 100)
+	for _, output := range []*conveyor.Conveyor{alerting, storage} {
+		err := runner.AddOutput(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner.send([]metric.Metric{{Name: "error"}, {Name: "unrouted"}, {Name: "cpu"}, {Name: "unknown"}, {Name: "failed"}})
+
+	received := func(output *conveyor.Conveyor) []string {
+		names := []string{}
+		for {
+			select {
+			case mt := <-output.GetChannel():
+				names = append(names, mt.Name)
+			default:
+				return names
+			}
+		}
+	}
+	if names := received(alerting); len(names) != 2 || names[0] != "error" || names[1] != "cpu" {
+		t.Errorf("invalid metrics sent to alerting: %v", names)
+	}
+	if names := received(storage); len(names) != 2 || names[0] != "error" || names[1] != "cpu" {
+		t.Errorf("invalid metrics sent to storage: %v", names)
+	}
+	if _, warned := runner.unknownRoutes.Load("missing"); !warned {
+		t.Error("unknown routes should be warned about")
+	}
+	if _, warned := runner.unknownRoutes.Load("storage"); warned {
+		t.Error("connected outputs should not be warned about")
+	}
+}
+
+type batchingProcessor struct {
+	emittingProcessor
+	batches chan []metric.Metric
+	events  chan string
+}
+
+func (proc *batchingProcessor) OnReceive(mt metric.Metric) ([]metric.Metric, error) {
+	return nil, errors.New("metrics should be received in batches")
+}
+
+func (proc *batchingProcessor) OnReceiveBatch(metrics []metric.Metric) ([]metric.Metric, error) {
+	proc.batches <- metrics
+	proc.events <- "batch"
+	return metrics[:1], nil
+}
+
+func (proc *batchingProcessor) Close() error {
+	proc.events <- "close"
+	return nil
+}
+
+func TestProcessorRunnerBatch(t *testing.T) {
+	proc := &batchingProcessor{
+		emittingProcessor: emittingProcessor{cfg: &config.ProcessorConfig{
+			Name:         "batching_processor",
+			BatchMaxSize: 3,
+			BatchMaxWait: config.Duration(50 * time.Millisecond),
+		}},
+		batches: make(chan []metric.Metric, 10),
+		events:  make(chan string, 10),
+	}
+	runner := &processorRunner{proc: proc}
+	input := conveyor.NewConveyor("input", 100)
+	output := conveyor.NewConveyor("output", 100)
+	err := runner.AddInput(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runner.AddOutput(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// queued metrics are received in full batches, and the rest after the max wait
+	for i := 0; i < 4; i++ {
+		err = input.Put(metric.Metric{Name: fmt.Sprintf("m%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	err = runner.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{3, 1} {
+		select {
+		case batch := <-proc.batches:
+			if len(batch) != size {
+				t.Errorf("expected a batch of %d metrics but got %v", size, batch)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("batch should be received")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("partial batch should be received after the max wait, got %v", elapsed)
+	}
+
+	// pending batches are received before the processor is closed
+	err = runner.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.cfg.BatchMaxWait = config.Duration(time.Hour)
+	err = runner.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = input.Put(metric.Metric{Name: "m4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the metric is pending once the runner has taken it from the input
+	deadline := time.Now().Add(time.Second)
+	for len(input.GetChannel()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	err = runner.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []string{}
+	for len(proc.events) > 0 {
+		events = append(events, <-proc.events)
+	}
+	if fmt.Sprint(events) != "[batch batch close batch close]" {
+		t.Errorf("invalid events: %v", events)
+	}
+
+	names := []string{}
+	for len(output.GetChannel()) > 0 {
+		names = append(names, (<-output.GetChannel()).Name)
+	}
+	if fmt.Sprint(names) != "[m0 m3 m4]" {
+		t.Errorf("invalid metrics sent: %v", names)
+	}
+}
+
+func TestNewProcessorBatchOptions(t *testing.T) {
+	RegisterProcessorConstructor("test_emitting", func(cfg *config.ProcessorConfig) (Processor, error) {
+		return &emittingProcessor{cfg: cfg}, nil
+	})
+	RegisterProcessorConstructor("test_batching", func(cfg *config.ProcessorConfig) (Processor, error) {
+		return &batchingProcessor{emittingProcessor: emittingProcessor{cfg: cfg}}, nil
+	})
+
+	cfg := &config.ProcessorConfig{Name: "batched", BatchMaxSize: 10}
+	if _, err := NewProcessor("test_emitting", cfg); err == nil {
+		t.Error("batch options of a processor not receiving batches should be rejected")
+	}
+	if _, err := NewProcessor("test_batching", cfg); err != nil {
+		t.Errorf("batch options of a batch processor should be accepted: %v", err)
+	}
+	if _, err := NewProcessor("test_emitting", &config.ProcessorConfig{Name: "plain"}); err != nil {
+		t.Errorf("processors without batch options should be created: %v", err)
+	}
+}
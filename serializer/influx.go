@@ -0,0 +1,144 @@
+package serializer
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+const (
+	FormatInflux = "influx"
+)
+
+func init() {
+	RegisterSerializerConstructor(FormatInflux, NewInfluxSerializer)
+}
+
+var (
+	influxNameEscaper  = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxKeyEscaper   = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	influxValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// influxSerializer serializes metrics in the InfluxDB line protocol with nanosecond timestamps
+type influxSerializer struct{}
+
+var _ Serializer = (*influxSerializer)(nil)
+
+// NewInfluxSerializer creates a new InfluxDB line protocol serializer
+func NewInfluxSerializer() Serializer {
+	return &influxSerializer{}
+}
+
+func (s *influxSerializer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (s *influxSerializer) Serialize(metrics []metric.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, mt := range metrics {
+		line, err := SerializeInfluxLine(mt)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// SerializeInfluxLine converts a metric to a line of the line protocol without the trailing newline
+// Tags are sorted by key and fields that can not be represented are skipped
+func SerializeInfluxLine(mt metric.Metric) (string, error) {
+	var builder strings.Builder
+	builder.WriteString(influxNameEscaper.Replace(mt.Name))
+
+	tags := make([]metric.Tag, len(mt.Tags))
+	copy(tags, mt.Tags)
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	for _, tag := range tags {
+		if tag.Key == "" || tag.Value == "" {
+			continue
+		}
+		builder.WriteByte(',')
+		builder.WriteString(influxKeyEscaper.Replace(tag.Key))
+		builder.WriteByte('=')
+		builder.WriteString(influxKeyEscaper.Replace(tag.Value))
+	}
+
+	count := 0
+	for _, field := range mt.Fields {
+		value, ok := formatInfluxFieldValue(field.Value)
+		if !ok {
+			continue
+		}
+		if count == 0 {
+			builder.WriteByte(' ')
+		} else {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(influxKeyEscaper.Replace(field.Key))
+		builder.WriteByte('=')
+		builder.WriteString(value)
+		count++
+	}
+	if count == 0 {
+		return "", fmt.Errorf("metric \"%s\" has no valid fields", mt.Name)
+	}
+
+	if !mt.Time.IsZero() {
+		builder.WriteByte(' ')
+		builder.WriteString(strconv.FormatInt(mt.Time.UnixNano(), 10))
+	}
+
+	return builder.String(), nil
+}
+
+func formatInfluxFieldValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return `"` + influxValueEscaper.Replace(v) + `"`, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int8:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int16:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int32:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", true
+	case uint:
+		return strconv.FormatUint(uint64(v), 10) + "u", true
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10) + "u", true
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10) + "u", true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10) + "u", true
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u", true
+	case float32:
+		return formatInfluxFloat(float64(v))
+	case float64:
+		return formatInfluxFloat(v)
+	default:
+		return "", false
+	}
+}
+
+func formatInfluxFloat(v float64) (string, bool) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", false
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64), true
+}
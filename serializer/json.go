@@ -0,0 +1,62 @@
+package serializer
+
+import (
+	"encoding/json"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+const (
+	FormatJSON = "json"
+)
+
+func init() {
+	RegisterSerializerConstructor(FormatJSON, NewJSONSerializer)
+}
+
+type jsonMetric struct {
+	Name      string                 `json:"name"`
+	Tags      map[string]string      `json:"tags"`
+	Fields    map[string]interface{} `json:"fields"`
+	Timestamp int64                  `json:"timestamp"`
+}
+
+// jsonSerializer serializes a batch as {"metrics": [...]} with unix timestamps in seconds
+type jsonSerializer struct{}
+
+var _ Serializer = (*jsonSerializer)(nil)
+
+// NewJSONSerializer creates a new JSON serializer
+func NewJSONSerializer() Serializer {
+	return &jsonSerializer{}
+}
+
+func (s *jsonSerializer) ContentType() string {
+	return "application/json"
+}
+
+func (s *jsonSerializer) Serialize(metrics []metric.Metric) ([]byte, error) {
+	batch := struct {
+		Metrics []jsonMetric `json:"metrics"`
+	}{
+		Metrics: make([]jsonMetric, 0, len(metrics)),
+	}
+
+	for _, mt := range metrics {
+		jm := jsonMetric{
+			Name:      mt.Name,
+			Tags:      make(map[string]string, len(mt.Tags)),
+			Fields:    make(map[string]interface{}, len(mt.Fields)),
+			Timestamp: mt.Time.Unix(),
+		}
+		for _, tag := range mt.Tags {
+			jm.Tags[tag.Key] = tag.Value
+		}
+		for _, field := range mt.Fields {
+			jm.Fields[field.Key] = field.Value
+		}
+		batch.Metrics = append(batch.Metrics, jm)
+	}
+
+	return json.Marshal(batch)
+}
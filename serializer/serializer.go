@@ -0,0 +1,44 @@
+package serializer
+
+import (
+	"fmt"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+// Serializer converts metrics to a wire format
+type Serializer interface {
+	// ContentType returns the MIME type of the serialized data
+	ContentType() string
+
+	// Serialize converts a batch of metrics to the wire format
+	Serialize(metrics []metric.Metric) ([]byte, error)
+}
+
+// SerializerConstructor is a function that creates a new serializer
+type SerializerConstructor func() Serializer
+
+var (
+	format2Constructor = map[string]SerializerConstructor{}
+)
+
+// RegisterSerializerConstructor registers a serializer constructor of a given format
+func RegisterSerializerConstructor(format string, constructor SerializerConstructor) {
+	format2Constructor[format] = constructor
+}
+
+// IsRegistered returns true if a serializer of the format is registered
+func IsRegistered(format string) bool {
+	_, ok := format2Constructor[format]
+	return ok
+}
+
+// NewSerializer creates a new serializer
+func NewSerializer(format string) (Serializer, error) {
+	constructor, ok := format2Constructor[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid serializer format: %s", format)
+	}
+
+	return constructor(), nil
+}
@@ -0,0 +1,85 @@
+package serializer
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/expinc/melegraf/metric"
+)
+
+func TestInfluxSerializer(t *testing.T) {
+	s, err := NewSerializer(FormatInflux)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt := metric.Metric{
+		Name: "cpu load",
+		Tags: []metric.Tag{{Key: "zone", Value: "eu,west"}, {Key: "host", Value: "web=01"}},
+		Fields: []metric.Field{
+			{Key: "usage", Value: 0.5},
+			{Key: "cores", Value: 8},
+			{Key: "ok", Value: true},
+			{Key: "note", Value: `say "hi"`},
+			{Key: "nan", Value: math.NaN()},
+		},
+		Time: time.Unix(1, 5),
+	}
+	data, err := s.Serialize([]metric.Metric{mt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `cpu\ load,host=web\=01,zone=eu\,west usage=0.5,cores=8i,ok=true,note="say \"hi\"" 1000000005` + "\n"
+	if string(data) != expected {
+		t.Errorf("invalid line protocol: %s", string(data))
+	}
+
+	_, err = s.Serialize([]metric.Metric{{Name: "empty"}})
+	if err == nil {
+		t.Error("metric without fields should fail to serialize")
+	}
+}
+
+func TestJSONSerializer(t *testing.T) {
+	s, err := NewSerializer(FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.Serialize([]metric.Metric{{
+		Name:   "cpu",
+		Tags:   []metric.Tag{{Key: "host", Value: "web01"}},
+		Fields: []metric.Field{{Key: "usage", Value: 0.5}},
+		Time:   time.Unix(100, 0),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batch struct {
+		Metrics []struct {
+			Name      string                 `json:"name"`
+			Tags      map[string]string      `json:"tags"`
+			Fields    map[string]interface{} `json:"fields"`
+			Timestamp int64                  `json:"timestamp"`
+		} `json:"metrics"`
+	}
+	err = json.Unmarshal(data, &batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Metrics) != 1 || batch.Metrics[0].Name != "cpu" || batch.Metrics[0].Tags["host"] != "web01" ||
+		batch.Metrics[0].Fields["usage"] != 0.5 || batch.Metrics[0].Timestamp != 100 {
+		t.Errorf("invalid json: %s", string(data))
+	}
+}
+
+func TestNewSerializerInvalidFormat(t *testing.T) {
+	_, err := NewSerializer("invalid")
+	if err == nil {
+		t.Error("invalid format should fail")
+	}
+}
@@ -0,0 +1,319 @@
+// Package sketch implements DDSketch, a mergeable quantile sketch with relative error guarantees
+//
+// A value v is counted in the bucket of index ceil(log_gamma(|v|)) where gamma = (1 + a) / (1 - a)
+// for the relative accuracy a, so that every quantile is estimated within a relative error of a
+// Sketches with the same relative accuracy merge exactly, as if all values were added to one sketch
+package sketch
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// encodingVersion is the first byte of the binary encoding
+const encodingVersion = 1
+
+var errTruncated = errors.New("truncated sketch")
+
+// DDSketch estimates quantiles of the added values
+// It is not safe for concurrent use
+type DDSketch struct {
+	relativeAccuracy float64
+	gamma            float64
+	logGamma         float64
+
+	positive map[int32]uint64
+	negative map[int32]uint64
+	zero     uint64
+
+	count uint64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// NewDDSketch creates an empty sketch with the relative accuracy in (0, 1)
+func NewDDSketch(relativeAccuracy float64) (*DDSketch, error) {
+	if !(relativeAccuracy > 0 && relativeAccuracy < 1) {
+		return nil, fmt.Errorf("invalid relative accuracy: %v", relativeAccuracy)
+	}
+
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &DDSketch{
+		relativeAccuracy: relativeAccuracy,
+		gamma:            gamma,
+		logGamma:         math.Log(gamma),
+		positive:         make(map[int32]uint64),
+		negative:         make(map[int32]uint64),
+		min:              math.Inf(1),
+		max:              math.Inf(-1),
+	}, nil
+}
+
+// RelativeAccuracy returns the relative accuracy of the quantiles
+func (s *DDSketch) RelativeAccuracy() float64 {
+	return s.relativeAccuracy
+}
+
+// Count returns the number of added values
+func (s *DDSketch) Count() uint64 {
+	return s.count
+}
+
+// Sum returns the sum of the added values
+func (s *DDSketch) Sum() float64 {
+	return s.sum
+}
+
+// Min returns the minimum of the added values, or +Inf if there is none
+func (s *DDSketch) Min() float64 {
+	return s.min
+}
+
+// Max returns the maximum of the added values, or -Inf if there is none
+func (s *DDSketch) Max() float64 {
+	return s.max
+}
+
+func (s *DDSketch) index(value float64) int32 {
+	index := math.Ceil(math.Log(value) / s.logGamma)
+	// values beyond the range are kept in the extreme buckets
+	return int32(math.Max(math.Min(index, math.MaxInt32), math.MinInt32))
+}
+
+// value returns the estimate of the values in the bucket
+func (s *DDSketch) value(index int32) float64 {
+	return 2 * math.Pow(s.gamma, float64(index)) / (s.gamma + 1)
+}
+
+// Add adds a value, NaN and infinities are ignored
+func (s *DDSketch) Add(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	switch {
+	case value > 0:
+		s.positive[s.index(value)]++
+	case value < 0:
+		s.negative[s.index(-value)]++
+	default:
+		s.zero++
+	}
+	s.count++
+	s.sum += value
+	s.min = math.Min(s.min, value)
+	s.max = math.Max(s.max, value)
+}
+
+// Merge adds the values of another sketch with the same relative accuracy
+func (s *DDSketch) Merge(other *DDSketch) error {
+	if other.relativeAccuracy != s.relativeAccuracy {
+		return fmt.Errorf("can not merge sketches of relative accuracies %v and %v", s.relativeAccuracy, other.relativeAccuracy)
+	}
+
+	for index, count := range other.positive {
+		s.positive[index] += count
+	}
+	for index, count := range other.negative {
+		s.negative[index] += count
+	}
+	s.zero += other.zero
+	s.count += other.count
+	s.sum += other.sum
+	s.min = math.Min(s.min, other.min)
+	s.max = math.Max(s.max, other.max)
+	return nil
+}
+
+// Quantile estimates the q-quantile for q in [0, 1], it returns false if the sketch is empty
+func (s *DDSketch) Quantile(q float64) (float64, bool) {
+	if s.count == 0 || q < 0 || q > 1 {
+		return 0, false
+	}
+
+	rank := q * float64(s.count-1)
+	var cumulative float64
+	estimate := s.max
+	found := false
+
+	negative := sortedIndexes(s.negative)
+	for i := len(negative) - 1; i >= 0 && !found; i-- {
+		cumulative += float64(s.negative[negative[i]])
+		if cumulative > rank {
+			estimate, found = -s.value(negative[i]), true
+		}
+	}
+	if !found {
+		cumulative += float64(s.zero)
+		if cumulative > rank {
+			estimate, found = 0, true
+		}
+	}
+	if !found {
+		for _, index := range sortedIndexes(s.positive) {
+			cumulative += float64(s.positive[index])
+			if cumulative > rank {
+				estimate = s.value(index)
+				break
+			}
+		}
+	}
+
+	return math.Max(s.min, math.Min(s.max, estimate)), true
+}
+
+func sortedIndexes(store map[int32]uint64) []int32 {
+	indexes := make([]int32, 0, len(store))
+	for index := range store {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i] < indexes[j]
+	})
+	return indexes
+}
+
+// MarshalBinary encodes the sketch as the version, the relative accuracy, the count, the sum,
+// the minimum, the maximum, the zero count and the positive and the negative buckets
+// The buckets are encoded as their number followed by delta encoded indexes and counts
+func (s *DDSketch) MarshalBinary() ([]byte, error) {
+	buf := []byte{encodingVersion}
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(s.relativeAccuracy))
+	buf = binary.AppendUvarint(buf, s.count)
+	for _, v := range []float64{s.sum, s.min, s.max} {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	buf = binary.AppendUvarint(buf, s.zero)
+	buf = appendStore(buf, s.positive)
+	buf = appendStore(buf, s.negative)
+	return buf, nil
+}
+
+func appendStore(buf []byte, store map[int32]uint64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(store)))
+	var previous int64
+	for _, index := range sortedIndexes(store) {
+		buf = binary.AppendVarint(buf, int64(index)-previous)
+		buf = binary.AppendUvarint(buf, store[index])
+		previous = int64(index)
+	}
+	return buf
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary
+func (s *DDSketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 9 {
+		return errTruncated
+	}
+	if data[0] != encodingVersion {
+		return fmt.Errorf("unsupported sketch version %d", data[0])
+	}
+
+	decoded, err := NewDDSketch(math.Float64frombits(binary.LittleEndian.Uint64(data[1:9])))
+	if err != nil {
+		return err
+	}
+	d := &decoder{data: data[9:]}
+	decoded.count = d.uvarint()
+	decoded.sum = d.float64()
+	decoded.min = d.float64()
+	decoded.max = d.float64()
+	decoded.zero = d.uvarint()
+	total := decoded.zero
+	for _, store := range []map[int32]uint64{decoded.positive, decoded.negative} {
+		n := d.uvarint()
+		var index int64
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			index += d.varint()
+			if index < math.MinInt32 || index > math.MaxInt32 {
+				return fmt.Errorf("invalid sketch bucket index %d", index)
+			}
+			count := d.uvarint()
+			store[int32(index)] += count
+			total += count
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) != 0 {
+		return fmt.Errorf("unexpected %d bytes after sketch", len(d.data))
+	}
+	if total != decoded.count {
+		return fmt.Errorf("sketch count %d does not match buckets %d", decoded.count, total)
+	}
+
+	*s = *decoded
+	return nil
+}
+
+// decoder reads the binary encoding and keeps the first error
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = errTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) float64() float64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 8 {
+		d.err = errTruncated
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+	d.data = d.data[8:]
+	return v
+}
+
+// Encode returns the binary encoding of the sketch in base64, suitable for a string field
+func (s *DDSketch) Encode() string {
+	data, _ := s.MarshalBinary()
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// Decode decodes a sketch returned by Encode
+func Decode(str string) (*DDSketch, error) {
+	data, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sketch encoding: %w", err)
+	}
+
+	s := &DDSketch{}
+	err = s.UnmarshalBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
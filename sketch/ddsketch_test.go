@@ -0,0 +1,126 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func exactQuantile(sorted []float64, q float64) float64 {
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+func checkQuantiles(t *testing.T, s *DDSketch, values []float64) {
+	t.Helper()
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	for _, q := range []float64{0, 0.1, 0.25, 0.5, 0.75, 0.9, 0.95, 0.99, 0.999, 1} {
+		estimate, ok := s.Quantile(q)
+		if !ok {
+			t.Fatalf("quantile %v should exist", q)
+		}
+		exact := exactQuantile(sorted, q)
+		if math.Abs(estimate-exact) > s.RelativeAccuracy()*math.Abs(exact)+1e-12 {
+			t.Errorf("quantile %v estimated %v, expected %v within %v", q, estimate, exact, s.RelativeAccuracy())
+		}
+	}
+}
+
+func TestDDSketchAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]float64, 0, 10000)
+	s, err := NewDDSketch(0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10000; i++ {
+		v := math.Exp(rng.NormFloat64()*2) * 10
+		if i%10 == 0 {
+			v = -v
+		}
+		if i%100 == 0 {
+			v = 0
+		}
+		values = append(values, v)
+		s.Add(v)
+	}
+	s.Add(math.NaN())
+	s.Add(math.Inf(1))
+
+	if s.Count() != 10000 {
+		t.Errorf("expected 10000 values but got %d", s.Count())
+	}
+	checkQuantiles(t, s, values)
+}
+
+func TestDDSketchMergeAndEncode(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	var values []float64
+	merged, _ := NewDDSketch(0.02)
+	for part := 0; part < 5; part++ {
+		s, _ := NewDDSketch(0.02)
+		for i := 0; i < 1000; i++ {
+			v := rng.Float64()*1000 + float64(part)*500
+			values = append(values, v)
+			s.Add(v)
+		}
+
+		decoded, err := Decode(s.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := merged.Merge(decoded); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if merged.Count() != uint64(len(values)) {
+		t.Errorf("expected %d values but got %d", len(values), merged.Count())
+	}
+	checkQuantiles(t, merged, values)
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	if math.Abs(merged.Sum()-sum) > 1e-6 {
+		t.Errorf("expected sum %v but got %v", sum, merged.Sum())
+	}
+
+	other, _ := NewDDSketch(0.01)
+	if merged.Merge(other) == nil {
+		t.Error("merging sketches of different accuracies should fail")
+	}
+}
+
+func TestDDSketchEmptyAndInvalid(t *testing.T) {
+	s, _ := NewDDSketch(0.01)
+	if _, ok := s.Quantile(0.5); ok {
+		t.Error("empty sketch should have no quantile")
+	}
+	decoded, err := Decode(s.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Count() != 0 || !math.IsInf(decoded.Min(), 1) {
+		t.Errorf("invalid empty sketch: %v", decoded)
+	}
+
+	for _, accuracy := range []float64{0, 1, -0.1, math.NaN()} {
+		if _, err := NewDDSketch(accuracy); err == nil {
+			t.Errorf("relative accuracy %v should be invalid", accuracy)
+		}
+	}
+
+	s.Add(1)
+	data, _ := s.MarshalBinary()
+	for _, invalid := range [][]byte{nil, data[:len(data)-1], append(append([]byte{}, data...), 0), append([]byte{2}, data[1:]...)} {
+		if err := (&DDSketch{}).UnmarshalBinary(invalid); err == nil {
+			t.Errorf("decoding %v should fail", invalid)
+		}
+	}
+	if _, err := Decode("not base64!"); err == nil {
+		t.Error("decoding invalid base64 should fail")
+	}
+}